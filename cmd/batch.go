@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxBatchSize is the maximum number of operations in a batch
+const maxBatchSize = 10000
+
+// batchWriteTimeout replaces the server write timeout for batches, which may take longer to apply
+// than single operations. It leaves room for a batch of maxBatchSize operations on a loaded database.
+const batchWriteTimeout = time.Minute
+
+// Batch item statuses: an item that was valid but not applied because another item
+// of an atomic batch failed is reported as not applied
+const (
+	batchItemApplied    = "applied"
+	batchItemFailed     = "failed"
+	batchItemNotApplied = "not_applied"
+)
+
+// batchItemResult is the outcome of a single operation in a batch. Code is the HTTP status code
+// and ErrorCode the error code from the catalogue the operation would get from the single operation endpoint.
+type batchItemResult struct {
+	Index       int         `json:"index"`
+	Status      string      `json:"status"`
+	OperationID pgtype.UUID `json:"operation_id"`
+	Code        int         `json:"code,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	ErrorCode   string      `json:"error_code,omitempty"`
+}
+
+// batchResponse reports the outcome of every operation in a batch in the order of the request
+type batchResponse struct {
+	Mode    string            `json:"mode"`
+	Applied int               `json:"applied"`
+	Failed  int               `json:"failed"`
+	Results []batchItemResult `json:"results"`
+}
+
+// fail marks the item as failed with the given problem
+func (res *batchResponse) fail(i int, p *problem) {
+	res.Results[i] = batchItemResult{Index: i, Status: batchItemFailed, Code: p.Status, Error: p.Detail, ErrorCode: p.Code}
+	res.Failed++
+}
+
+// batchWallet is the state of a locked wallet while the operations of a batch are applied to it in memory
+type batchWallet struct {
+	database.GetBalancesForUpdateRow
+	limits           withdrawalLimits
+	dailyWithdrawn   int64
+	monthlyWithdrawn int64
+}
+
+// apply checks the operation against the wallet state and updates the state if it is allowed.
+// Otherwise it returns the problem, matching handleOperation.
+func (wallet *batchWallet) apply(item operations.BatchItem) *problem {
+	if p := walletStatusProblem(wallet.Status, item.OperationType == operations.Withdraw); p != nil {
+		return p
+	}
+
+	if item.Currency != "" && !strings.EqualFold(item.Currency, wallet.Currency) {
+		return currencyMismatch(wallet.Currency, item.Currency, "operation")
+	}
+
+	if item.OperationType == operations.Deposit {
+		balance, err := operations.Credit(wallet.Balance, item.Amount)
+		if err != nil {
+			return balanceOverflow(wallet.Balance, item.Amount)
+		}
+		wallet.Balance = balance
+		return nil
+	}
+
+	available := operations.Available(wallet.Balance, wallet.Held, wallet.CreditLimit)
+	if available < item.Amount {
+		return insufficientFunds("withdraw", available, item.Amount)
+	}
+	balance, err := operations.Debit(wallet.Balance, item.Amount)
+	if err != nil {
+		return balanceOverflow(wallet.Balance, item.Amount)
+	}
+	if wallet.limits.Daily.Valid && item.Amount > wallet.limits.Daily.Int64-wallet.dailyWithdrawn {
+		return withdrawalLimitExceeded("daily", wallet.limits.Daily.Int64, wallet.dailyWithdrawn, item.Amount)
+	}
+	if wallet.limits.Monthly.Valid && item.Amount > wallet.limits.Monthly.Int64-wallet.monthlyWithdrawn {
+		return withdrawalLimitExceeded("monthly", wallet.limits.Monthly.Int64, wallet.monthlyWithdrawn, item.Amount)
+	}
+
+	wallet.Balance = balance
+	wallet.dailyWithdrawn += item.Amount
+	wallet.monthlyWithdrawn += item.Amount
+	return nil
+}
+
+// handleBatch applies many deposits and withdrawals in one transaction. All wallets of the batch
+// are locked at once and the operations are checked in memory in the order of the request.
+// The accepted operations of an atomic batch are written to the database in a single pgx batch,
+// the ones of a best-effort batch one by one, each in a savepoint.
+func (app *application) handleBatch(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON from request to struct
+	var req operations.BatchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	// Check batch mode and size
+	if req.Mode == "" {
+		req.Mode = operations.Atomic
+	}
+	if req.Mode != operations.Atomic && req.Mode != operations.BestEffort {
+		writeProblem(w, r, problemInvalidBatch, "Invalid batch mode: expected mode to be \"atomic\" or \"best_effort\"", nil)
+		return
+	}
+	if len(req.Operations) == 0 {
+		writeProblem(w, r, problemInvalidBatch, "Batch must contain at least one operation", nil)
+		return
+	}
+	if len(req.Operations) > maxBatchSize {
+		writeProblem(w, r, problemInvalidBatch, fmt.Sprintf("Batch must contain at most %d operations", maxBatchSize), map[string]any{"max_operations": maxBatchSize})
+		return
+	}
+
+	res := batchResponse{Mode: req.Mode, Results: make([]batchItemResult, len(req.Operations))}
+
+	// Check every operation on its own and collect the wallets to lock
+	walletUUIDs := make([]pgtype.UUID, len(req.Operations))
+	var lockIDs, withdrawalIDs []pgtype.UUID
+	seen := make(map[pgtype.UUID]bool)
+	withdrawing := make(map[pgtype.UUID]bool)
+	for i, item := range req.Operations {
+		err := walletUUIDs[i].Scan(item.WalletID)
+		switch {
+		case err != nil:
+			res.fail(i, newProblem(problemInvalidWalletID, "Invalid wallet ID", nil))
+			continue
+		case item.OperationType != operations.Deposit && item.OperationType != operations.Withdraw:
+			res.fail(i, newProblem(problemInvalidOperationType, "Unsupported operation type: expected operation_type to be \"deposit\" or \"withdraw\"", nil))
+			continue
+		case item.Amount <= 0:
+			res.fail(i, newProblem(problemInvalidAmount, "Amount must be greater than zero", nil))
+			continue
+		}
+
+		if !seen[walletUUIDs[i]] {
+			seen[walletUUIDs[i]] = true
+			lockIDs = append(lockIDs, walletUUIDs[i])
+		}
+		if item.OperationType == operations.Withdraw && !withdrawing[walletUUIDs[i]] {
+			withdrawing[walletUUIDs[i]] = true
+			withdrawalIDs = append(withdrawalIDs, walletUUIDs[i])
+		}
+	}
+	if res.Failed > 0 && req.Mode == operations.Atomic {
+		app.writeBatchResponse(w, &res)
+		return
+	}
+
+	// Start transaction
+	tx, err := app.begin(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to begin batch transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock all wallets of the batch in the order of their IDs
+	rows, err := queriesWithTx.GetBalancesForUpdate(r.Context(), lockIDs)
+	if err != nil {
+		serverError(w, r, "Failed to get wallet balances", err)
+		return
+	}
+	wallets := make(map[pgtype.UUID]*batchWallet, len(rows))
+	for _, row := range rows {
+		wallets[row.ID] = &batchWallet{GetBalancesForUpdateRow: row}
+	}
+
+	// Load the withdrawal limits and the amounts already withdrawn by the wallets that withdraw
+	if len(withdrawalIDs) > 0 {
+		usages, err := queriesWithTx.GetWithdrawalUsages(r.Context(), withdrawalIDs)
+		if err != nil {
+			serverError(w, r, "Failed to get withdrawal usages", err)
+			return
+		}
+		for _, usage := range usages {
+			wallet, ok := wallets[usage.WalletID]
+			if !ok {
+				continue
+			}
+			wallet.limits = app.defaultLimits
+			if usage.CustomLimits {
+				wallet.limits = withdrawalLimits{Daily: usage.DailyWithdrawalLimit, Monthly: usage.MonthlyWithdrawalLimit}
+			}
+			wallet.dailyWithdrawn, wallet.monthlyWithdrawn = usage.DailyWithdrawn, usage.MonthlyWithdrawn
+		}
+	}
+
+	// Apply the operations in memory in the order of the request. An atomic batch writes the accepted
+	// operations at the end; a best-effort batch records every operation right away in a savepoint
+	// of its own, so that a database error fails only that operation.
+	records := make([]database.RecordOperationParams, 0, len(req.Operations))
+	for i, item := range req.Operations {
+		if res.Results[i].Status == batchItemFailed {
+			continue
+		}
+
+		wallet, ok := wallets[walletUUIDs[i]]
+		if !ok {
+			res.fail(i, newProblem(problemWalletNotFound, "Wallet not found", nil))
+			continue
+		}
+		state := *wallet
+		if p := wallet.apply(item); p != nil {
+			res.fail(i, p)
+			continue
+		}
+
+		var record database.RecordOperationParams
+		if req.Mode == operations.BestEffort {
+			record, err = app.recordBatchItem(r.Context(), tx, wallet, item)
+			if err != nil {
+				// Undo the operation, so the next operations of the wallet are checked against its recorded state
+				*wallet = state
+				slog.ErrorContext(r.Context(), "Failed to record batch operation", "index", i, "error", errorSummary(err))
+				res.fail(i, newProblem(problemInternal, "Failed to record operation", nil))
+				continue
+			}
+		} else {
+			record, err = app.batchRecord(r.Context(), queriesWithTx, wallet, item)
+			if err != nil {
+				serverError(w, r, "Failed to get "+externalAccount+" account", err)
+				return
+			}
+		}
+		records = append(records, record)
+		res.Results[i] = batchItemResult{Index: i, Status: batchItemApplied, OperationID: record.ID}
+		res.Applied++
+	}
+
+	// An atomic batch is applied only if every operation succeeds
+	if req.Mode == operations.Atomic {
+		if res.Failed > 0 {
+			app.writeBatchResponse(w, &res)
+			return
+		}
+
+		// Insert the operations and post their ledger journals in one round trip
+		var batchErr error
+		queriesWithTx.RecordOperation(r.Context(), records).Exec(func(i int, err error) {
+			if err != nil && batchErr == nil {
+				batchErr = err
+			}
+		})
+		if batchErr != nil {
+			serverError(w, r, "Failed to record batch operations", batchErr)
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to commit batch transaction", err)
+		return
+	}
+	for _, record := range records {
+		app.metrics.observeOperation(record.OperationType, record.Amount)
+	}
+
+	app.writeBatchResponse(w, &res)
+}
+
+// batchRecord prepares the operation of a batch item for writing: the ledger journal of a deposit moves funds
+// from the external account of the currency to the wallet, and of a withdrawal the other way
+func (app *application) batchRecord(ctx context.Context, queries *database.Queries, wallet *batchWallet, item operations.BatchItem) (database.RecordOperationParams, error) {
+	externalID, err := app.systemAccountID(ctx, queries, externalAccount, wallet.Currency)
+	if err != nil {
+		return database.RecordOperationParams{}, err
+	}
+
+	record := database.RecordOperationParams{
+		ID:            newUUID(),
+		WalletID:      wallet.ID,
+		OperationType: item.OperationType,
+		Amount:        item.Amount,
+	}
+	if item.OperationType == operations.Deposit {
+		record.DebitAccountID, record.CreditAccountID = externalID, wallet.ID
+	} else {
+		record.DebitAccountID, record.CreditAccountID = wallet.ID, externalID
+	}
+	return record, nil
+}
+
+// recordBatchItem records the operation of an item of a best-effort batch in a savepoint,
+// which is rolled back if the operation cannot be recorded, leaving the rest of the batch intact
+func (app *application) recordBatchItem(ctx context.Context, tx pgx.Tx, wallet *batchWallet, item operations.BatchItem) (database.RecordOperationParams, error) {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return database.RecordOperationParams{}, err
+	}
+	defer savepoint.Rollback(ctx)
+
+	queries := app.queries.WithTx(savepoint)
+	record, err := app.batchRecord(ctx, queries, wallet, item)
+	if err != nil {
+		return database.RecordOperationParams{}, err
+	}
+	queries.RecordOperation(ctx, []database.RecordOperationParams{record}).Exec(func(_ int, batchErr error) {
+		err = batchErr
+	})
+	if err != nil {
+		return database.RecordOperationParams{}, err
+	}
+	return record, savepoint.Commit(ctx)
+}
+
+// writeBatchResponse responds with the results of the batch. If an atomic batch has failed operations,
+// nothing was applied: the remaining operations are reported as not applied with 422 Unprocessable Entity.
+func (app *application) writeBatchResponse(w http.ResponseWriter, res *batchResponse) {
+	if res.Failed == 0 || res.Mode != operations.Atomic {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	for i := range res.Results {
+		if res.Results[i].Status != batchItemFailed {
+			res.Results[i] = batchItemResult{Index: i, Status: batchItemNotApplied}
+		}
+	}
+	res.Applied = 0
+	writeJSON(w, http.StatusUnprocessableEntity, res)
+}
+
+// withWriteTimeout gives the handler its own write timeout instead of the one of the server
+func withWriteTimeout(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.WarnContext(r.Context(), "Failed to extend write deadline", "error", err)
+		}
+		next.ServeHTTP(w, r)
+	}
+}
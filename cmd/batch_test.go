@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleBatch(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name             string
+		body             string
+		mockError        error
+		expectedCode     int
+		expectedBody     string
+		expectedProblem  string
+		expectedDetail   string
+		expectedStatuses []string
+	}{
+		{
+			name:            "Invalid mode",
+			body:            `{"mode":"eventually","operations":[{"wallet_id":"` + validUUID + `","operation_type":"deposit","amount":10}]}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_batch",
+			expectedDetail:  "Invalid batch mode: expected mode to be \"atomic\" or \"best_effort\"",
+		},
+		{
+			name:            "Empty batch",
+			body:            `{"operations":[]}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_batch",
+			expectedDetail:  "Batch must contain at least one operation",
+		},
+		{
+			name: "Atomic batch with an invalid operation",
+			body: `{"mode":"atomic","operations":[` +
+				`{"wallet_id":"` + validUUID + `","operation_type":"deposit","amount":10},` +
+				`{"wallet_id":"` + invalidUUID + `","operation_type":"deposit","amount":10}]}`,
+			expectedCode:     http.StatusUnprocessableEntity,
+			expectedStatuses: []string{batchItemNotApplied, batchItemFailed},
+		},
+		{
+			name: "Best-effort batch with a missing wallet",
+			body: `{"mode":"best_effort","operations":[` +
+				`{"wallet_id":"` + validUUID + `","operation_type":"deposit","amount":10},` +
+				`{"wallet_id":"` + validUUID + `","operation_type":"transfer","amount":10}]}`,
+			expectedCode:     http.StatusOK,
+			expectedStatuses: []string{batchItemFailed, batchItemFailed},
+		},
+		{
+			name:            "Unexpected error",
+			body:            `{"operations":[{"wallet_id":"` + validUUID + `","operation_type":"deposit","amount":10}]}`,
+			mockError:       errors.New("unexpected error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: "internal_error",
+			expectedDetail:  "Failed to get wallet balances",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/operations/batch", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			contract(t, app.handleBatch).ServeHTTP(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+			if tc.expectedStatuses != nil {
+				var batch batchResponse
+				if err := json.Unmarshal(body, &batch); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				statuses := make([]string, len(batch.Results))
+				for i, result := range batch.Results {
+					statuses[i] = result.Status
+				}
+				assert.Equal(t, tc.expectedStatuses, statuses)
+			}
+		})
+	}
+}
+
+// TestBatch applies atomic and best-effort batches to real wallets. It needs a migrated
+// PostgreSQL database in TEST_DB_URL.
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletIDs := make([]string, 2)
+	walletUUIDs := make([]pgtype.UUID, 2)
+	for i := range walletUUIDs {
+		var err error
+		walletUUIDs[i], err = app.queries.CreateWallet(ctx, "RUB")
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		walletIDs[i] = walletUUIDs[i].String()
+	}
+
+	// postBatch sends the batch to the handler and returns the status code and the response
+	postBatch := func(mode string, items ...operations.BatchItem) (int, batchResponse) {
+		body, err := json.Marshal(operations.BatchRequest{Mode: mode, Operations: items})
+		if err != nil {
+			t.Fatalf("Failed to marshal request body: %v", err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/operations/batch", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		contract(t, app.handleBatch).ServeHTTP(w, req)
+
+		var res batchResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+
+	// An atomic batch with an overdraft applies nothing
+	code, res := postBatch(operations.Atomic,
+		operations.BatchItem{WalletID: walletIDs[0], OperationType: operations.Deposit, Amount: 100},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Withdraw, Amount: 50},
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, 0, res.Applied)
+
+	// A best-effort batch applies everything but the overdraft, in order
+	code, res = postBatch(operations.BestEffort,
+		operations.BatchItem{WalletID: walletIDs[0], OperationType: operations.Deposit, Amount: 100},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Withdraw, Amount: 50},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Deposit, Amount: 70},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Withdraw, Amount: 50},
+	)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, res.Applied)
+	assert.Equal(t, 1, res.Failed)
+
+	for i, expected := range []int64{100, 20} {
+		walletUUID := walletUUIDs[i]
+		balance, err := app.queries.GetBalance(ctx, walletUUID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet balance: %v", err)
+		}
+		assert.Equal(t, expected, balance)
+		assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+		assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+	}
+
+	// The database rejects operations of a marked amount, as a trigger or a constraint could
+	rejectedAmount := int64(7777)
+	_, err := dbPool.Exec(ctx, `
+		CREATE OR REPLACE FUNCTION test_reject_batch_operation() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'operation rejected';
+		END $$ LANGUAGE plpgsql;
+		CREATE OR REPLACE TRIGGER test_reject_batch_operation BEFORE INSERT ON operations
+		FOR EACH ROW WHEN (NEW.amount = 7777) EXECUTE FUNCTION test_reject_batch_operation();`)
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = dbPool.Exec(ctx, `
+			DROP TRIGGER test_reject_batch_operation ON operations;
+			DROP FUNCTION test_reject_batch_operation();`)
+	})
+
+	// A best-effort operation rejected by the database fails alone, and the next operations
+	// of its wallet are checked without it
+	code, res = postBatch(operations.BestEffort,
+		operations.BatchItem{WalletID: walletIDs[0], OperationType: operations.Deposit, Amount: 10},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Deposit, Amount: rejectedAmount},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Withdraw, Amount: 500},
+		operations.BatchItem{WalletID: walletIDs[1], OperationType: operations.Withdraw, Amount: 15},
+		operations.BatchItem{WalletID: walletIDs[0], OperationType: operations.Deposit, Amount: 5},
+	)
+	assert.Equal(t, http.StatusOK, code)
+	statuses := make([]string, len(res.Results))
+	for i, result := range res.Results {
+		statuses[i] = result.Status
+	}
+	assert.Equal(t, []string{batchItemApplied, batchItemFailed, batchItemFailed, batchItemApplied, batchItemApplied}, statuses)
+	assert.Equal(t, "internal_error", res.Results[1].ErrorCode)
+	assert.Equal(t, "insufficient_funds", res.Results[2].ErrorCode)
+
+	for i, expected := range []int64{115, 5} {
+		walletUUID := walletUUIDs[i]
+		balance, err := app.queries.GetBalance(ctx, walletUUID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet balance: %v", err)
+		}
+		assert.Equal(t, expected, balance)
+		assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+		assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+	}
+}
+
+// TestBatchMaxSize applies batches of the maximum size through a server with the production timeouts
+// and checks that the response arrives. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestBatchMaxSize(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+	app.auth.username = "admin"
+	app.auth.password = "secret"
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = app.newHTTPServer("")
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	walletUUIDs := make([]pgtype.UUID, 10)
+	for i := range walletUUIDs {
+		var err error
+		walletUUIDs[i], err = app.queries.CreateWallet(ctx, "RUB")
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+	}
+
+	for _, mode := range []string{operations.Atomic, operations.BestEffort} {
+		t.Run(mode, func(t *testing.T) {
+			// Every wallet gets deposits of 2 and withdrawals of 1 in turn
+			items := make([]operations.BatchItem, maxBatchSize)
+			for i := range items {
+				items[i] = operations.BatchItem{WalletID: walletUUIDs[i%len(walletUUIDs)].String(), OperationType: operations.Deposit, Amount: 2}
+				if i/len(walletUUIDs)%2 == 1 {
+					items[i].OperationType = operations.Withdraw
+					items[i].Amount = 1
+				}
+			}
+			body, err := json.Marshal(operations.BatchRequest{Mode: mode, Operations: items})
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req, err := http.NewRequest("POST", srv.URL+"/api/v1/operations/batch", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.SetBasicAuth("admin", "secret")
+			start := time.Now()
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send batch: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var res batchResponse
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				t.Fatalf("Failed to read batch response: %v", err)
+			}
+			t.Logf("Applied a batch of %d operations in %s", maxBatchSize, time.Since(start))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, maxBatchSize, res.Applied)
+		})
+	}
+
+	// Each batch adds 1 per pair of operations of a wallet
+	for _, walletUUID := range walletUUIDs {
+		balance, err := app.queries.GetBalance(ctx, walletUUID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet balance: %v", err)
+		}
+		assert.Equal(t, int64(maxBatchSize/len(walletUUIDs)), balance)
+		assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+	}
+}
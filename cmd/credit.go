@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// creditLimitRequest is the body of a request to change the credit limit of a wallet
+type creditLimitRequest struct {
+	CreditLimit int64 `json:"credit_limit"`
+}
+
+// creditResponse reports how much of the credit limit of a wallet is in use
+type creditResponse struct {
+	CreditLimit     int64 `json:"credit_limit"`
+	CreditUsed      int64 `json:"credit_used"`
+	CreditAvailable int64 `json:"credit_available"`
+}
+
+// newCreditResponse calculates the credit usage of a wallet from its balance and credit limit
+func newCreditResponse(balance, creditLimit int64) creditResponse {
+	used := operations.CreditUsed(balance)
+	return creditResponse{CreditLimit: creditLimit, CreditUsed: used, CreditAvailable: max(creditLimit-used, 0)}
+}
+
+func (app *application) handleGetCredit(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	wallet, err := app.queries.GetWallet(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newCreditResponse(wallet.Balance, wallet.CreditLimit))
+}
+
+func (app *application) handleSetCreditLimit(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Decode JSON from request to struct
+	var req creditLimitRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	if req.CreditLimit < 0 {
+		writeProblem(w, r, problemInvalidLimit, "Credit limit must not be negative", nil)
+		return
+	}
+
+	// Start transaction
+	tx, err := app.db.Begin(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to begin credit limit transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock the wallet row, so no withdrawal uses more credit while the limit is checked
+	wallet, err := queriesWithTx.GetBalanceForUpdate(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	if wallet.Status == walletClosed {
+		writeProblem(w, r, problemWalletClosed, "Wallet is closed", map[string]any{"wallet_status": walletClosed})
+		return
+	}
+
+	// The limit cannot be lowered below the credit already in use
+	if used := operations.CreditUsed(wallet.Balance); req.CreditLimit < used {
+		writeProblem(w, r, problemCreditInUse, fmt.Sprintf("Credit limit is less than the credit in use: credit in use %d, credit limit %d", used, req.CreditLimit),
+			map[string]any{"credit_used": used, "credit_limit": req.CreditLimit})
+		return
+	}
+
+	updated, err := queriesWithTx.UpdateWalletCreditLimit(r.Context(), database.UpdateWalletCreditLimitParams{
+		ID:          walletUUID,
+		CreditLimit: req.CreditLimit,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to update credit limit", err)
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to commit credit limit transaction", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newCreditResponse(updated.Balance, updated.CreditLimit))
+}
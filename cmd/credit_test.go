@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSetCreditLimit(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name            string
+		body            string
+		mockRow         []any
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Raise credit limit",
+			body:         `{"credit_limit":1000}`,
+			mockRow:      []any{int64(-200), "RUB", int64(0), walletActive, int64(500)},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Lower credit limit below credit in use",
+			body:            `{"credit_limit":100}`,
+			mockRow:         []any{int64(-200), "RUB", int64(0), walletActive, int64(500)},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "credit_limit_below_usage",
+			expectedDetail:  "Credit limit is less than the credit in use: credit in use 200, credit limit 100",
+		},
+		{
+			name:            "Negative credit limit",
+			body:            `{"credit_limit":-1}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_limit",
+			expectedDetail:  "Credit limit must not be negative",
+		},
+		{
+			name:            "Closed wallet",
+			body:            `{"credit_limit":1000}`,
+			mockRow:         []any{int64(0), "RUB", int64(0), walletClosed, int64(0)},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_closed",
+			expectedDetail:  "Wallet is closed",
+		},
+		{
+			name:            "Wallet not found",
+			body:            `{"credit_limit":1000}`,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Row: tc.mockRow,
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("PUT", "/api/v1/wallets/"+validUUID+"/credit", strings.NewReader(tc.body))
+			req.SetPathValue("wallet_id", validUUID)
+			w := httptest.NewRecorder()
+
+			contract(t, app.handleSetCreditLimit).ServeHTTP(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// walletEventsChannel is the Postgres channel that wallet events are published on
+	walletEventsChannel = "wallet_events"
+	// walletEventBuffer is the number of events buffered for a stream; a client that falls further behind
+	// is disconnected and resumes with Last-Event-ID
+	walletEventBuffer = 64
+	// eventStreamRetry is how long a client waits before reconnecting to a closed stream
+	eventStreamRetry = 3 * time.Second
+	// listenRetryInterval is how long the broker waits before listening again after losing its connection
+	listenRetryInterval = time.Second
+)
+
+// walletEvent is an event published on the wallet events channel
+type walletEvent struct {
+	Seq      int64           `json:"seq"`
+	WalletID pgtype.UUID     `json:"wallet_id"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// eventBroker listens for wallet events committed by any server instance and fans them out
+// to the streams of the wallets they belong to
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[pgtype.UUID]map[chan walletEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[pgtype.UUID]map[chan walletEvent]struct{})}
+}
+
+// subscribe returns a channel with the events of the wallet and a function that cancels the subscription.
+// The channel is closed if the subscriber falls behind or the broker stops receiving events.
+func (b *eventBroker) subscribe(walletUUID pgtype.UUID) (<-chan walletEvent, func()) {
+	ch := make(chan walletEvent, walletEventBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[walletUUID] == nil {
+		b.subscribers[walletUUID] = make(map[chan walletEvent]struct{})
+	}
+	b.subscribers[walletUUID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[walletUUID][ch]; ok {
+			b.remove(walletUUID, ch)
+		}
+	}
+}
+
+// publish sends the event to the subscribers of its wallet without waiting for them
+func (b *eventBroker) publish(event walletEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.WalletID] {
+		select {
+		case ch <- event:
+		default:
+			b.remove(event.WalletID, ch)
+		}
+	}
+}
+
+// closeAll closes every subscription, so the clients reconnect and catch up on missed events
+func (b *eventBroker) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for walletUUID, channels := range b.subscribers {
+		for ch := range channels {
+			b.remove(walletUUID, ch)
+		}
+	}
+}
+
+// remove closes and forgets a subscription; b.mu must be held
+func (b *eventBroker) remove(walletUUID pgtype.UUID, ch chan walletEvent) {
+	close(ch)
+	delete(b.subscribers[walletUUID], ch)
+	if len(b.subscribers[walletUUID]) == 0 {
+		delete(b.subscribers, walletUUID)
+	}
+}
+
+// run listens for wallet events until ctx is canceled, reconnecting when the connection is lost
+func (b *eventBroker) run(ctx context.Context, pool *pgxpool.Pool) {
+	for {
+		err := b.listen(ctx, pool)
+
+		// Events may have been missed while the connection was down
+		b.closeAll()
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Failed to listen for wallet events", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryInterval):
+		}
+	}
+}
+
+// listen holds a dedicated connection that listens on the wallet events channel and publishes
+// every notification until ctx is canceled or the connection fails
+func (b *eventBroker) listen(ctx context.Context, pool *pgxpool.Pool) error {
+	poolConn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection keeps listening, so it is taken out of the pool rather than released into it
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+walletEventsChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event walletEvent
+		err = json.Unmarshal([]byte(notification.Payload), &event)
+		if err != nil {
+			slog.Error("Failed to decode wallet event", "error", err)
+			continue
+		}
+		b.publish(event)
+	}
+}
+
+// handleWalletEvents streams the events of a wallet as Server-Sent Events. A client that sends
+// Last-Event-ID first receives the events it missed, then new events as their transactions commit.
+func (app *application) handleWalletEvents(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Read the ID of the last event the client received, if it is resuming
+	var lastSeq int64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		lastSeq, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || lastSeq < 0 {
+			writeProblem(w, r, problemInvalidHeader, "Invalid Last-Event-ID header: expected an event ID", nil)
+			return
+		}
+	}
+
+	// Check that the wallet exists
+	_, err = app.queries.GetWallet(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet", err)
+		return
+	}
+
+	// Subscribe before catching up, so no event committed in between is lost
+	events, unsubscribe := app.events.subscribe(walletUUID)
+	defer unsubscribe()
+
+	// The stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "Failed to clear write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	if err != nil {
+		return
+	}
+
+	// Send the events missed since Last-Event-ID
+	for {
+		missed, err := app.queries.GetWalletEventsAfter(r.Context(), database.GetWalletEventsAfterParams{
+			WalletID:  walletUUID,
+			AfterSeq:  lastSeq,
+			MaxEvents: maxPageLimit,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get missed wallet events", "error", errorSummary(err))
+			return
+		}
+		for _, event := range missed {
+			if writeEvent(w, event.Seq, event.EventType, event.Payload) != nil {
+				return
+			}
+			lastSeq = event.Seq
+		}
+		if len(missed) < maxPageLimit {
+			break
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// The client fell behind or the server is shutting down; it reconnects with Last-Event-ID
+				return
+			}
+			if event.Seq <= lastSeq {
+				// Already sent while catching up
+				continue
+			}
+			err = writeEvent(w, event.Seq, event.Type, event.Payload)
+			lastSeq = event.Seq
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err != nil || rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a Server-Sent Event; the payload is single-line JSON
+func writeEvent(w http.ResponseWriter, seq int64, eventType string, payload []byte) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, eventType, payload)
+	return err
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBroker(t *testing.T) {
+	broker := newEventBroker()
+	walletUUID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	otherUUID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	events, unsubscribe := broker.subscribe(walletUUID)
+	slow, _ := broker.subscribe(walletUUID)
+	other, _ := broker.subscribe(otherUUID)
+
+	// Events only reach the subscribers of their wallet
+	broker.publish(walletEvent{Seq: 1, WalletID: walletUUID, Type: "balance.changed"})
+	assert.Equal(t, int64(1), (<-events).Seq)
+	assert.Len(t, other, 0)
+
+	// A subscriber that falls behind is disconnected
+	for i := range walletEventBuffer {
+		broker.publish(walletEvent{Seq: int64(i + 2), WalletID: walletUUID})
+		<-events
+	}
+	broker.publish(walletEvent{Seq: walletEventBuffer + 2, WalletID: walletUUID})
+	for range walletEventBuffer {
+		<-slow
+	}
+	_, ok := <-slow
+	assert.False(t, ok)
+	assert.Equal(t, int64(walletEventBuffer+2), (<-events).Seq)
+
+	// Unsubscribing twice is safe
+	unsubscribe()
+	unsubscribe()
+	_, ok = <-events
+	assert.False(t, ok)
+
+	// Closing the broker disconnects everyone
+	broker.closeAll()
+	_, ok = <-other
+	assert.False(t, ok)
+	assert.Empty(t, broker.subscribers)
+}
+
+func TestHandleWalletEvents(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	walletUUID := pgtype.UUID{}
+	_ = walletUUID.Scan(walletID)
+
+	tests := []struct {
+		name            string
+		walletID        string
+		lastEventID     string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Stream events",
+			walletID:     walletID,
+			expectedCode: http.StatusOK,
+			expectedBody: "retry: 3000\n\n" +
+				"id: 5\nevent: balance.changed\ndata: {\"balance\":100}\n\n" +
+				"id: 6\nevent: wallet.status_changed\ndata: {\"status\":\"frozen\"}\n\n",
+		},
+		{
+			name:         "Resume after the last event",
+			walletID:     walletID,
+			lastEventID:  "5",
+			expectedCode: http.StatusOK,
+			expectedBody: "retry: 3000\n\n" +
+				"id: 6\nevent: wallet.status_changed\ndata: {\"status\":\"frozen\"}\n\n",
+		},
+		{
+			name:            "Invalid Last-Event-ID",
+			walletID:        walletID,
+			lastEventID:     "abc",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_header",
+			expectedDetail:  "Invalid Last-Event-ID header: expected an event ID",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        walletID,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        "fe6403a7-8b421-449-abe6-a8508199a0d4",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:                mockDB,
+				queries:           database.New(mockDB),
+				events:            newEventBroker(),
+				heartbeatInterval: time.Hour,
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/wallets/"+tc.walletID+"/events", nil)
+			req.SetPathValue("wallet_id", tc.walletID)
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				app.handleWalletEvents(w, req)
+			}()
+
+			if tc.expectedCode == http.StatusOK {
+				// Wait for the stream to subscribe, publish the events and end the stream
+				assert.Eventually(t, func() bool {
+					app.events.mu.Lock()
+					defer app.events.mu.Unlock()
+					return len(app.events.subscribers[walletUUID]) == 1
+				}, time.Second, time.Millisecond)
+				app.events.publish(walletEvent{Seq: 5, WalletID: walletUUID, Type: "balance.changed", Payload: json.RawMessage(`{"balance":100}`)})
+				app.events.publish(walletEvent{Seq: 6, WalletID: walletUUID, Type: "wallet.status_changed", Payload: json.RawMessage(`{"status":"frozen"}`)})
+				app.events.closeAll()
+			}
+			<-done
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+// TestWalletEventsNotify checks that a committed operation reaches the event broker
+// through LISTEN/NOTIFY. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestWalletEventsNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app, dbPool := newTestApp(t)
+	app.events = newEventBroker()
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	events, unsubscribe := app.events.subscribe(walletUUID)
+	defer unsubscribe()
+
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		app.events.run(ctx, dbPool)
+	}()
+	defer func() {
+		cancel()
+		<-listening
+	}()
+
+	// The broker may not be listening yet, so deposit until the first event arrives
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		assert.Equal(t, http.StatusOK, postOperation(t, app, walletUUID.String(), operations.Operation{OperationType: operations.Deposit, Amount: 10}))
+
+		select {
+		case event := <-events:
+			assert.Equal(t, "balance.changed", event.Type)
+			assert.Equal(t, walletUUID, event.WalletID)
+			assert.Positive(t, event.Seq)
+
+			var payload map[string]any
+			assert.NoError(t, json.Unmarshal(event.Payload, &payload))
+			assert.Equal(t, operations.Deposit, payload["operation"].(map[string]any)["operation_type"])
+			return
+		case <-ticker.C:
+		case <-deadline:
+			t.Fatal("No wallet event received")
+		}
+	}
+}
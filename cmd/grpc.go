@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/chtozamm/javacode-wallet/internal/walletpb"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcErrorDomain is the domain of the ErrorInfo details of gRPC errors
+const grpcErrorDomain = "wallet.v1"
+
+// walletServer implements the gRPC interface of the wallet API on top of the business logic
+// shared with the HTTP handlers. Its methods return problems and failures like the business logic,
+// and the interceptor of the server converts them to gRPC statuses.
+type walletServer struct {
+	walletpb.UnimplementedWalletServiceServer
+	app *application
+}
+
+// newGRPCServer returns a gRPC server with the wallet service, the health service and reflection
+func (app *application) newGRPCServer() *grpc.Server {
+	options := []grpc.ServerOption{grpc.ChainUnaryInterceptor(logCalls, app.grpcInterceptor, app.grpcIdempotency)}
+	if app.tracerProvider != nil {
+		options = append(options, grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithTracerProvider(app.tracerProvider),
+			otelgrpc.WithPropagators(propagator),
+		)))
+	}
+	srv := grpc.NewServer(options...)
+	walletpb.RegisterWalletServiceServer(srv, &walletServer{app: app})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(walletpb.WalletService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+	return srv
+}
+
+// grpcSecuredMethods are the methods that require basic credentials in the authorization metadata,
+// like the HTTP routes they mirror
+var grpcSecuredMethods = map[string]bool{
+	walletpb.WalletService_ListWallets_FullMethodName:  true,
+	walletpb.WalletService_DeleteWallet_FullMethodName: true,
+}
+
+// grpcInterceptor authenticates the methods that require it and converts the errors of the methods
+// to gRPC statuses, logging internal failures
+func (app *application) grpcInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if grpcSecuredMethods[info.FullMethod] && !app.grpcAuthenticated(ctx) {
+		return nil, grpcStatus(newProblem(problemUnauthorized, "", nil)).Err()
+	}
+
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	var f *failure
+	switch {
+	case errors.As(err, &f):
+		slog.ErrorContext(ctx, f.Msg, "error", errorSummary(f.Err))
+	case !errors.As(err, new(*problem)):
+		slog.ErrorContext(ctx, problemInternal.Title, "error", errorSummary(err))
+	}
+	return nil, grpcStatus(err).Err()
+}
+
+// grpcIdempotencyKey is the metadata key of the idempotency key of a gRPC call
+const grpcIdempotencyKey = "idempotency-key"
+
+// grpcIdempotentReplayedKey is the response header metadata key set when a call returns a stored result
+const grpcIdempotentReplayedKey = "idempotent-replayed"
+
+// grpcResponseContentType is the content type stored with the responses of gRPC calls, and
+// grpcProblemContentType the one stored with the problems they end with
+const (
+	grpcResponseContentType = "application/x-protobuf"
+	grpcProblemContentType  = "application/json"
+)
+
+// grpcIdempotentMethods are the methods that accept an idempotency key, with constructors of their responses
+// to decode the stored ones into
+var grpcIdempotentMethods = map[string]func() proto.Message{
+	walletpb.WalletService_ApplyOperation_FullMethodName: func() proto.Message { return new(walletpb.ApplyOperationResponse) },
+}
+
+// grpcIdempotency makes the methods that accept an idempotency key safe to retry, like idempotencyMiddleware
+// does for HTTP requests: a call with idempotency-key metadata is executed at most once, and retries with
+// the same key get the stored response or problem back. The keys are shared with the HTTP API.
+func (app *application) grpcIdempotency(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	newResponse, ok := grpcIdempotentMethods[info.FullMethod]
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(grpcIdempotencyKey)
+	if !ok || len(keys) == 0 || keys[0] == "" {
+		return handler(ctx, req)
+	}
+	key := keys[0]
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, newProblem(problemInvalidHeader, "idempotency-key must not be longer than 255 characters", nil)
+	}
+
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+	if err != nil {
+		return nil, internalError("Failed to marshal request", err)
+	}
+	fingerprint := requestFingerprint("GRPC", info.FullMethod, body)
+
+	// Claim the key; nothing is claimed if it is already taken and has not expired yet
+	lockedUntil, err := app.claimIdempotencyKey(ctx, key, fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.replayIdempotentCall(ctx, key, fingerprint, newResponse())
+	}
+	if err != nil {
+		return nil, internalError("Failed to create idempotency key", err)
+	}
+
+	// Run the call in a transaction
+	tx, err := app.db.Begin(ctx)
+	if err != nil {
+		app.releaseIdempotencyKey(ctx, key, lockedUntil)
+		return nil, internalError("Failed to begin idempotent request transaction", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	resp, callErr := handler(context.WithValue(ctx, requestTxKey{}, tx), req)
+
+	// Store the response, or the problem the call ended with; after internal errors nothing is stored
+	params := database.SaveIdempotencyKeyResponseParams{Key: key, LockedUntil: lockedUntil}
+	var p *problem
+	switch {
+	case callErr == nil:
+		params.StatusCode = pgtype.Int4{Int32: http.StatusOK, Valid: true}
+		params.ContentType = pgtype.Text{String: grpcResponseContentType, Valid: true}
+		params.ResponseBody, err = proto.Marshal(resp.(proto.Message))
+	case errors.As(callErr, &p) && p.Status < http.StatusInternalServerError:
+		params.StatusCode = pgtype.Int4{Int32: int32(p.Status), Valid: true} // #nosec G115 -- HTTP status codes fit in int32
+		params.ContentType = pgtype.Text{String: grpcProblemContentType, Valid: true}
+		params.ResponseBody, err = json.Marshal(p)
+	default:
+		// Roll back and release the key so the client can retry the call
+		_ = tx.Rollback(ctx)
+		app.releaseIdempotencyKey(ctx, key, lockedUntil)
+		return nil, callErr
+	}
+
+	// Commit the stored result together with the changes of the call
+	var saved int64
+	if err == nil {
+		saved, err = app.queries.WithTx(tx).SaveIdempotencyKeyResponse(ctx, params)
+	}
+	if err == nil && saved == 0 {
+		// The call took longer than its lease and a retry has taken over the key
+		return nil, newProblem(problemIdempotencyInProgress, "A request with this Idempotency-Key is still being processed", nil)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		app.releaseIdempotencyKey(ctx, key, lockedUntil)
+		return nil, internalError("Failed to save idempotent response", err)
+	}
+	return resp, callErr
+}
+
+// replayIdempotentCall returns the response or the problem stored for an already used idempotency key,
+// decoding a stored response into resp
+func (app *application) replayIdempotentCall(ctx context.Context, key, fingerprint string, resp proto.Message) (any, error) {
+	stored, err := app.queries.GetIdempotencyKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The key expired and was cleaned up in the meantime
+			return nil, newProblem(problemIdempotencyKeyExpired, "Idempotency-Key has expired, retry the request", nil)
+		}
+		return nil, internalError("Failed to get idempotency key", err)
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return nil, newProblem(problemIdempotencyKeyReused, "Idempotency-Key has already been used with a different request", nil)
+	}
+
+	if !stored.StatusCode.Valid {
+		return nil, newProblem(problemIdempotencyInProgress, "A request with this Idempotency-Key is still being processed", nil)
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcIdempotentReplayedKey, "true"))
+	if stored.ContentType.String == grpcProblemContentType {
+		p := new(problem)
+		decoder := json.NewDecoder(bytes.NewReader(stored.ResponseBody))
+		decoder.UseNumber()
+		if err := decoder.Decode(p); err != nil {
+			return nil, internalError("Failed to decode stored problem", err)
+		}
+		return nil, p
+	}
+	if err := proto.Unmarshal(stored.ResponseBody, resp); err != nil {
+		return nil, internalError("Failed to decode stored response", err)
+	}
+	return resp, nil
+}
+
+// grpcAuthenticated reports whether the authorization metadata of the call holds valid basic credentials
+func (app *application) grpcAuthenticated(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{Header: http.Header{"Authorization": md.Get("authorization")}}
+	username, password, ok := r.BasicAuth()
+	return ok && app.checkCredentials(username, password)
+}
+
+// grpcStatus converts an error of the business logic to a gRPC status. The status of a problem
+// carries an ErrorInfo detail with its code in upper case and its fields as metadata.
+func grpcStatus(err error) *status.Status {
+	var p *problem
+	var f *failure
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, err.Error())
+	case errors.As(err, &p):
+	case errors.As(err, &f):
+		p = newProblem(problemInternal, f.Msg, nil)
+	default:
+		p = newProblem(problemInternal, problemInternal.Title, nil)
+	}
+
+	info := &errdetails.ErrorInfo{Reason: strings.ToUpper(p.Code), Domain: grpcErrorDomain}
+	if len(p.Fields) > 0 {
+		info.Metadata = make(map[string]string, len(p.Fields))
+		for name, value := range p.Fields {
+			info.Metadata[name] = fmt.Sprint(value)
+		}
+	}
+
+	st := status.New(grpcCode(p), p.Error())
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// grpcCode returns the gRPC status code of a problem: mostly the one of its HTTP status,
+// and a more specific one where gRPC has it
+func grpcCode(p *problem) codes.Code {
+	switch p.Code {
+	case problemWithdrawalLimit.Code:
+		return codes.ResourceExhausted
+	case problemIdempotencyInProgress.Code:
+		return codes.Aborted
+	case problemBalanceOverflow.Code:
+		return codes.OutOfRange
+	}
+
+	switch p.Status {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusPaymentRequired, http.StatusConflict, http.StatusUnprocessableEntity:
+		// The request is valid, but the state of the wallet does not allow it
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
+
+func (s *walletServer) CreateWallet(ctx context.Context, req *walletpb.CreateWalletRequest) (*walletpb.CreateWalletResponse, error) {
+	walletID, err := s.app.createWallet(ctx, req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+	return &walletpb.CreateWalletResponse{WalletId: walletID.String()}, nil
+}
+
+func (s *walletServer) GetBalance(ctx context.Context, req *walletpb.GetBalanceRequest) (*walletpb.GetBalanceResponse, error) {
+	walletUUID, err := parseWalletID(req.GetWalletId())
+	if err != nil {
+		return nil, err
+	}
+
+	kind := enumName(balanceKinds, req.GetKind())
+	asOf := timestampFromProto(req.GetAsOf())
+	balance, err := s.app.getBalance(ctx, walletUUID, kind, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.app.describeBalance(ctx, walletUUID, kind, balance, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return &walletpb.GetBalanceResponse{
+		WalletId:       res.WalletID.String(),
+		Kind:           enumValue(balanceKinds, res.Kind),
+		Balance:        res.Balance,
+		BalanceDecimal: res.BalanceDecimal,
+		Currency:       res.Currency,
+		AsOf:           timestampToProto(res.AsOf),
+		UpdatedAt:      timestampToProto(res.UpdatedAt),
+	}, nil
+}
+
+func (s *walletServer) ApplyOperation(ctx context.Context, req *walletpb.ApplyOperationRequest) (*walletpb.ApplyOperationResponse, error) {
+	walletUUID, err := parseWalletID(req.GetWalletId())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.app.applyOperation(ctx, walletUUID, operations.Operation{
+		OperationType:  enumName(operationTypes, req.GetOperationType()),
+		Amount:         req.GetAmount(),
+		TargetWalletID: req.GetTargetWalletId(),
+		Currency:       req.GetCurrency(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &walletpb.ApplyOperationResponse{
+		OperationId:    res.OperationID.String(),
+		WalletId:       res.WalletID.String(),
+		OperationType:  enumValue(operationTypes, res.OperationType),
+		Amount:         res.Amount,
+		Balance:        res.Balance,
+		BalanceDecimal: res.BalanceDecimal,
+	}
+	if res.TransferID.Valid {
+		response.TransferId = res.TransferID.String()
+	}
+	return response, nil
+}
+
+func (s *walletServer) ListWallets(ctx context.Context, req *walletpb.ListWalletsRequest) (*walletpb.ListWalletsResponse, error) {
+	q := walletsQuery{
+		Limit:        req.GetPageSize(),
+		SortBy:       enumName(walletSorts, req.GetSort()),
+		Descending:   req.GetDescending(),
+		Status:       enumName(walletStatuses, req.GetStatus()),
+		CreatedFrom:  timestampFromProto(req.GetCreatedFrom()),
+		CreatedTo:    timestampFromProto(req.GetCreatedTo()),
+		Cursor:       req.GetPageToken(),
+		IncludeTotal: req.GetIncludeTotal(),
+	}
+	if req.MinBalance != nil {
+		q.MinBalance = pgtype.Int8{Int64: req.GetMinBalance(), Valid: true}
+	}
+	if req.MaxBalance != nil {
+		q.MaxBalance = pgtype.Int8{Int64: req.GetMaxBalance(), Valid: true}
+	}
+
+	page, err := s.app.listWallets(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &walletpb.ListWalletsResponse{
+		Wallets: make([]*walletpb.Wallet, 0, len(page.Wallets)),
+		Total:   page.Total,
+	}
+	for _, wallet := range page.Wallets {
+		res.Wallets = append(res.Wallets, walletToProto(wallet.Wallet))
+	}
+	if page.NextCursor != nil {
+		res.NextPageToken = *page.NextCursor
+	}
+	return res, nil
+}
+
+func (s *walletServer) DeleteWallet(ctx context.Context, req *walletpb.DeleteWalletRequest) (*walletpb.DeleteWalletResponse, error) {
+	walletUUID, err := parseWalletID(req.GetWalletId())
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.app.setWalletStatus(ctx, walletUUID, walletClosed)
+	if err != nil {
+		return nil, err
+	}
+	return &walletpb.DeleteWalletResponse{Wallet: walletToProto(wallet)}, nil
+}
+
+// parseWalletID parses the wallet ID of a gRPC request
+func parseWalletID(walletID string) (pgtype.UUID, error) {
+	walletUUID := pgtype.UUID{}
+	if err := walletUUID.Scan(walletID); err != nil {
+		return pgtype.UUID{}, newProblem(problemInvalidWalletID, "Invalid wallet ID", nil)
+	}
+	return walletUUID, nil
+}
+
+// walletToProto converts a wallet with its balance formatted in major currency units and its credit usage
+func walletToProto(wallet database.Wallet) *walletpb.Wallet {
+	return &walletpb.Wallet{
+		Id:             wallet.ID.String(),
+		Balance:        wallet.Balance,
+		BalanceDecimal: formatAmount(wallet.Balance, wallet.Currency),
+		Currency:       wallet.Currency,
+		Held:           wallet.Held,
+		Status:         enumValue(walletStatuses, wallet.Status),
+		CreditLimit:    wallet.CreditLimit,
+		CreditUsed:     operations.CreditUsed(wallet.Balance),
+		CreatedAt:      timestampToProto(wallet.CreatedAt),
+		UpdatedAt:      timestampToProto(wallet.UpdatedAt),
+	}
+}
+
+// Names of the gRPC enum values in the HTTP API and the business logic
+var (
+	operationTypes = map[walletpb.OperationType]string{
+		walletpb.OperationType_OPERATION_TYPE_DEPOSIT:  operations.Deposit,
+		walletpb.OperationType_OPERATION_TYPE_WITHDRAW: operations.Withdraw,
+		walletpb.OperationType_OPERATION_TYPE_TRANSFER: operations.Transfer,
+	}
+	balanceKinds = map[walletpb.BalanceKind]string{
+		walletpb.BalanceKind_BALANCE_KIND_LEDGER:    "ledger",
+		walletpb.BalanceKind_BALANCE_KIND_AVAILABLE: "available",
+	}
+	walletStatuses = map[walletpb.WalletStatus]string{
+		walletpb.WalletStatus_WALLET_STATUS_ACTIVE: walletActive,
+		walletpb.WalletStatus_WALLET_STATUS_FROZEN: walletFrozen,
+		walletpb.WalletStatus_WALLET_STATUS_CLOSED: walletClosed,
+	}
+	walletSorts = map[walletpb.WalletSort]string{
+		walletpb.WalletSort_WALLET_SORT_CREATED_AT: "created_at",
+		walletpb.WalletSort_WALLET_SORT_UPDATED_AT: "updated_at",
+		walletpb.WalletSort_WALLET_SORT_BALANCE:    "balance",
+	}
+)
+
+// enumName returns the name of an enum value: empty for the unspecified value, so that the business logic
+// applies its default, and the number of an unknown value, so that the business logic rejects it
+func enumName[E interface {
+	comparable
+	String() string
+}](names map[E]string, value E) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	var unspecified E
+	if value == unspecified {
+		return ""
+	}
+	return value.String()
+}
+
+// enumValue returns the enum value with the given name, or the unspecified value if there is none
+func enumValue[E comparable](names map[E]string, name string) E {
+	for value, n := range names {
+		if n == name {
+			return value
+		}
+	}
+	var unspecified E
+	return unspecified
+}
+
+// timestampFromProto converts a protobuf timestamp to a UTC timestamp, which is null if the timestamp is not set
+func timestampFromProto(t *timestamppb.Timestamp) pgtype.Timestamp {
+	if t == nil {
+		return pgtype.Timestamp{}
+	}
+	return pgtype.Timestamp{Time: t.AsTime(), Valid: true}
+}
+
+// timestampToProto converts a timestamp to a protobuf timestamp, which is not set if the timestamp is null
+func timestampToProto(t pgtype.Timestamp) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/walletpb"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// newGRPCClient serves the gRPC server of the application in memory and returns a client connected to it
+func newGRPCClient(t *testing.T, app *application) walletpb.WalletServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := app.newGRPCServer()
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+func TestGRPCWalletService(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	walletUUID := pgtype.UUID{}
+	_ = walletUUID.Scan(validUUID)
+
+	// withAuth adds basic credentials to the metadata of a call
+	withAuth := func(ctx context.Context, username, password string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+
+	tests := []struct {
+		name           string
+		mockBalance    int64
+		mockRow        []any
+		mockError      error
+		call           func(ctx context.Context, client walletpb.WalletServiceClient) error
+		expectedCode   codes.Code
+		expectedReason string
+		expectedDetail string
+	}{
+		{
+			name:    "Create wallet",
+			mockRow: []any{walletUUID},
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				res, err := client.CreateWallet(ctx, &walletpb.CreateWalletRequest{Currency: "USD"})
+				if err == nil && res.GetWalletId() != validUUID {
+					return errors.New("unexpected wallet ID " + res.GetWalletId())
+				}
+				return err
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "Create wallet in unsupported currency",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.CreateWallet(ctx, &walletpb.CreateWalletRequest{Currency: "ABC"})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: "UNSUPPORTED_CURRENCY",
+			expectedDetail: "Unsupported currency: expected an ISO 4217 currency code",
+		},
+		{
+			name:        "Get balance",
+			mockBalance: 100,
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				res, err := client.GetBalance(ctx, &walletpb.GetBalanceRequest{WalletId: validUUID})
+				if err == nil && (res.GetBalance() != 100 || res.GetKind() != walletpb.BalanceKind_BALANCE_KIND_LEDGER) {
+					return errors.New("unexpected balance " + res.String())
+				}
+				return err
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "Get balance of invalid wallet ID",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.GetBalance(ctx, &walletpb.GetBalanceRequest{WalletId: "not-a-wallet"})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: "INVALID_WALLET_ID",
+			expectedDetail: "Invalid wallet ID",
+		},
+		{
+			name:      "Get balance of missing wallet",
+			mockError: sql.ErrNoRows,
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.GetBalance(ctx, &walletpb.GetBalanceRequest{WalletId: validUUID})
+				return err
+			},
+			expectedCode:   codes.NotFound,
+			expectedReason: "WALLET_NOT_FOUND",
+			expectedDetail: "Wallet not found",
+		},
+		{
+			name:    "Withdraw with insufficient funds",
+			mockRow: []any{int64(50), "RUB", int64(0), walletActive},
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ApplyOperation(ctx, &walletpb.ApplyOperationRequest{
+					WalletId:      validUUID,
+					OperationType: walletpb.OperationType_OPERATION_TYPE_WITHDRAW,
+					Amount:        100,
+				})
+				return err
+			},
+			expectedCode:   codes.FailedPrecondition,
+			expectedReason: "INSUFFICIENT_FUNDS",
+			expectedDetail: "Insufficient funds to withdraw: balance 50, trying to withdraw 100",
+		},
+		{
+			name: "Unspecified operation type",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ApplyOperation(ctx, &walletpb.ApplyOperationRequest{WalletId: validUUID, Amount: 100})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: "INVALID_OPERATION_TYPE",
+			expectedDetail: "Unsupported operation type: expected operation_type to be \"deposit\", \"withdraw\" or \"transfer\"",
+		},
+		{
+			name:    "Deposit to closed wallet",
+			mockRow: []any{int64(0), "RUB", int64(0), walletClosed},
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ApplyOperation(ctx, &walletpb.ApplyOperationRequest{
+					WalletId:      validUUID,
+					OperationType: walletpb.OperationType_OPERATION_TYPE_DEPOSIT,
+					Amount:        100,
+				})
+				return err
+			},
+			expectedCode:   codes.FailedPrecondition,
+			expectedReason: "WALLET_CLOSED",
+			expectedDetail: "Wallet is closed",
+		},
+		{
+			name: "List wallets",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ListWallets(withAuth(ctx, "admin", "secret"), &walletpb.ListWalletsRequest{})
+				return err
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "List wallets without credentials",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ListWallets(ctx, &walletpb.ListWalletsRequest{})
+				return err
+			},
+			expectedCode:   codes.Unauthenticated,
+			expectedReason: "UNAUTHORIZED",
+			expectedDetail: "Unauthorized",
+		},
+		{
+			name: "List wallets with wrong credentials",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ListWallets(withAuth(ctx, "admin", "wrong"), &walletpb.ListWalletsRequest{})
+				return err
+			},
+			expectedCode:   codes.Unauthenticated,
+			expectedReason: "UNAUTHORIZED",
+			expectedDetail: "Unauthorized",
+		},
+		{
+			name: "List wallets with invalid page size",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.ListWallets(withAuth(ctx, "admin", "secret"), &walletpb.ListWalletsRequest{PageSize: 2000})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: "INVALID_QUERY_PARAMETER",
+			expectedDetail: "Invalid query parameter: limit must be an integer between 1 and 1000",
+		},
+		{
+			name:    "Delete closed wallet",
+			mockRow: []any{int64(0), "RUB", int64(0), walletClosed},
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.DeleteWallet(withAuth(ctx, "admin", "secret"), &walletpb.DeleteWalletRequest{WalletId: validUUID})
+				return err
+			},
+			expectedCode:   codes.FailedPrecondition,
+			expectedReason: "WALLET_STATUS_UNCHANGED",
+			expectedDetail: "Wallet is already closed",
+		},
+		{
+			name: "Delete wallet without credentials",
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.DeleteWallet(ctx, &walletpb.DeleteWalletRequest{WalletId: validUUID})
+				return err
+			},
+			expectedCode:   codes.Unauthenticated,
+			expectedReason: "UNAUTHORIZED",
+			expectedDetail: "Unauthorized",
+		},
+		{
+			name:      "Database failure",
+			mockError: errors.New("connection refused"),
+			call: func(ctx context.Context, client walletpb.WalletServiceClient) error {
+				_, err := client.DeleteWallet(withAuth(ctx, "admin", "secret"), &walletpb.DeleteWalletRequest{WalletId: validUUID})
+				return err
+			},
+			expectedCode:   codes.Internal,
+			expectedReason: "INTERNAL_ERROR",
+			expectedDetail: "Failed to get wallet",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Balance: tc.mockBalance,
+			Row:     tc.mockRow,
+			Err:     tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries
+			app := &application{
+				db:              mockDB,
+				queries:         database.New(mockDB),
+				defaultCurrency: "RUB",
+			}
+			app.auth.username = "admin"
+			app.auth.password = "secret"
+
+			// Call the method
+			err := tc.call(t.Context(), newGRPCClient(t, app))
+
+			// Check the status and its error details
+			st := status.Convert(err)
+			assert.Equal(t, tc.expectedCode, st.Code(), st.Message())
+			if tc.expectedCode == codes.OK {
+				return
+			}
+			assert.Equal(t, tc.expectedDetail, st.Message())
+			require.Len(t, st.Details(), 1)
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(t, ok)
+			assert.Equal(t, tc.expectedReason, info.GetReason())
+			assert.Equal(t, grpcErrorDomain, info.GetDomain())
+		})
+	}
+}
+
+func TestGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedCode     codes.Code
+		expectedMessage  string
+		expectedMetadata map[string]string
+	}{
+		{
+			name:             "Insufficient funds",
+			err:              insufficientFunds("withdraw", 50, 100),
+			expectedCode:     codes.FailedPrecondition,
+			expectedMessage:  "Insufficient funds to withdraw: balance 50, trying to withdraw 100",
+			expectedMetadata: map[string]string{"available": "50", "amount": "100"},
+		},
+		{
+			name:            "Withdrawal limit",
+			err:             newProblem(problemWithdrawalLimit, "Daily withdrawal limit exceeded", nil),
+			expectedCode:    codes.ResourceExhausted,
+			expectedMessage: "Daily withdrawal limit exceeded",
+		},
+		{
+			name:             "Balance overflow",
+			err:              balanceOverflow(1, 2),
+			expectedCode:     codes.OutOfRange,
+			expectedMessage:  "Operation would overflow wallet balance: balance 1, amount 2",
+			expectedMetadata: map[string]string{"balance": "1", "amount": "2"},
+		},
+		{
+			name:            "Target wallet not found",
+			err:             newProblem(problemTargetWalletNotFound, "Target wallet not found", nil),
+			expectedCode:    codes.NotFound,
+			expectedMessage: "Target wallet not found",
+		},
+		{
+			name:            "Failure hides its cause",
+			err:             internalError("Failed to record operation", errors.New("duplicate key value")),
+			expectedCode:    codes.Internal,
+			expectedMessage: "Failed to record operation",
+		},
+		{
+			name:            "Canceled call",
+			err:             internalError("Failed to get wallet balance", context.Canceled),
+			expectedCode:    codes.Canceled,
+			expectedMessage: "Failed to get wallet balance: context canceled",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st := grpcStatus(tc.err)
+			assert.Equal(t, tc.expectedCode, st.Code())
+			assert.Equal(t, tc.expectedMessage, st.Message())
+
+			if tc.expectedMetadata != nil {
+				require.Len(t, st.Details(), 1)
+				info := st.Details()[0].(*errdetails.ErrorInfo)
+				assert.Equal(t, tc.expectedMetadata, info.GetMetadata())
+			}
+		})
+	}
+}
+
+func TestGRPCIdempotency(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: walletpb.WalletService_ApplyOperation_FullMethodName}
+	req := &walletpb.ApplyOperationRequest{
+		WalletId:      "fe6403a7-8b42-4449-abe6-a8508199a0d4",
+		OperationType: walletpb.OperationType_OPERATION_TYPE_DEPOSIT,
+		Amount:        100,
+	}
+
+	tests := []struct {
+		name          string
+		key           string
+		mockError     error
+		rowsAffected  int64
+		handlerError  error
+		expectedCode  codes.Code
+		expectedCalls int
+	}{
+		{
+			name:          "No idempotency key",
+			expectedCalls: 1,
+		},
+		{
+			name:          "Idempotency key is too long",
+			key:           strings.Repeat("k", maxIdempotencyKeyLength+1),
+			expectedCode:  codes.InvalidArgument,
+			expectedCalls: 0,
+		},
+		{
+			name:          "Response is stored",
+			key:           "key",
+			rowsAffected:  1,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Problem is stored",
+			key:           "key",
+			rowsAffected:  1,
+			handlerError:  newProblem(problemInsufficientFunds, "", nil),
+			expectedCode:  codes.FailedPrecondition,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Key taken over by a retry",
+			key:           "key",
+			expectedCode:  codes.Aborted,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Internal error is not stored",
+			key:           "key",
+			handlerError:  internalError("Failed to record operation", errors.New("connection refused")),
+			expectedCode:  codes.Internal,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Failed to claim key",
+			key:           "key",
+			mockError:     errors.New("connection refused"),
+			expectedCode:  codes.Internal,
+			expectedCalls: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError, RowsAffected: tc.rowsAffected}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			calls := 0
+			handler := func(ctx context.Context, req any) (any, error) {
+				calls++
+				if tc.handlerError != nil {
+					return nil, tc.handlerError
+				}
+				return &walletpb.ApplyOperationResponse{WalletId: req.(*walletpb.ApplyOperationRequest).GetWalletId()}, nil
+			}
+
+			ctx := t.Context()
+			if tc.key != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(grpcIdempotencyKey, tc.key))
+			}
+			resp, err := app.grpcIdempotency(ctx, req, info, handler)
+
+			assert.Equal(t, tc.expectedCalls, calls)
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				assert.Equal(t, req.GetWalletId(), resp.(*walletpb.ApplyOperationResponse).GetWalletId())
+				return
+			}
+			assert.Equal(t, tc.expectedCode, grpcStatus(err).Code())
+		})
+	}
+}
+
+// TestGRPCApplyOperationIdempotency retries a deposit and a rejected withdrawal with their idempotency keys
+// and checks that each is executed once. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestGRPCApplyOperationIdempotency(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+	app.idempotencyKeyTTL = time.Hour
+	client := newGRPCClient(t, app)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	require.NoError(t, err)
+	walletID := walletUUID.String()
+
+	// apply sends an operation with the idempotency key and returns the response,
+	// whether it was replayed and the error
+	apply := func(key string, operationType walletpb.OperationType, amount int64) (*walletpb.ApplyOperationResponse, bool, error) {
+		var header metadata.MD
+		res, err := client.ApplyOperation(metadata.AppendToOutgoingContext(ctx, grpcIdempotencyKey, key), &walletpb.ApplyOperationRequest{
+			WalletId:      walletID,
+			OperationType: operationType,
+			Amount:        amount,
+		}, grpc.Header(&header))
+		return res, len(header.Get(grpcIdempotentReplayedKey)) > 0, err
+	}
+
+	// A retried deposit returns the stored response and is applied once
+	depositKey := newUUID().String()
+	first, replayed, err := apply(depositKey, walletpb.OperationType_OPERATION_TYPE_DEPOSIT, 100)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	retry, replayed, err := apply(depositKey, walletpb.OperationType_OPERATION_TYPE_DEPOSIT, 100)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, first.GetOperationId(), retry.GetOperationId())
+	assert.Equal(t, int64(100), retry.GetBalance())
+
+	// The key cannot be used for another operation
+	_, _, err = apply(depositKey, walletpb.OperationType_OPERATION_TYPE_DEPOSIT, 200)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "Idempotency-Key has already been used with a different request", status.Convert(err).Message())
+
+	// A rejected withdrawal is replayed with its problem, even once the funds are there
+	withdrawalKey := newUUID().String()
+	_, _, withdrawalErr := apply(withdrawalKey, walletpb.OperationType_OPERATION_TYPE_WITHDRAW, 150)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(withdrawalErr))
+	_, err = client.ApplyOperation(ctx, &walletpb.ApplyOperationRequest{
+		WalletId:      walletID,
+		OperationType: walletpb.OperationType_OPERATION_TYPE_DEPOSIT,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+	_, replayed, err = apply(withdrawalKey, walletpb.OperationType_OPERATION_TYPE_WITHDRAW, 150)
+	assert.True(t, replayed)
+	assert.True(t, proto.Equal(status.Convert(withdrawalErr).Proto(), status.Convert(err).Proto()))
+
+	balance, err := app.queries.GetBalance(ctx, walletUUID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), balance)
+	assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+}
@@ -1,23 +1,22 @@
 package main
 
 import (
-	"log"
 	"net/http"
 )
 
+// healthResponse is the JSON body of a health check response
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
 func (app *application) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Check the database connection
 	err := app.db.Ping(r.Context())
 	if err != nil {
-		log.Printf("Database health check failed: %v\n", err)
-		http.Error(w, "Database health check failed", http.StatusInternalServerError)
+		serverError(w, r, "Database health check failed", err)
 		return
 	}
 
 	// Write the response
-	_, err = w.Write([]byte("OK\n"))
-	if err != nil {
-		log.Printf("Failed to write response: %v\n", err)
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
-	}
+	writeNegotiated(w, r, http.StatusOK, healthResponse{Status: "ok"}, "OK")
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/holds"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// maxHoldTTL limits how long a hold can reserve funds
+	maxHoldTTL = 30 * 24 * time.Hour
+	// holdExpiryBatchSize limits the number of holds expired in one transaction
+	holdExpiryBatchSize = 100
+)
+
+func (app *application) handleCreateHold(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Decode JSON from request to struct
+	var req holds.Request
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	// Check amount
+	if req.Amount <= 0 {
+		writeProblem(w, r, problemInvalidAmount, "Amount must be greater than zero", nil)
+		return
+	}
+
+	// Check hold lifetime, falling back to the default one
+	ttl := app.holdTTL
+	if req.TTLSeconds != 0 {
+		if req.TTLSeconds < 0 || req.TTLSeconds > int64(maxHoldTTL/time.Second) {
+			writeProblem(w, r, problemInvalidTTL, fmt.Sprintf("TTL must be between 1 and %d seconds", int64(maxHoldTTL/time.Second)), nil)
+			return
+		}
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	// Start transaction
+	tx, err := app.begin(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to begin hold transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Get current wallet balance and lock the wallet row
+	wallet, err := queriesWithTx.GetBalanceForUpdate(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	// Check that the wallet status allows reserving funds
+	if p := walletStatusProblem(wallet.Status, true); p != nil {
+		p.write(w, r)
+		return
+	}
+
+	// Check currency if it is specified in the request
+	if req.Currency != "" && !strings.EqualFold(req.Currency, wallet.Currency) {
+		currencyMismatch(wallet.Currency, req.Currency, "hold").write(w, r)
+		return
+	}
+
+	// Check available balance, including the credit limit
+	available := operations.Available(wallet.Balance, wallet.Held, wallet.CreditLimit)
+	if available < req.Amount {
+		insufficientFunds("hold", available, req.Amount).write(w, r)
+		return
+	}
+
+	// Insert hold in database and reserve the funds
+	hold, err := queriesWithTx.CreateHold(r.Context(), database.CreateHoldParams{
+		WalletID: walletUUID,
+		Amount:   req.Amount,
+		Ttl:      pgtype.Interval{Microseconds: ttl.Microseconds(), Valid: true},
+	})
+	if err != nil {
+		serverError(w, r, "Failed to create hold", err)
+		return
+	}
+
+	err = queriesWithTx.UpdateWalletHeld(r.Context(), database.UpdateWalletHeldParams{
+		ID:    walletUUID,
+		Delta: req.Amount,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to update held funds", err)
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to commit hold transaction", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, hold)
+}
+
+func (app *application) handleGetHold(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet and hold UUIDs from path
+	walletUUID, holdUUID, ok := parseHoldPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Get the hold
+	hold, err := app.queries.GetHold(r.Context(), database.GetHoldParams{ID: holdUUID, WalletID: walletUUID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemHoldNotFound, "Hold not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get hold", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hold)
+}
+
+func (app *application) handleCaptureHold(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet and hold UUIDs from path
+	walletUUID, holdUUID, ok := parseHoldPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Decode JSON from request to struct; the body is optional
+	var req holds.CaptureRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+	if req.Amount < 0 {
+		writeProblem(w, r, problemInvalidAmount, "Amount must be greater than zero", nil)
+		return
+	}
+
+	app.settleHold(w, r, walletUUID, holdUUID, holds.Captured, req.Amount)
+}
+
+func (app *application) handleVoidHold(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet and hold UUIDs from path
+	walletUUID, holdUUID, ok := parseHoldPath(w, r)
+	if !ok {
+		return
+	}
+
+	app.settleHold(w, r, walletUUID, holdUUID, holds.Voided, 0)
+}
+
+// settleHold releases the funds reserved by an active hold. Capturing also withdraws
+// the captured amount (the whole hold if amount is zero) from the wallet balance.
+func (app *application) settleHold(w http.ResponseWriter, r *http.Request, walletUUID, holdUUID pgtype.UUID, status string, amount int64) {
+	// Start transaction
+	tx, err := app.begin(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to begin hold transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock the hold first and the wallet second, in the same order as the expiry job does
+	hold, err := queriesWithTx.GetHoldForUpdate(r.Context(), database.GetHoldForUpdateParams{ID: holdUUID, WalletID: walletUUID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemHoldNotFound, "Hold not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get hold", err)
+		return
+	}
+
+	if hold.Status != holds.Active {
+		writeProblem(w, r, problemHoldNotActive, "Hold is already "+hold.Status, map[string]any{"hold_status": hold.Status})
+		return
+	}
+	if hold.Expired {
+		writeProblem(w, r, problemHoldNotActive, "Hold has expired", map[string]any{"hold_status": holds.Expired})
+		return
+	}
+
+	if status == holds.Captured && amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		writeProblem(w, r, problemCaptureExceedsHold, fmt.Sprintf("Capture amount exceeds held amount: held %d, trying to capture %d", hold.Amount, amount),
+			map[string]any{"held": hold.Amount, "amount": amount})
+		return
+	}
+
+	wallet, err := queriesWithTx.GetBalanceForUpdate(r.Context(), walletUUID)
+	if err != nil {
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	// Withdraw the captured amount; a frozen wallet can still void its holds, but not capture them
+	if amount > 0 {
+		if p := walletStatusProblem(wallet.Status, true); p != nil {
+			p.write(w, r)
+			return
+		}
+
+		_, err = operations.Debit(wallet.Balance, amount)
+		if err != nil {
+			balanceOverflow(wallet.Balance, amount).write(w, r)
+			return
+		}
+
+		p, err := app.checkWithdrawalLimits(r.Context(), queriesWithTx, walletUUID, amount)
+		if err != nil {
+			serverError(w, r, "Failed to check withdrawal limits", err)
+			return
+		}
+		if p != nil {
+			p.write(w, r)
+			return
+		}
+
+		_, err = app.recordOperation(r.Context(), queriesWithTx, database.AddOperationParams{
+			WalletID:      walletUUID,
+			OperationType: operations.Withdraw,
+			Amount:        amount,
+			HoldID:        holdUUID,
+		}, wallet.Currency)
+		if err != nil {
+			serverError(w, r, "Failed to record operation", err)
+			return
+		}
+	}
+
+	// Release the reserved funds; the uncaptured part of a partial capture becomes available again
+	err = queriesWithTx.UpdateWalletHeld(r.Context(), database.UpdateWalletHeldParams{
+		ID:    walletUUID,
+		Delta: -hold.Amount,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to update held funds", err)
+		return
+	}
+
+	settled, err := queriesWithTx.UpdateHold(r.Context(), database.UpdateHoldParams{
+		ID:             holdUUID,
+		Status:         status,
+		CapturedAmount: amount,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to update hold", err)
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to commit hold transaction", err)
+		return
+	}
+	if amount > 0 {
+		app.metrics.observeOperation(operations.Withdraw, amount)
+	}
+
+	writeJSON(w, http.StatusOK, settled)
+}
+
+// parseHoldPath reads wallet and hold UUIDs from path, writing an error response if they are invalid
+func parseHoldPath(w http.ResponseWriter, r *http.Request) (walletUUID, holdUUID pgtype.UUID, ok bool) {
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return walletUUID, holdUUID, false
+	}
+
+	err = holdUUID.Scan(r.PathValue("hold_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidID, "Invalid hold ID", nil)
+		return walletUUID, holdUUID, false
+	}
+
+	return walletUUID, holdUUID, true
+}
+
+// runHoldExpiry periodically expires holds past their TTL and releases their funds until ctx is canceled
+func (app *application) runHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				expired, err := app.expireHolds(ctx)
+				if err != nil {
+					slog.Error("Failed to expire holds", "error", err)
+					break
+				}
+				if expired > 0 {
+					slog.Info("Expired holds", "count", expired)
+				}
+				if expired < holdExpiryBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// expireHolds expires one batch of holds and returns the number of expired holds
+func (app *application) expireHolds(ctx context.Context) (int, error) {
+	tx, err := app.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	queriesWithTx := app.queries.WithTx(tx)
+
+	expired, err := queriesWithTx.ExpireHolds(ctx, holdExpiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sum released funds per wallet and update wallets in the order of their IDs to avoid deadlocks
+	released := make(map[pgtype.UUID]int64)
+	for _, hold := range expired {
+		released[hold.WalletID] += hold.Amount
+	}
+	walletUUIDs := make([]pgtype.UUID, 0, len(released))
+	for walletUUID := range released {
+		walletUUIDs = append(walletUUIDs, walletUUID)
+	}
+	slices.SortFunc(walletUUIDs, func(a, b pgtype.UUID) int {
+		return slices.Compare(a.Bytes[:], b.Bytes[:])
+	})
+
+	for _, walletUUID := range walletUUIDs {
+		err = queriesWithTx.UpdateWalletHeld(ctx, database.UpdateWalletHeldParams{
+			ID:    walletUUID,
+			Delta: -released[walletUUID],
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), tx.Commit(ctx)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/holds"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateHold(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name            string
+		walletID        string
+		body            string
+		mockBalance     int64
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Valid hold",
+			walletID:     validUUID,
+			body:         `{"amount":50,"ttl_seconds":600}`,
+			mockBalance:  100,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Insufficient funds",
+			walletID:        validUUID,
+			body:            `{"amount":150}`,
+			mockBalance:     100,
+			expectedCode:    http.StatusPaymentRequired,
+			expectedProblem: "insufficient_funds",
+			expectedDetail:  "Insufficient funds to hold: balance 100, trying to hold 150",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			body:            `{"amount":50}`,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			body:            `{"amount":50}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+		{
+			name:            "Invalid amount",
+			walletID:        validUUID,
+			body:            `{"amount":0}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_amount",
+			expectedDetail:  "Amount must be greater than zero",
+		},
+		{
+			name:            "Invalid TTL",
+			walletID:        validUUID,
+			body:            `{"amount":50,"ttl_seconds":-1}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_ttl",
+			expectedDetail:  "TTL must be between 1 and 2592000 seconds",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Balance: tc.mockBalance,
+			Err:     tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			// Create and configure a new HTTP request
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+tc.walletID+"/holds", strings.NewReader(tc.body))
+			req.SetPathValue("wallet_id", tc.walletID)
+
+			// Create a new response recorder
+			w := httptest.NewRecorder()
+
+			// Call the handler
+			app.handleCreateHold(w, req)
+
+			// Check the response status code
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestHandleCaptureHold(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	holdID := "30504a06-1d08-4390-92ef-c03c253d702b"
+
+	// holdRow returns the columns of a hold locked by GetHoldForUpdate
+	holdRow := func(status string, expired bool) []any {
+		return []any{nil, nil, int64(100), int64(0), status, nil, nil, nil, expired}
+	}
+
+	tests := []struct {
+		name            string
+		holdID          string
+		body            string
+		mockRow         []any
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Full capture",
+			holdID:       holdID,
+			body:         "",
+			mockRow:      holdRow(holds.Active, false),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Partial capture",
+			holdID:       holdID,
+			body:         `{"amount":40}`,
+			mockRow:      holdRow(holds.Active, false),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Capture more than held",
+			holdID:          holdID,
+			body:            `{"amount":150}`,
+			mockRow:         holdRow(holds.Active, false),
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "capture_exceeds_hold",
+			expectedDetail:  "Capture amount exceeds held amount: held 100, trying to capture 150",
+		},
+		{
+			name:            "Hold already voided",
+			holdID:          holdID,
+			mockRow:         holdRow(holds.Voided, false),
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "hold_not_active",
+			expectedDetail:  "Hold is already voided",
+		},
+		{
+			name:            "Hold expired",
+			holdID:          holdID,
+			mockRow:         holdRow(holds.Active, true),
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "hold_not_active",
+			expectedDetail:  "Hold has expired",
+		},
+		{
+			name:            "Hold not found",
+			holdID:          holdID,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "hold_not_found",
+			expectedDetail:  "Hold not found",
+		},
+		{
+			name:            "Invalid hold ID",
+			holdID:          "not-a-hold",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_id",
+			expectedDetail:  "Invalid hold ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Row: tc.mockRow,
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			// Create and configure a new HTTP request
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/holds/"+tc.holdID+"/capture", strings.NewReader(tc.body))
+			req.SetPathValue("wallet_id", walletID)
+			req.SetPathValue("hold_id", tc.holdID)
+
+			// Create a new response recorder
+			w := httptest.NewRecorder()
+
+			// Call the handler
+			app.handleCaptureHold(w, req)
+
+			// Check the response status code
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+// TestVoidHoldRetry checks that a void retried with its Idempotency-Key gets the original response
+// instead of a conflict. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestVoidHoldRetry(t *testing.T) {
+	ctx := context.Background()
+	app, _ := newTestApp(t)
+	app.idempotencyKeyTTL = time.Hour
+	handler := app.router()
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+	if code := postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: 100}); code != http.StatusOK {
+		t.Fatalf("Failed to deposit: status code %d", code)
+	}
+
+	// Hold part of the balance
+	req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/holds", strings.NewReader(`{"amount":40}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create hold: status code %d", w.Code)
+	}
+	var hold database.Hold
+	if err := json.Unmarshal(w.Body.Bytes(), &hold); err != nil {
+		t.Fatalf("Failed to decode hold: %v", err)
+	}
+
+	// Void it twice with the same key
+	key := newUUID().String()
+	responses := make([]*httptest.ResponseRecorder, 2)
+	for i := range responses {
+		req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/holds/"+hold.ID.String()+"/void", nil)
+		req.Header.Set("Idempotency-Key", key)
+		responses[i] = httptest.NewRecorder()
+		handler.ServeHTTP(responses[i], req)
+	}
+
+	assert.Equal(t, http.StatusOK, responses[0].Code)
+	assert.Equal(t, http.StatusOK, responses[1].Code)
+	assert.Equal(t, "true", responses[1].Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, responses[0].Body.String(), responses[1].Body.String())
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxIdempotencyKeyLength limits the size of client-supplied keys stored in the database
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLease is how long a request holds its idempotency key. A retry takes over the key of a request
+// that has not finished by then, for example because the server stopped while handling it. The changes of
+// a request commit together with its response, so a request that lost its key is rolled back rather than
+// applied twice.
+const idempotencyLease = time.Minute
+
+// idempotencyMiddleware makes a handler safe to retry: a request with an Idempotency-Key header
+// is executed at most once, and retries with the same key get the stored response back.
+func (app *application) idempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(w, r, problemInvalidHeader, "Idempotency-Key must not be longer than 255 characters", nil)
+			return
+		}
+
+		// Read the request body to fingerprint it and put it back for the handler
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, problemInvalidRequestBody, "Failed to read request body", nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r.Method, r.URL.Path, body)
+
+		// Claim the key; nothing is claimed if it is already taken and has not expired yet
+		lockedUntil, err := app.claimIdempotencyKey(r.Context(), key, fingerprint)
+		if errors.Is(err, sql.ErrNoRows) {
+			app.replayIdempotentResponse(w, r, key, fingerprint)
+			return
+		}
+		if err != nil {
+			serverError(w, r, "Failed to create idempotency key", err)
+			return
+		}
+
+		// Run the handler in a transaction, buffering the response until it is stored
+		tx, err := app.db.Begin(r.Context())
+		if err != nil {
+			app.releaseIdempotencyKey(r.Context(), key, lockedUntil)
+			serverError(w, r, "Failed to begin idempotent request transaction", err)
+			return
+		}
+		defer tx.Rollback(context.WithoutCancel(r.Context()))
+
+		rec := newResponseRecorder()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestTxKey{}, tx)))
+
+		// Roll back after server errors and release the key so the client can retry the request
+		if rec.status >= http.StatusInternalServerError {
+			_ = tx.Rollback(r.Context())
+			app.releaseIdempotencyKey(r.Context(), key, lockedUntil)
+			rec.writeTo(r.Context(), w)
+			return
+		}
+
+		// Store the response and commit it together with the changes of the request
+		saved, err := app.queries.WithTx(tx).SaveIdempotencyKeyResponse(r.Context(), database.SaveIdempotencyKeyResponseParams{
+			Key:          key,
+			LockedUntil:  lockedUntil,
+			StatusCode:   pgtype.Int4{Int32: int32(rec.status), Valid: true}, // #nosec G115 -- HTTP status codes fit in int32
+			ContentType:  pgtype.Text{String: rec.Header().Get("Content-Type"), Valid: true},
+			ResponseBody: rec.body.Bytes(),
+		})
+		if err == nil && saved == 0 {
+			// The request took longer than its lease and a retry has taken over the key
+			writeProblem(w, r, problemIdempotencyInProgress, "A request with this Idempotency-Key is still being processed", nil)
+			return
+		}
+		if err == nil {
+			err = tx.Commit(r.Context())
+		}
+		if err != nil {
+			_ = tx.Rollback(r.Context())
+			app.releaseIdempotencyKey(r.Context(), key, lockedUntil)
+			serverError(w, r, "Failed to save idempotent response", err)
+			return
+		}
+		rec.writeTo(r.Context(), w)
+	})
+}
+
+// claimIdempotencyKey claims the key for a request and returns the end of its lease, which identifies the claim.
+// It returns sql.ErrNoRows if the key is already taken.
+func (app *application) claimIdempotencyKey(ctx context.Context, key, fingerprint string) (pgtype.Timestamp, error) {
+	return app.queries.CreateIdempotencyKey(ctx, database.CreateIdempotencyKeyParams{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Ttl:         pgtype.Interval{Microseconds: app.idempotencyKeyTTL.Microseconds(), Valid: true},
+		Lease:       pgtype.Interval{Microseconds: idempotencyLease.Microseconds(), Valid: true},
+	})
+}
+
+// releaseIdempotencyKey deletes the claim of a request that failed. If that fails too, the key
+// is released when its lease ends.
+func (app *application) releaseIdempotencyKey(ctx context.Context, key string, lockedUntil pgtype.Timestamp) {
+	err := app.queries.DeleteIdempotencyKey(context.WithoutCancel(ctx), database.DeleteIdempotencyKeyParams{
+		Key:         key,
+		LockedUntil: lockedUntil,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete idempotency key", "error", errorSummary(err))
+	}
+}
+
+// requestTxKey is the context key of the transaction of a request with an Idempotency-Key
+type requestTxKey struct{}
+
+// begin starts a transaction. Within a request with an Idempotency-Key it starts a savepoint
+// of the request transaction instead, so the changes of the request commit with its stored response.
+func (app *application) begin(ctx context.Context) (pgx.Tx, error) {
+	if tx, ok := ctx.Value(requestTxKey{}).(pgx.Tx); ok {
+		return tx.Begin(ctx)
+	}
+	return app.db.Begin(ctx)
+}
+
+// requestQueries returns the queries to make outside of a transaction: within a request
+// with an Idempotency-Key they run in the request transaction.
+func (app *application) requestQueries(ctx context.Context) *database.Queries {
+	if tx, ok := ctx.Value(requestTxKey{}).(pgx.Tx); ok {
+		return app.queries.WithTx(tx)
+	}
+	return app.queries
+}
+
+// replayIdempotentResponse writes the response stored for an already used idempotency key
+func (app *application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key, fingerprint string) {
+	stored, err := app.queries.GetIdempotencyKey(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The key expired and was cleaned up in the meantime
+			writeProblem(w, r, problemIdempotencyKeyExpired, "Idempotency-Key has expired, retry the request", nil)
+			return
+		}
+		serverError(w, r, "Failed to get idempotency key", err)
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		writeProblem(w, r, problemIdempotencyKeyReused, "Idempotency-Key has already been used with a different request", nil)
+		return
+	}
+
+	if !stored.StatusCode.Valid {
+		writeProblem(w, r, problemIdempotencyInProgress, "A request with this Idempotency-Key is still being processed", nil)
+		return
+	}
+
+	if stored.ContentType.String != "" {
+		w.Header().Set("Content-Type", stored.ContentType.String)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.StatusCode.Int32))
+	_, err = w.Write(stored.ResponseBody)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+// JSON bodies are normalized first, so formatting and key order do not matter.
+func requestFingerprint(method, path string, body []byte) string {
+	var payload any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err == nil {
+		if normalized, err := json.Marshal(payload); err == nil {
+			body = normalized
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(method + "\n" + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// runIdempotencyKeyCleanup periodically deletes expired idempotency keys until ctx is canceled
+func (app *application) runIdempotencyKeyCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := app.queries.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				slog.Error("Failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("Deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
+
+// responseRecorder buffers a response until it is stored, so that the client only gets
+// a response whose changes have been committed
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
+
+// writeTo sends the buffered response to the client
+func (rec *responseRecorder) writeTo(ctx context.Context, w http.ResponseWriter) {
+	for name, values := range rec.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(rec.status)
+	_, err := w.Write(rec.body.Bytes())
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to write response", "error", err)
+	}
+}
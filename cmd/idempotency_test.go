@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	path := "/api/v1/wallets/fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	original := requestFingerprint("POST", path, []byte(`{"operation_type":"deposit","amount":500}`))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected bool
+	}{
+		{
+			name:     "Same request",
+			method:   "POST",
+			path:     path,
+			body:     `{"operation_type":"deposit","amount":500}`,
+			expected: true,
+		},
+		{
+			name:     "Reformatted JSON body",
+			method:   "POST",
+			path:     path,
+			body:     "{\n  \"amount\": 500,\n  \"operation_type\": \"deposit\"\n}",
+			expected: true,
+		},
+		{
+			name:     "Different amount",
+			method:   "POST",
+			path:     path,
+			body:     `{"operation_type":"deposit","amount":501}`,
+			expected: false,
+		},
+		{
+			name:     "Different wallet",
+			method:   "POST",
+			path:     "/api/v1/wallets/30504a06-1d08-4390-92ef-c03c253d702b",
+			body:     `{"operation_type":"deposit","amount":500}`,
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fingerprint := requestFingerprint(tc.method, tc.path, []byte(tc.body))
+			assert.Equal(t, tc.expected, fingerprint == original)
+		})
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		key           string
+		mockError     error
+		rowsAffected  int64
+		handlerStatus int
+		expectedCode  int
+		expectedCalls int
+	}{
+		{
+			name:          "No Idempotency-Key header",
+			key:           "",
+			handlerStatus: http.StatusCreated,
+			expectedCode:  http.StatusCreated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Idempotency-Key is too long",
+			key:           strings.Repeat("k", maxIdempotencyKeyLength+1),
+			expectedCode:  http.StatusBadRequest,
+			expectedCalls: 0,
+		},
+		{
+			name:          "Response is stored",
+			key:           "key",
+			rowsAffected:  1,
+			handlerStatus: http.StatusCreated,
+			expectedCode:  http.StatusCreated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Key taken over by a retry",
+			key:           "key",
+			handlerStatus: http.StatusCreated,
+			expectedCode:  http.StatusConflict,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Server error is not stored",
+			key:           "key",
+			handlerStatus: http.StatusInternalServerError,
+			expectedCode:  http.StatusInternalServerError,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Failed to claim key",
+			key:           "key",
+			mockError:     errors.New("connection refused"),
+			expectedCode:  http.StatusInternalServerError,
+			expectedCalls: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError, RowsAffected: tc.rowsAffected}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			calls := 0
+			handler := app.idempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(tc.handlerStatus)
+			})
+
+			req := httptest.NewRequest("POST", "/api/v1/wallets", nil)
+			if tc.key != "" {
+				req.Header.Set("Idempotency-Key", tc.key)
+			}
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+// TestIdempotencyKeyLease checks that a retry takes over the key of a request that did not finish
+// before its lease ended, and that the changes of a failed request are rolled back with its key.
+// It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestIdempotencyKeyLease(t *testing.T) {
+	app, dbPool := newTestApp(t)
+	app.idempotencyKeyTTL = time.Hour
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		lockedFor     time.Duration
+		handlerStatus int
+		expectedCode  int
+		expectWallet  bool
+	}{
+		{
+			name:          "Request still holds the key",
+			lockedFor:     time.Minute,
+			handlerStatus: http.StatusCreated,
+			expectedCode:  http.StatusConflict,
+		},
+		{
+			name:          "Lease of the request has ended",
+			lockedFor:     -time.Second,
+			handlerStatus: http.StatusCreated,
+			expectedCode:  http.StatusCreated,
+			expectWallet:  true,
+		},
+		{
+			name:          "Failed request is rolled back",
+			handlerStatus: http.StatusInternalServerError,
+			expectedCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			key := newUUID().String()
+			path := "/api/v1/wallets"
+
+			// Simulate an earlier request that claimed the key and never finished
+			if tc.lockedFor != 0 {
+				_, err := dbPool.Exec(ctx, `
+					INSERT INTO idempotency_keys (key, fingerprint, expires_at, locked_until)
+					VALUES ($1, $2, NOW() + INTERVAL '1 hour', NOW() + $3::interval)`,
+					key, requestFingerprint("POST", path, nil), pgtype.Interval{Microseconds: tc.lockedFor.Microseconds(), Valid: true})
+				require.NoError(t, err)
+			}
+
+			// The handler creates a wallet in the request transaction
+			var walletID pgtype.UUID
+			handler := app.idempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				walletID, err = app.createWallet(r.Context(), "RUB")
+				require.NoError(t, err)
+				w.WriteHeader(tc.handlerStatus)
+			})
+
+			req := httptest.NewRequest("POST", path, nil)
+			req.Header.Set("Idempotency-Key", key)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, tc.expectedCode, w.Code)
+
+			if walletID.Valid {
+				_, err := app.queries.GetWallet(ctx, walletID)
+				if tc.expectWallet {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, sql.ErrNoRows)
+				}
+			}
+
+			// A finished request leaves its response unlocked, a failed one leaves no key
+			stored, err := app.queries.GetIdempotencyKey(ctx, key)
+			switch tc.expectedCode {
+			case http.StatusCreated:
+				require.NoError(t, err)
+				assert.Equal(t, int32(http.StatusCreated), stored.StatusCode.Int32)
+				assert.False(t, stored.LockedUntil.Valid)
+			case http.StatusInternalServerError:
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			}
+		})
+	}
+}
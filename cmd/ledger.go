@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// externalAccount is the system ledger account that funds deposits and receives withdrawals
+const externalAccount = "external"
+
+// recordOperation inserts a deposit or withdrawal and posts its ledger journal,
+// which moves the amount between the wallet account and the external account.
+// The wallet balance is derived from the posted entries by the database.
+func (app *application) recordOperation(ctx context.Context, queries *database.Queries, params database.AddOperationParams, currencyCode string) (pgtype.UUID, error) {
+	operationID, err := queries.AddOperation(ctx, params)
+	if err != nil {
+		return pgtype.UUID{}, fmt.Errorf("add operation: %w", err)
+	}
+
+	externalID, err := app.systemAccountID(ctx, queries, externalAccount, currencyCode)
+	if err != nil {
+		return pgtype.UUID{}, fmt.Errorf("get %s account: %w", externalAccount, err)
+	}
+
+	journal := database.PostJournalParams{
+		JournalID:         operationID,
+		Amount:            params.Amount,
+		DebitOperationID:  operationID,
+		CreditOperationID: operationID,
+	}
+	switch params.OperationType {
+	case operations.Deposit:
+		journal.DebitAccountID, journal.CreditAccountID = externalID, params.WalletID
+	case operations.Withdraw:
+		journal.DebitAccountID, journal.CreditAccountID = params.WalletID, externalID
+	default:
+		return pgtype.UUID{}, fmt.Errorf("unsupported operation type %q", params.OperationType)
+	}
+
+	err = queries.PostJournal(ctx, journal)
+	if err != nil {
+		return pgtype.UUID{}, fmt.Errorf("post journal: %w", err)
+	}
+	return operationID, nil
+}
+
+// systemAccountID returns the ID of the system ledger account with the given name and currency,
+// creating the account on first use
+func (app *application) systemAccountID(ctx context.Context, queries *database.Queries, name, currencyCode string) (pgtype.UUID, error) {
+	key := name + "/" + currencyCode
+	if id, ok := app.systemAccounts.Load(key); ok {
+		return id.(pgtype.UUID), nil
+	}
+
+	params := database.GetSystemAccountParams{Name: pgtype.Text{String: name, Valid: true}, Currency: currencyCode}
+	id, err := queries.GetSystemAccount(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Create the account outside of the caller's transaction, so it is never rolled back
+		err = app.queries.CreateSystemAccount(ctx, database.CreateSystemAccountParams(params))
+		if err != nil {
+			return pgtype.UUID{}, err
+		}
+		id, err = queries.GetSystemAccount(ctx, params)
+	}
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+
+	app.systemAccounts.Store(key, id)
+	return id, nil
+}
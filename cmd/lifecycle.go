@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Wallet statuses: a frozen wallet only accepts deposits, a closed wallet accepts no operations
+const (
+	walletActive = "active"
+	walletFrozen = "frozen"
+	walletClosed = "closed"
+)
+
+// walletStatusProblem returns why an operation is not allowed on a wallet with the given status,
+// or nil if it is allowed. Debits are withdrawals, outgoing transfers, holds and captures.
+func walletStatusProblem(status string, debit bool) *problem {
+	switch {
+	case status == walletClosed:
+		return newProblem(problemWalletClosed, "Wallet is closed", map[string]any{"wallet_status": status})
+	case status == walletFrozen && debit:
+		return newProblem(problemWalletFrozen, "Wallet is frozen: withdrawals are not allowed", map[string]any{"wallet_status": status})
+	}
+	return nil
+}
+
+func (app *application) handleFreezeWallet(w http.ResponseWriter, r *http.Request) {
+	wallet, ok := app.changeWalletStatus(w, r, walletFrozen)
+	if ok {
+		writeJSON(w, http.StatusOK, wallet)
+	}
+}
+
+func (app *application) handleUnfreezeWallet(w http.ResponseWriter, r *http.Request) {
+	wallet, ok := app.changeWalletStatus(w, r, walletActive)
+	if ok {
+		writeJSON(w, http.StatusOK, wallet)
+	}
+}
+
+func (app *application) handleCloseWallet(w http.ResponseWriter, r *http.Request) {
+	wallet, ok := app.changeWalletStatus(w, r, walletClosed)
+	if ok {
+		writeJSON(w, http.StatusOK, wallet)
+	}
+}
+
+// changeWalletStatus moves the wallet from the path to the given status and returns the updated wallet.
+// If the status cannot be changed, it writes an error response and returns false.
+func (app *application) changeWalletStatus(w http.ResponseWriter, r *http.Request, status string) (database.Wallet, bool) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return database.Wallet{}, false
+	}
+
+	wallet, err := app.setWalletStatus(r.Context(), walletUUID, status)
+	if err != nil {
+		writeError(w, r, err)
+		return database.Wallet{}, false
+	}
+	return wallet, true
+}
+
+// setWalletStatus moves the wallet to the given status and returns the updated wallet.
+// A closed wallet cannot be reopened, and a wallet can only be closed once its balance is zero
+// and no funds are on hold.
+func (app *application) setWalletStatus(ctx context.Context, walletUUID pgtype.UUID, status string) (database.Wallet, error) {
+	// Start transaction
+	tx, err := app.db.Begin(ctx)
+	if err != nil {
+		return database.Wallet{}, internalError("Failed to begin wallet status transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock the wallet row, so no operation changes the balance while the status is checked
+	wallet, err := queriesWithTx.GetBalanceForUpdate(ctx, walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Wallet{}, newProblem(problemWalletNotFound, "Wallet not found", nil)
+		}
+		return database.Wallet{}, internalError("Failed to get wallet", err)
+	}
+
+	// Check the status transition
+	switch {
+	case wallet.Status == status:
+		return database.Wallet{}, newProblem(problemWalletStatusUnchanged, "Wallet is already "+status, map[string]any{"wallet_status": status})
+	case wallet.Status == walletClosed:
+		return database.Wallet{}, newProblem(problemWalletClosed, "Wallet is closed", map[string]any{"wallet_status": walletClosed})
+	case status == walletClosed && wallet.Held != 0:
+		return database.Wallet{}, newProblem(problemWalletNotEmpty, fmt.Sprintf("Cannot close wallet with funds on hold: held %d", wallet.Held), map[string]any{"held": wallet.Held})
+	case status == walletClosed && wallet.Balance != 0:
+		return database.Wallet{}, newProblem(problemWalletNotEmpty, fmt.Sprintf("Cannot close wallet with non-zero balance: balance %d", wallet.Balance), map[string]any{"balance": wallet.Balance})
+	}
+
+	updated, err := queriesWithTx.UpdateWalletStatus(ctx, database.UpdateWalletStatusParams{
+		ID:     walletUUID,
+		Status: status,
+	})
+	if err != nil {
+		return database.Wallet{}, internalError("Failed to update wallet status", err)
+	}
+
+	// Commit transaction
+	err = tx.Commit(ctx)
+	if err != nil {
+		return database.Wallet{}, internalError("Failed to commit wallet status transaction", err)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletStatusHandlers(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	// walletRow returns the columns of a wallet locked by GetBalanceForUpdate
+	walletRow := func(balance, held int64, status string) []any {
+		return []any{balance, "RUB", held, status}
+	}
+
+	tests := []struct {
+		name            string
+		walletID        string
+		mockRow         []any
+		mockError       error
+		handler         func(*application, http.ResponseWriter, *http.Request)
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Freeze an active wallet",
+			walletID:     validUUID,
+			mockRow:      walletRow(100, 0, walletActive),
+			handler:      (*application).handleFreezeWallet,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Unfreeze a frozen wallet",
+			walletID:     validUUID,
+			mockRow:      walletRow(100, 0, walletFrozen),
+			handler:      (*application).handleUnfreezeWallet,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Close an empty wallet",
+			walletID:     validUUID,
+			mockRow:      walletRow(0, 0, walletFrozen),
+			handler:      (*application).handleCloseWallet,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Close a wallet with non-zero balance",
+			walletID:        validUUID,
+			mockRow:         walletRow(100, 0, walletActive),
+			handler:         (*application).handleCloseWallet,
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_not_empty",
+			expectedDetail:  "Cannot close wallet with non-zero balance: balance 100",
+		},
+		{
+			name:            "Close a wallet with funds on hold",
+			walletID:        validUUID,
+			mockRow:         walletRow(100, 100, walletActive),
+			handler:         (*application).handleCloseWallet,
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_not_empty",
+			expectedDetail:  "Cannot close wallet with funds on hold: held 100",
+		},
+		{
+			name:            "Reopen a closed wallet",
+			walletID:        validUUID,
+			mockRow:         walletRow(0, 0, walletClosed),
+			handler:         (*application).handleUnfreezeWallet,
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_closed",
+			expectedDetail:  "Wallet is closed",
+		},
+		{
+			name:            "Freeze a frozen wallet",
+			walletID:        validUUID,
+			mockRow:         walletRow(100, 0, walletFrozen),
+			handler:         (*application).handleFreezeWallet,
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_status_unchanged",
+			expectedDetail:  "Wallet is already frozen",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			mockError:       sql.ErrNoRows,
+			handler:         (*application).handleFreezeWallet,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			handler:         (*application).handleFreezeWallet,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Row: tc.mockRow,
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+tc.walletID, nil)
+			req.SetPathValue("wallet_id", tc.walletID)
+			w := httptest.NewRecorder()
+
+			tc.handler(app, w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
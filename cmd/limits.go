@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/limits"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// withdrawalLimits are the limits of withdrawals per calendar day and month; invalid values mean no limit
+type withdrawalLimits struct {
+	Daily   pgtype.Int8 `json:"daily_withdrawal_limit"`
+	Monthly pgtype.Int8 `json:"monthly_withdrawal_limit"`
+}
+
+// limitsResponse is the effective withdrawal limits of a wallet with the amounts already withdrawn
+type limitsResponse struct {
+	withdrawalLimits
+	// Default reports whether the wallet uses the default limits from the configuration
+	Default          bool  `json:"default"`
+	DailyWithdrawn   int64 `json:"daily_withdrawn"`
+	MonthlyWithdrawn int64 `json:"monthly_withdrawn"`
+}
+
+func (app *application) handleGetLimits(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Check that the wallet exists
+	_, err = app.queries.GetBalance(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	app.writeLimits(w, r, walletUUID)
+}
+
+func (app *application) handleSetLimits(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Decode JSON from request to struct
+	var req limits.Request
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	// Check limits
+	params := database.SetWalletLimitsParams{WalletID: walletUUID}
+	for _, limit := range []struct {
+		value *int64
+		param *pgtype.Int8
+	}{
+		{value: req.DailyWithdrawalLimit, param: &params.DailyWithdrawalLimit},
+		{value: req.MonthlyWithdrawalLimit, param: &params.MonthlyWithdrawalLimit},
+	} {
+		if limit.value == nil {
+			continue
+		}
+		if *limit.value <= 0 {
+			writeProblem(w, r, problemInvalidLimit, "Limit must be greater than zero or null for no limit", nil)
+			return
+		}
+		*limit.param = pgtype.Int8{Int64: *limit.value, Valid: true}
+	}
+
+	// Check that the wallet exists
+	_, err = app.queries.GetBalance(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	_, err = app.queries.SetWalletLimits(r.Context(), params)
+	if err != nil {
+		serverError(w, r, "Failed to set wallet limits", err)
+		return
+	}
+
+	app.writeLimits(w, r, walletUUID)
+}
+
+func (app *application) handleResetLimits(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Check that the wallet exists
+	_, err = app.queries.GetWallet(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet", err)
+		return
+	}
+
+	// Delete the wallet limits, so the default limits apply again
+	err = app.queries.DeleteWalletLimits(r.Context(), walletUUID)
+	if err != nil {
+		serverError(w, r, "Failed to reset wallet limits", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeLimits responds with the effective limits of the wallet and the amounts withdrawn in the current periods
+func (app *application) writeLimits(w http.ResponseWriter, r *http.Request, walletUUID pgtype.UUID) {
+	walletLimits, isDefault, err := app.walletLimits(r.Context(), app.queries, walletUUID)
+	if err != nil {
+		serverError(w, r, "Failed to get wallet limits", err)
+		return
+	}
+
+	totals, err := app.queries.GetWithdrawalTotals(r.Context(), walletUUID)
+	if err != nil {
+		serverError(w, r, "Failed to get withdrawal totals", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, limitsResponse{
+		withdrawalLimits: walletLimits,
+		Default:          isDefault,
+		DailyWithdrawn:   totals.Daily,
+		MonthlyWithdrawn: totals.Monthly,
+	})
+}
+
+// walletLimits returns the withdrawal limits of the wallet and whether they are the default ones
+func (app *application) walletLimits(ctx context.Context, queries *database.Queries, walletUUID pgtype.UUID) (withdrawalLimits, bool, error) {
+	walletLimits, err := queries.GetWalletLimits(ctx, walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.defaultLimits, true, nil
+		}
+		return withdrawalLimits{}, false, err
+	}
+	return withdrawalLimits{Daily: walletLimits.DailyWithdrawalLimit, Monthly: walletLimits.MonthlyWithdrawalLimit}, false, nil
+}
+
+// checkWithdrawalLimits returns the problem of withdrawing the amount if it would exceed the wallet limits,
+// or nil if it is allowed. It must be called in the transaction that holds the wallet lock,
+// so concurrent withdrawals cannot exceed the limits together.
+func (app *application) checkWithdrawalLimits(ctx context.Context, queries *database.Queries, walletUUID pgtype.UUID, amount int64) (*problem, error) {
+	walletLimits, _, err := app.walletLimits(ctx, queries, walletUUID)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet limits: %w", err)
+	}
+	if !walletLimits.Daily.Valid && !walletLimits.Monthly.Valid {
+		return nil, nil
+	}
+
+	totals, err := queries.GetWithdrawalTotals(ctx, walletUUID)
+	if err != nil {
+		return nil, fmt.Errorf("get withdrawal totals: %w", err)
+	}
+
+	if walletLimits.Daily.Valid && amount > walletLimits.Daily.Int64-totals.Daily {
+		return withdrawalLimitExceeded("daily", walletLimits.Daily.Int64, totals.Daily, amount), nil
+	}
+	if walletLimits.Monthly.Valid && amount > walletLimits.Monthly.Int64-totals.Monthly {
+		return withdrawalLimitExceeded("monthly", walletLimits.Monthly.Int64, totals.Monthly, amount), nil
+	}
+	return nil, nil
+}
+
+// getEnvLimit reads a positive limit from an environment variable; an unset variable means no limit
+func getEnvLimit(name string) (pgtype.Int8, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return pgtype.Int8{}, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return pgtype.Int8{}, fmt.Errorf("invalid %s environment variable: expected a positive integer, got %q", name, value)
+	}
+	return pgtype.Int8{Int64: limit, Valid: true}, nil
+}
+
+// withdrawalLimitExceeded is the problem of a withdrawal over the daily or monthly limit
+func withdrawalLimitExceeded(period string, limit, withdrawn, amount int64) *problem {
+	detail := fmt.Sprintf("Daily withdrawal limit exceeded: limit %d, withdrawn today %d, trying to withdraw %d", limit, withdrawn, amount)
+	if period == "monthly" {
+		detail = fmt.Sprintf("Monthly withdrawal limit exceeded: limit %d, withdrawn this month %d, trying to withdraw %d", limit, withdrawn, amount)
+	}
+	return newProblem(problemWithdrawalLimit, detail, map[string]any{
+		"period":    period,
+		"limit":     limit,
+		"withdrawn": withdrawn,
+		"amount":    amount,
+	})
+}
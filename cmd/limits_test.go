@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSetLimits(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name            string
+		walletID        string
+		body            string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Daily and monthly limits",
+			walletID:     validUUID,
+			body:         `{"daily_withdrawal_limit":1000,"monthly_withdrawal_limit":20000}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "No limits",
+			walletID:     validUUID,
+			body:         `{"daily_withdrawal_limit":null}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Negative limit",
+			walletID:        validUUID,
+			body:            `{"daily_withdrawal_limit":-1}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_limit",
+			expectedDetail:  "Limit must be greater than zero or null for no limit",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			body:            `{"daily_withdrawal_limit":1000}`,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			body:            `{"daily_withdrawal_limit":1000}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("PUT", "/api/v1/wallets/"+tc.walletID+"/limits", strings.NewReader(tc.body))
+			req.SetPathValue("wallet_id", tc.walletID)
+			w := httptest.NewRecorder()
+
+			contract(t, app.handleSetLimits).ServeHTTP(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestHandleResetLimits(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name            string
+		walletID        string
+		mockError       error
+		expectedCode    int
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Limits reset",
+			walletID:     validUUID,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("DELETE", "/api/v1/wallets/"+tc.walletID+"/limits", nil)
+			req.SetPathValue("wallet_id", tc.walletID)
+			w := httptest.NewRecorder()
+
+			contract(t, app.handleResetLimits).ServeHTTP(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+		})
+	}
+}
+
+// TestWithdrawalLimitAfterReversal checks that the reversed part of a withdrawal no longer counts
+// towards the limits of the wallet. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestWithdrawalLimitAfterReversal(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	_, err = app.queries.SetWalletLimits(ctx, database.SetWalletLimitsParams{
+		WalletID:             walletUUID,
+		DailyWithdrawalLimit: pgtype.Int8{Int64: 500, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set wallet limits: %v", err)
+	}
+
+	code := postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: 1000})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to deposit: status code %d", code)
+	}
+	code = postOperation(t, app, walletID, operations.Operation{OperationType: operations.Withdraw, Amount: 400})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to withdraw: status code %d", code)
+	}
+
+	var withdrawalUUID pgtype.UUID
+	err = dbPool.QueryRow(ctx, "SELECT id FROM operations WHERE wallet_id = $1 AND operation_type = 'withdraw'", walletUUID).Scan(&withdrawalUUID)
+	if err != nil {
+		t.Fatalf("Failed to get withdrawal: %v", err)
+	}
+
+	// Reverse most of the withdrawal, leaving 100 withdrawn today
+	req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/operations/"+withdrawalUUID.String()+"/reverse", strings.NewReader(`{"amount":300}`))
+	req.SetPathValue("wallet_id", walletID)
+	req.SetPathValue("operation_id", withdrawalUUID.String())
+	w := httptest.NewRecorder()
+	app.handleReverseOperation(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to reverse withdrawal: status code %d", w.Code)
+	}
+
+	totals, err := app.queries.GetWithdrawalTotals(ctx, walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to get withdrawal totals: %v", err)
+	}
+	assert.Equal(t, int64(100), totals.Daily)
+
+	usages, err := app.queries.GetWithdrawalUsages(ctx, []pgtype.UUID{walletUUID})
+	if err != nil {
+		t.Fatalf("Failed to get withdrawal usages: %v", err)
+	}
+	if assert.Len(t, usages, 1) {
+		assert.Equal(t, int64(100), usages[0].DailyWithdrawn)
+	}
+
+	// The rest of the limit can be withdrawn again, but not more
+	assert.Equal(t, http.StatusOK, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Withdraw, Amount: 400}))
+	assert.Equal(t, http.StatusForbidden, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Withdraw, Amount: 1}))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDHeader carries the ID of a request. An ID sent by the client or a proxy is kept,
+// otherwise one is generated; either way it is echoed in the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits the size of client-supplied request IDs written to the logs
+const maxRequestIDLength = 128
+
+// newLogger returns a logger that writes JSON lines at the given level and above,
+// adding the attributes of the request being handled to every line
+func newLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// parseLogLevel parses a level name: "debug", "info", "warn" or "error"
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid LOG_LEVEL environment variable: %q must be \"debug\", \"info\", \"warn\" or \"error\"", name)
+	}
+	return level, nil
+}
+
+// fatal logs an error that the server cannot recover from and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// requestLog holds the attributes of a request that are added to every line logged while handling it
+type requestLog struct {
+	start time.Time
+
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type requestLogKey struct{}
+
+// withRequestLog returns a context whose log lines carry the given attributes and the latency of the request
+func withRequestLog(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, requestLogKey{}, &requestLog{start: time.Now(), attrs: attrs})
+}
+
+// addLogAttrs adds attributes to the lines logged for the rest of the request in the context,
+// such as the wallet or the operation type once they are known. It does nothing outside of a request.
+func addLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	rl, ok := ctx.Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.attrs = append(rl.attrs, attrs...)
+}
+
+// contextHandler adds the attributes of the request in the context of a record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if rl, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		rl.mu.Lock()
+		r.AddAttrs(rl.attrs...)
+		rl.mu.Unlock()
+		r.AddAttrs(slog.Float64("latency_ms", float64(time.Since(rl.start).Microseconds())/1000))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestID returns the request ID sent by the client if it is valid, or a new one.
+// A valid ID is up to 128 visible ASCII characters, so it cannot break the log lines.
+func requestID(sent string) string {
+	if sent == "" || len(sent) > maxRequestIDLength {
+		return newUUID().String()
+	}
+	for i := 0; i < len(sent); i++ {
+		if sent[i] < '!' || sent[i] > '~' {
+			return newUUID().String()
+		}
+	}
+	return sent
+}
+
+// logRequests assigns an ID to every request, echoes it in the X-Request-ID response header,
+// and logs the request once it has been handled
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(requestIDHeader))
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := withRequestLog(r.Context(), slog.String("request_id", id))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		slog.LogAttrs(ctx, slog.LevelInfo, "Request handled",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+		)
+	})
+}
+
+// logRoute adds the route of the request and the wallet it addresses to its log lines
+func logRoute(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attrs := []slog.Attr{slog.String("route", pattern)}
+		if walletID := r.PathValue("wallet_id"); walletID != "" {
+			attrs = append(attrs, slog.String("wallet_id", walletID))
+		}
+		addLogAttrs(r.Context(), attrs...)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// grpcRequestIDKey is the metadata key of the request ID of a gRPC call
+const grpcRequestIDKey = "x-request-id"
+
+// logCalls assigns an ID to every gRPC call, echoes it in the x-request-id response header,
+// and logs the call once it has been handled
+func logCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var sent string
+	if values := md.Get(grpcRequestIDKey); len(values) > 0 {
+		sent = values[0]
+	}
+	id := requestID(sent)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+
+	attrs := []slog.Attr{slog.String("request_id", id), slog.String("method", info.FullMethod)}
+	if r, ok := req.(interface{ GetWalletId() string }); ok && r.GetWalletId() != "" {
+		attrs = append(attrs, slog.String("wallet_id", r.GetWalletId()))
+	}
+	ctx = withRequestLog(ctx, attrs...)
+
+	resp, err := handler(ctx, req)
+	slog.LogAttrs(ctx, slog.LevelInfo, "Call handled", slog.String("code", status.Code(err).String()))
+	return resp, err
+}
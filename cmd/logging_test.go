@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/walletpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// captureLogs makes the default logger write JSON lines to a buffer for the rest of the test
+// and returns a function that decodes the lines written so far
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(newLogger(&buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []map[string]any {
+		var lines []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+			lines = append(lines, entry)
+		}
+		return lines
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name              string
+		sentRequestID     string
+		body              string
+		mockBalance       int64
+		expectedCode      int
+		expectedRequestID string
+	}{
+		{
+			name:              "Propagated request ID",
+			sentRequestID:     "req-42",
+			body:              `{"operation_type":"deposit","amount":50}`,
+			mockBalance:       100,
+			expectedCode:      http.StatusOK,
+			expectedRequestID: "req-42",
+		},
+		{
+			name:         "Generated request ID",
+			body:         `{"operation_type":"deposit","amount":50}`,
+			mockBalance:  100,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "Invalid request ID is replaced",
+			sentRequestID: "bad id\twith spaces",
+			body:          `{"operation_type":"withdraw","amount":500}`,
+			mockBalance:   100,
+			expectedCode:  http.StatusPaymentRequired,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Balance: tc.mockBalance}
+
+		t.Run(tc.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID, strings.NewReader(tc.body))
+			if tc.sentRequestID != "" {
+				req.Header.Set(requestIDHeader, tc.sentRequestID)
+			}
+			w := httptest.NewRecorder()
+			app.router().ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedCode, w.Code)
+
+			// The request ID is echoed in the response
+			id := w.Header().Get(requestIDHeader)
+			if tc.expectedRequestID != "" {
+				assert.Equal(t, tc.expectedRequestID, id)
+			} else {
+				assert.Len(t, id, 36)
+			}
+
+			// The access line carries the attributes of the request
+			lines := logs()
+			require.Len(t, lines, 1)
+			line := lines[0]
+			assert.Equal(t, "Request handled", line["msg"])
+			assert.Equal(t, id, line["request_id"])
+			assert.Equal(t, "POST /api/v1/wallets/{wallet_id}", line["route"])
+			assert.Equal(t, walletID, line["wallet_id"])
+			assert.Equal(t, float64(tc.expectedCode), line["status"])
+			assert.Contains(t, tc.body, line["operation_type"])
+			assert.Contains(t, line, "latency_ms")
+		})
+	}
+}
+
+func TestServerErrorLog(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	logs := captureLogs(t)
+
+	mockDB := &mocks.DBTX{Err: errors.New("connection refused")}
+	app := &application{
+		db:      mockDB,
+		queries: database.New(mockDB),
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/wallets/"+walletID, nil)
+	req.Header.Set(requestIDHeader, "req-500")
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Both the error and the access line belong to the request
+	lines := logs()
+	require.Len(t, lines, 2)
+	assert.Equal(t, "ERROR", lines[0]["level"])
+	for _, line := range lines {
+		assert.Equal(t, "req-500", line["request_id"])
+		assert.Equal(t, walletID, line["wallet_id"])
+	}
+}
+
+func TestLogCalls(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	logs := captureLogs(t)
+
+	mockDB := &mocks.DBTX{Balance: 100}
+	app := &application{
+		db:      mockDB,
+		queries: database.New(mockDB),
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := app.newGRPCServer()
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	// The request ID sent in the metadata is echoed in the response header
+	ctx := metadata.AppendToOutgoingContext(t.Context(), grpcRequestIDKey, "call-7")
+	var header metadata.MD
+	_, err = walletpb.NewWalletServiceClient(conn).GetBalance(ctx, &walletpb.GetBalanceRequest{WalletId: walletID}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"call-7"}, header.Get(grpcRequestIDKey))
+
+	lines := logs()
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, "Call handled", line["msg"])
+	assert.Equal(t, "OK", line["code"])
+	assert.Equal(t, "call-7", line["request_id"])
+	assert.Equal(t, walletID, line["wallet_id"])
+	assert.Equal(t, walletpb.WalletService_GetBalance_FullMethodName, line["method"])
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		sent      string
+		expectNew bool
+	}{
+		{name: "Client ID", sent: "7b0c1f0e-trace"},
+		{name: "Missing ID", expectNew: true},
+		{name: "ID with spaces", sent: "a b", expectNew: true},
+		{name: "ID with a line break", sent: "a\nb", expectNew: true},
+		{name: "Too long ID", sent: strings.Repeat("a", maxRequestIDLength+1), expectNew: true},
+		{name: "Longest ID", sent: strings.Repeat("a", maxRequestIDLength)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id := requestID(tc.sent)
+			if tc.expectNew {
+				assert.NotEqual(t, tc.sent, id)
+				assert.Len(t, id, 36)
+				return
+			}
+			assert.Equal(t, tc.sent, id)
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectedLevel slog.Level
+		expectedErr   string
+	}{
+		{name: "debug", expectedLevel: slog.LevelDebug},
+		{name: "info", expectedLevel: slog.LevelInfo},
+		{name: "WARN", expectedLevel: slog.LevelWarn},
+		{name: "error", expectedLevel: slog.LevelError},
+		{name: "verbose", expectedErr: `invalid LOG_LEVEL environment variable: "verbose" must be "debug", "info", "warn" or "error"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			level, err := parseLogLevel(tc.name)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLevel, level)
+		})
+	}
+}
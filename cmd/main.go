@@ -2,60 +2,134 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/chtozamm/javacode-wallet/internal/currency"
 	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/openapi"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
+// dbPool is the subset of *pgxpool.Pool used by the handlers
+type dbPool interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+}
+
 type application struct {
-	db      *pgxpool.Pool
+	db      dbPool
 	queries *database.Queries
 	auth    struct {
 		username string
 		password string
 	}
+	idempotencyKeyTTL time.Duration
+	defaultCurrency   string
+	holdTTL           time.Duration
+	defaultLimits     withdrawalLimits
+	// webhookClient sends webhooks to subscribers
+	webhookClient *http.Client
+	// events fans out wallet events to Server-Sent Events streams
+	events            *eventBroker
+	heartbeatInterval time.Duration
+	// systemAccounts caches the IDs of system ledger accounts by name and currency
+	systemAccounts sync.Map
+	// openAPISpec is the OpenAPI specification served as JSON
+	openAPISpec []byte
+	// metrics are exposed for Prometheus on /metrics
+	metrics *metrics
+	// tracerProvider creates the spans of requests; requests are not traced if it is nil
+	tracerProvider trace.TracerProvider
 }
 
 func main() {
+	// Log JSON lines at the level set by LOG_LEVEL
+	logLevel := new(slog.LevelVar)
+	slog.SetDefault(newLogger(os.Stdout, logLevel))
+
 	// Load environment variables
-	log.Println("Setting up environment variables...")
+	slog.Info("Setting up environment variables...")
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatalf("FATAL: Failed to load environment variables: %v", err)
+		fatal("Failed to load environment variables", "error", err)
+	}
+
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		level, err := parseLogLevel(name)
+		if err != nil {
+			fatal(err.Error())
+		}
+		logLevel.Set(level)
 	}
 
 	dbURL := os.Getenv("DB_URL")
 	if dbURL == "" {
-		log.Fatal("FATAL: Missing DB_URL environment variable")
+		fatal("Missing DB_URL environment variable")
 	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
-		log.Fatal("FATAL: Missing PORT environment variable")
+		fatal("Missing PORT environment variable")
+	}
+
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		slog.Warn("Missing GRPC_PORT environment variable. The gRPC server will not be started.")
+	}
+
+	// Set up tracing
+	tracerProvider, err := newTracerProvider(context.Background(), os.Getenv("TRACING_EXPORTER"))
+	if err != nil {
+		fatal(err.Error())
+	}
+	if tracerProvider != nil {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagator)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracerProvider.Shutdown(ctx); err != nil {
+				slog.Error("Failed to flush spans", "error", err)
+			}
+		}()
 	}
 
 	// Create database connection pool
-	log.Println("Creating database connection pool...")
-	dbPool, err := pgxpool.New(context.Background(), dbURL)
+	slog.Info("Creating database connection pool...")
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
 	if err != nil {
-		log.Fatalf("FATAL: Unable to create database connection pool: %v", err)
+		fatal("Invalid DB_URL environment variable", "error", err)
+	}
+	if tracerProvider != nil {
+		poolConfig.ConnConfig.Tracer = newQueryTracer(tracerProvider)
+	}
+	dbPool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		fatal("Unable to create database connection pool", "error", err)
 	}
 	defer dbPool.Close()
 
 	// Check the database connection
-	log.Println("Trying to reach the database...")
+	slog.Info("Trying to reach the database...")
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	err = dbPool.Ping(ctx)
 	if err != nil {
-		log.Fatalf("FATAL: Unable to reach the database: %v", err)
+		fatal("Unable to reach the database", "error", err)
 	}
 
 	// Wrap the DB connection in queries generated by sqlc
@@ -67,53 +141,213 @@ func main() {
 		queries: dbQueries,
 	}
 
+	if tracerProvider != nil {
+		app.tracerProvider = tracerProvider
+	}
+
+	// Run the reconciliation instead of the server if requested:
+	// wallet-server reconcile [-fix]
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		app.runReconcileCommand(os.Args[2:])
+		return
+	}
+
 	// Load authentication credentials from environment variables
 	app.auth.username = os.Getenv("AUTH_USERNAME")
 	if app.auth.username == "" {
-		log.Println("WARNING: Missing AUTH_USERNAME environment variable. Endpoints that require authentication will be inaccessible.")
+		slog.Warn("Missing AUTH_USERNAME environment variable. Endpoints that require authentication will be inaccessible.")
 	}
 
 	app.auth.password = os.Getenv("AUTH_PASSWORD")
 	if app.auth.password == "" {
-		log.Println("WARNING: Missing AUTH_PASSWORD environment variable. Endpoints that require authentication will be inaccessible.")
+		slog.Warn("Missing AUTH_PASSWORD environment variable. Endpoints that require authentication will be inaccessible.")
 	}
 
-	// Set up the router
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /api/v1/wallets/{wallet_id}", app.handleGetBalance)
-	mux.HandleFunc("GET /api/v1/wallets", app.basicAuthMiddleware(app.handleGetWallets))
-	mux.HandleFunc("POST /api/v1/wallets", app.handleCreateWallet)
-	mux.HandleFunc("POST /api/v1/wallets/{wallet_id}", app.handleOperation)
-	mux.HandleFunc("DELETE /api/v1/wallets/{wallet_id}", app.handleDeleteWallet)
-	mux.HandleFunc("GET /api/v1/healthz", app.handleHealthCheck)
+	// Load default currency for new wallets from environment variables
+	app.defaultCurrency = os.Getenv("DEFAULT_CURRENCY")
+	if app.defaultCurrency == "" {
+		app.defaultCurrency = "RUB"
+	}
+	if _, ok := currency.Lookup(app.defaultCurrency); !ok {
+		fatal(fmt.Sprintf("Invalid DEFAULT_CURRENCY environment variable: %q is not an ISO 4217 currency code", app.defaultCurrency))
+	}
 
-	// Set up and start the server
-	srv := &http.Server{
-		Addr:              ":" + port,
-		Handler:           mux,
-		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       30 * time.Second,
-		ReadHeaderTimeout: 2 * time.Second,
+	// Load idempotency key settings from environment variables
+	app.idempotencyKeyTTL, err = getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	idempotencyCleanupInterval, err := getEnvDuration("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load hold settings from environment variables
+	app.holdTTL, err = getEnvDuration("HOLD_TTL", 7*24*time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	holdExpiryInterval, err := getEnvDuration("HOLD_EXPIRY_INTERVAL", time.Minute)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load default withdrawal limits from environment variables
+	app.defaultLimits.Daily, err = getEnvLimit("DAILY_WITHDRAWAL_LIMIT")
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	app.defaultLimits.Monthly, err = getEnvLimit("MONTHLY_WITHDRAWAL_LIMIT")
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load balance snapshot settings from environment variables
+	balanceSnapshotInterval, err := getEnvDuration("BALANCE_SNAPSHOT_INTERVAL", 24*time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load reconciliation settings from environment variables
+	reconciliationInterval, err := getEnvDuration("RECONCILIATION_INTERVAL", 24*time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load webhook settings from environment variables
+	webhookTimeout, err := getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		fatal(err.Error())
+	}
+	app.webhookClient = &http.Client{Timeout: webhookTimeout}
+
+	webhookDispatchInterval, err := getEnvDuration("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	webhookEventRetention, err := getEnvDuration("WEBHOOK_EVENT_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		fatal(err.Error())
 	}
 
-	log.Printf("Server is listening on port :%s\n", port)
+	webhookCleanupInterval, err := getEnvDuration("WEBHOOK_CLEANUP_INTERVAL", time.Hour)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// Load event stream settings from environment variables
+	app.heartbeatInterval, err = getEnvDuration("SSE_HEARTBEAT_INTERVAL", 15*time.Second)
+	if err != nil {
+		fatal(err.Error())
+	}
+	app.events = newEventBroker()
+
+	// Load the OpenAPI specification to serve
+	spec, err := openapi.Load()
+	if err != nil {
+		fatal(err.Error())
+	}
+	app.openAPISpec, err = spec.MarshalJSON()
+	if err != nil {
+		fatal("Failed to marshal OpenAPI specification", "error", err)
+	}
+
+	// Register metrics
+	app.metrics = newMetrics(dbPool)
+
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go app.runIdempotencyKeyCleanup(jobsCtx, idempotencyCleanupInterval)
+	go app.runHoldExpiry(jobsCtx, holdExpiryInterval)
+	go app.runReconciliation(jobsCtx, reconciliationInterval)
+	go app.runBalanceSnapshots(jobsCtx, balanceSnapshotInterval)
+	go app.runWebhookDispatcher(jobsCtx, webhookDispatchInterval)
+	go app.runWebhookEventCleanup(jobsCtx, webhookCleanupInterval, webhookEventRetention)
+	go app.events.run(jobsCtx, dbPool)
+
+	// Set up and start the server
+	srv := app.newHTTPServer(":" + port)
+
+	slog.Info("Server is listening", "port", port)
 
 	// Start the server in a goroutine
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("FATAL: %v", err)
+			fatal(err.Error())
 		}
 	}()
 
+	// Start the gRPC server in a goroutine if its port is configured
+	var grpcSrv *grpc.Server
+	if grpcPort != "" {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			fatal(err.Error())
+		}
+		grpcSrv = app.newGRPCServer()
+
+		slog.Info("gRPC server is listening", "port", grpcPort)
+
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				fatal(err.Error())
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down the server...")
+	slog.Info("Shutting down the server...")
+	stopJobs()
 	if err := srv.Shutdown(context.Background()); err != nil {
-		log.Fatalf("FATAL: Server forced to shut down: %v", err)
+		fatal("Server forced to shut down", "error", err)
+	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+	slog.Info("Server has been successfully shut down.")
+}
+
+// runReconcileCommand runs a single reconciliation from the command line and exits
+// with a non-zero code if it fails or leaves mismatches uncorrected
+func (app *application) runReconcileCommand(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "write correcting entries for mismatching balances")
+	_ = flags.Parse(args)
+
+	run, err := app.reconcile(context.Background(), *fix)
+	if err != nil {
+		fatal("Reconciliation failed", "error", err)
+	}
+
+	slog.Info("Reconciliation finished",
+		"run_id", run.ID.String(),
+		"wallets_checked", run.WalletsChecked,
+		"mismatches_found", run.MismatchesFound,
+		"mismatches_corrected", run.MismatchesCorrected,
+	)
+	if run.MismatchesFound > run.MismatchesCorrected {
+		os.Exit(1)
+	}
+}
+
+// newHTTPServer returns the HTTP server of the API listening on addr. Routes that may take longer
+// than its write timeout, such as batches and event streams, set their own write deadlines.
+func (app *application) newHTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           app.router(),
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
 	}
-	log.Println("Server has been successfully shut down.")
 }
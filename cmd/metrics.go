@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the Prometheus metrics of the application. A nil *metrics records nothing,
+// so handlers can be tested without it.
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	operations      *prometheus.CounterVec
+	operationAmount *prometheus.CounterVec
+	transactions    *prometheus.CounterVec
+}
+
+// newMetrics registers the metrics of the application, the Go runtime and the process,
+// and the statistics of the database connection pool if it is not nil
+func newMetrics(pool poolStater) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wallet_http_requests_total",
+			Help: "HTTP requests by route pattern and status code.",
+		}, []string{"route", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "wallet_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route pattern and status code. Event streams are observed when they end.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wallet_operations_total",
+			Help: "Committed wallet operations by operation type.",
+		}, []string{"operation_type"}),
+		operationAmount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wallet_operation_amount_total",
+			Help: "Sum of the amounts of committed wallet operations in minor currency units by operation type.",
+		}, []string{"operation_type"}),
+		transactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wallet_operation_transactions_total",
+			Help: "Outcomes of the transactions of deposits, withdrawals and transfers: commit or rollback.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.operations,
+		m.operationAmount,
+		m.transactions,
+	)
+	if pool != nil {
+		m.registry.MustRegister(newPoolCollector(pool))
+	}
+	return m
+}
+
+// instrument counts the requests to the route and observes their latency
+func (m *metrics) instrument(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if m == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		code := strconv.Itoa(rec.status)
+		m.httpRequests.WithLabelValues(pattern, code).Inc()
+		m.httpDuration.WithLabelValues(pattern, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeOperation counts a committed operation and its amount
+func (m *metrics) observeOperation(operationType string, amount int64) {
+	if m == nil {
+		return
+	}
+	m.operations.WithLabelValues(operationType).Inc()
+	m.operationAmount.WithLabelValues(operationType).Add(float64(amount))
+}
+
+// observeTransaction counts the outcome of an operation transaction
+func (m *metrics) observeTransaction(committed bool) {
+	if m == nil {
+		return
+	}
+	result := "rollback"
+	if committed {
+		result = "commit"
+	}
+	m.transactions.WithLabelValues(result).Inc()
+}
+
+func (app *application) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if app.metrics == nil {
+		http.NotFound(w, r)
+		return
+	}
+	promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// statusRecorder passes a response through to the client and keeps its status code
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap gives http.ResponseController access to the underlying response writer, which event streams flush
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// poolStater is the part of *pgxpool.Pool that reports its statistics
+type poolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// poolCollector exports the statistics of the database connection pool at every scrape.
+// pgxpool does not report how many acquires are waiting at the moment, so waiting is exported
+// as the number of acquires that had to wait for a connection and the total time spent acquiring.
+type poolCollector struct {
+	pool poolStater
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	constructing    *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceled        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+func newPoolCollector(pool poolStater) *poolCollector {
+	return &poolCollector{
+		pool:            pool,
+		acquired:        prometheus.NewDesc("wallet_db_pool_acquired_connections", "Connections currently in use.", nil, nil),
+		idle:            prometheus.NewDesc("wallet_db_pool_idle_connections", "Idle connections.", nil, nil),
+		constructing:    prometheus.NewDesc("wallet_db_pool_constructing_connections", "Connections being established.", nil, nil),
+		total:           prometheus.NewDesc("wallet_db_pool_total_connections", "Connections in the pool: acquired, idle and being established.", nil, nil),
+		max:             prometheus.NewDesc("wallet_db_pool_max_connections", "Maximum size of the pool.", nil, nil),
+		acquires:        prometheus.NewDesc("wallet_db_pool_acquires_total", "Successful acquires of a connection.", nil, nil),
+		emptyAcquires:   prometheus.NewDesc("wallet_db_pool_empty_acquires_total", "Acquires that waited for a connection because none was idle.", nil, nil),
+		canceled:        prometheus.NewDesc("wallet_db_pool_canceled_acquires_total", "Acquires canceled while waiting for a connection.", nil, nil),
+		acquireDuration: prometheus.NewDesc("wallet_db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationMetrics(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	route := "POST /api/v1/wallets/{wallet_id}"
+
+	tests := []struct {
+		name               string
+		mockBalance        int64
+		body               string
+		expectedCode       int
+		expectedOperations float64
+		expectedAmount     float64
+		expectedCommits    float64
+		expectedRollbacks  float64
+		operationType      string
+	}{
+		{
+			name:               "Deposit",
+			mockBalance:        100,
+			body:               `{"operation_type":"deposit","amount":50}`,
+			expectedCode:       http.StatusOK,
+			expectedOperations: 1,
+			expectedAmount:     50,
+			expectedCommits:    1,
+			operationType:      operations.Deposit,
+		},
+		{
+			name:              "Withdrawal with insufficient funds",
+			mockBalance:       50,
+			body:              `{"operation_type":"withdraw","amount":100}`,
+			expectedCode:      http.StatusPaymentRequired,
+			expectedRollbacks: 1,
+			operationType:     operations.Withdraw,
+		},
+		{
+			name:          "Invalid amount",
+			body:          `{"operation_type":"withdraw","amount":0}`,
+			expectedCode:  http.StatusBadRequest,
+			operationType: operations.Withdraw,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Balance: tc.mockBalance}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries and fresh metrics
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+				metrics: newMetrics(nil),
+			}
+
+			// Send the request through the router, which instruments every route
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			app.router().ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedCode, w.Code)
+
+			// Check the request, operation and transaction metrics
+			m := app.metrics
+			assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues(route, strconv.Itoa(tc.expectedCode))))
+			assert.Equal(t, 1, testutil.CollectAndCount(m.httpDuration))
+			assert.Equal(t, tc.expectedOperations, testutil.ToFloat64(m.operations.WithLabelValues(tc.operationType)))
+			assert.Equal(t, tc.expectedAmount, testutil.ToFloat64(m.operationAmount.WithLabelValues(tc.operationType)))
+			assert.Equal(t, tc.expectedCommits, testutil.ToFloat64(m.transactions.WithLabelValues("commit")))
+			assert.Equal(t, tc.expectedRollbacks, testutil.ToFloat64(m.transactions.WithLabelValues("rollback")))
+		})
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	// The pool connects lazily, so its statistics are available without a database
+	pool, err := pgxpool.New(t.Context(), "postgres://wallet@localhost:1/wallet")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	app := &application{metrics: newMetrics(pool)}
+	app.metrics.observeOperation(operations.Withdraw, 30)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	contract(t, app.router().ServeHTTP).ServeHTTP(w, req)
+
+	res := w.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, _ := io.ReadAll(res.Body)
+	for _, line := range []string{
+		`wallet_operations_total{operation_type="withdraw"} 1`,
+		`wallet_operation_amount_total{operation_type="withdraw"} 30`,
+		`wallet_db_pool_acquired_connections 0`,
+		`wallet_db_pool_idle_connections 0`,
+		`wallet_db_pool_empty_acquires_total 0`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
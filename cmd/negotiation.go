@@ -0,0 +1,90 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types of responses
+const (
+	mediaTypeText = "text/plain"
+	mediaTypeJSON = "application/json"
+)
+
+// negotiate returns the offered media type the client prefers according to its Accept header.
+// Offers are listed in the server's order of preference, which breaks ties, so the first offer
+// is returned when the header is missing, matches nothing or accepts everything equally.
+func negotiate(r *http.Request, offers ...string) string {
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(r.Header.Values("Accept"), offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the Accept header values give to the media type:
+// the q parameter of the most specific media range that matches it, or 0 if none does
+func acceptQuality(accept []string, mediaType string) float64 {
+	if len(accept) == 0 {
+		return 1
+	}
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, header := range accept {
+		for _, value := range strings.Split(header, ",") {
+			mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+			if err != nil {
+				continue
+			}
+			rangeType, rangeSubtype, _ := strings.Cut(mediaRange, "/")
+
+			var s int
+			switch {
+			case rangeType == typ && rangeSubtype == subtype:
+				s = 2
+			case rangeType == typ && rangeSubtype == "*":
+				s = 1
+			case rangeType == "*" && rangeSubtype == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s <= specificity {
+				continue
+			}
+
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+			}
+			quality, specificity = q, s
+		}
+	}
+	return quality
+}
+
+// wantsJSON reports whether the client prefers JSON to the plain text fallback
+func wantsJSON(r *http.Request) bool {
+	return negotiate(r, mediaTypeText, mediaTypeJSON) == mediaTypeJSON
+}
+
+// writeNegotiated writes the payload as JSON if the client prefers it and the text otherwise
+func writeNegotiated(w http.ResponseWriter, r *http.Request, status int, payload any, text any) {
+	w.Header().Add("Vary", "Accept")
+	if wantsJSON(r) {
+		writeJSON(w, status, payload)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeText)
+	w.WriteHeader(status)
+	writeResponse(w, text)
+}
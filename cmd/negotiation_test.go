@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   []string
+		expected string
+	}{
+		{name: "No Accept header", expected: mediaTypeText},
+		{name: "Anything", accept: []string{"*/*"}, expected: mediaTypeText},
+		{name: "JSON", accept: []string{"application/json"}, expected: mediaTypeJSON},
+		{name: "Plain text", accept: []string{"text/plain"}, expected: mediaTypeText},
+		{name: "Browser", accept: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, expected: mediaTypeText},
+		{name: "JSON preferred", accept: []string{"text/plain;q=0.5, application/json"}, expected: mediaTypeJSON},
+		{name: "Plain text preferred", accept: []string{"application/json;q=0.5, text/*"}, expected: mediaTypeText},
+		{name: "JSON with a wildcard fallback", accept: []string{"application/json, */*;q=0.1"}, expected: mediaTypeJSON},
+		{name: "Plain text excluded", accept: []string{"text/plain;q=0, */*"}, expected: mediaTypeJSON},
+		{name: "Several headers", accept: []string{"text/plain;q=0.2", "application/json;q=0.4"}, expected: mediaTypeJSON},
+		{name: "Nothing acceptable", accept: []string{"image/png"}, expected: mediaTypeText},
+		{name: "Malformed header", accept: []string{"application/json;q=high"}, expected: mediaTypeText},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for _, value := range tc.accept {
+				req.Header.Add("Accept", value)
+			}
+
+			assert.Equal(t, tc.expected, negotiate(req, mediaTypeText, mediaTypeJSON))
+		})
+	}
+}
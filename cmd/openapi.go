@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleGetOpenAPI serves the OpenAPI specification of the API as JSON
+func (app *application) handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	writeResponse(w, string(app.openAPISpec))
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadSpec loads the OpenAPI specification once for all tests
+var loadSpec = sync.OnceValues(openapi.Load)
+
+// contract wraps the handler with the OpenAPI validator, so that a request the handler accepts
+// against the specification or a response that does not match it fails the test
+func contract(t *testing.T, next http.HandlerFunc) http.Handler {
+	t.Helper()
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+	return openapi.NewValidator(doc, func(r *http.Request, err error) {
+		t.Errorf("OpenAPI contract violation: %s %s: %v", r.Method, r.URL, err)
+	}).Middleware(next)
+}
+
+func TestRoutesMatchSpecification(t *testing.T) {
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+
+	app := &application{}
+	var patterns []string
+	for _, route := range app.routes() {
+		patterns = append(patterns, route.pattern)
+	}
+
+	assert.ElementsMatch(t, patterns, openapi.Patterns(doc), "routes registered on the mux and operations in the specification differ")
+}
+
+// TestSecuredRoutes checks that every operation the specification secures with basic authentication
+// rejects requests without credentials before doing anything
+func TestSecuredRoutes(t *testing.T) {
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+
+	mockDB := &mocks.DBTX{}
+	app := &application{db: mockDB, queries: database.New(mockDB)}
+	app.auth.username, app.auth.password = "admin", "secret"
+	handler := app.router()
+
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			if operation.Security == nil || len(*operation.Security) == 0 {
+				continue
+			}
+			t.Run(method+" "+path, func(t *testing.T) {
+				target := pathWildcard.ReplaceAllString(path, "fe6403a7-8b42-4449-abe6-a8508199a0d4")
+				req := httptest.NewRequest(method, target, strings.NewReader("{}"))
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			})
+		}
+	}
+}
+
+// pathWildcard matches the wildcards of a path in the specification
+var pathWildcard = regexp.MustCompile(`\{[^}]+\}`)
+
+func TestHandleGetOpenAPI(t *testing.T) {
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+	spec, err := doc.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal OpenAPI specification: %v", err)
+	}
+
+	app := &application{openAPISpec: spec}
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	contract(t, app.router().ServeHTTP).ServeHTTP(w, req)
+
+	res := w.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var body struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	assert.Equal(t, "3.0.3", body.OpenAPI)
+	assert.Len(t, body.Paths, doc.Paths.Len())
+}
+
+func TestContractReportsViolations(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		handler        http.HandlerFunc
+		expectedReport bool
+	}{
+		{
+			name:   "Documented response",
+			method: "GET",
+			target: "/api/v1/healthz",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+			},
+		},
+		{
+			name:   "Undocumented status code",
+			method: "GET",
+			target: "/api/v1/healthz",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			},
+			expectedReport: true,
+		},
+		{
+			name:   "Response body does not match schema",
+			method: "GET",
+			target: "/api/v1/healthz",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]string{"state": "ok"})
+			},
+			expectedReport: true,
+		},
+		{
+			name:   "Invalid request accepted",
+			method: "GET",
+			target: "/api/v1/wallets/not-a-uuid/credit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, creditResponse{})
+			},
+			expectedReport: true,
+		},
+		{
+			name:   "Invalid request rejected",
+			method: "GET",
+			target: "/api/v1/wallets/not-a-uuid/credit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+			},
+		},
+		{
+			name:   "Undocumented route",
+			method: "GET",
+			target: "/api/v1/status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+			},
+			expectedReport: true,
+		},
+		{
+			name:    "Unknown route rejected",
+			method:  "GET",
+			target:  "/api/v1/status",
+			handler: http.NotFound,
+		},
+	}
+
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var reports []error
+			validator := openapi.NewValidator(doc, func(r *http.Request, err error) {
+				reports = append(reports, err)
+			})
+
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			w := httptest.NewRecorder()
+			validator.Middleware(tc.handler).ServeHTTP(w, req)
+
+			if tc.expectedReport {
+				assert.NotEmpty(t, reports)
+			} else {
+				assert.Empty(t, reports)
+			}
+		})
+	}
+}
+
+// TestOpenAPIContract sends a request to every route through the router and checks the requests
+// and responses against the specification. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestOpenAPIContract(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.defaultCurrency = "RUB"
+	app.idempotencyKeyTTL = time.Hour
+	app.holdTTL = time.Hour
+	app.auth.username, app.auth.password = "admin", "secret"
+
+	doc, err := loadSpec()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI specification: %v", err)
+	}
+	app.openAPISpec, err = doc.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal OpenAPI specification: %v", err)
+	}
+	handler := contract(t, app.router().ServeHTTP)
+
+	// send sends a request with JSON and admin credentials, checks the status code and decodes the JSON response
+	send := func(method, target, body string, expectedCode int, response any) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		req.SetBasicAuth(app.auth.username, app.auth.password)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if !assert.Equal(t, expectedCode, w.Code, "%s %s: %s", method, target, w.Body.String()) {
+			t.FailNow()
+		}
+		if response != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+				t.Fatalf("%s %s: failed to decode response body: %v", method, target, err)
+			}
+		}
+		return w
+	}
+
+	var wallet, target createWalletResponse
+	send("POST", "/api/v1/wallets", `{"currency":"RUB"}`, http.StatusCreated, &wallet)
+	send("POST", "/api/v1/wallets", "", http.StatusCreated, &target)
+	walletPath, targetPath := "/api/v1/wallets/"+wallet.ID.String(), "/api/v1/wallets/"+target.ID.String()
+
+	// Wallets and balances
+	send("POST", walletPath, `{"operation_type":"deposit","amount":1000}`, http.StatusOK, nil)
+	send("POST", walletPath, `{"operation_type":"withdraw","amount":100}`, http.StatusOK, nil)
+	send("POST", walletPath, `{"operation_type":"transfer","amount":100,"target_wallet_id":"`+target.ID.String()+`"}`, http.StatusOK, nil)
+	send("POST", walletPath, `{"operation_type":"withdraw","amount":1000000}`, http.StatusPaymentRequired, nil)
+	send("POST", walletPath, `{"operation_type":"deposit","amount":100,"currency":"USD"}`, http.StatusUnprocessableEntity, nil)
+	send("GET", walletPath, "", http.StatusOK, nil)
+	send("GET", walletPath+"?balance=available", "", http.StatusOK, nil)
+	send("GET", "/api/v1/wallets/not-a-uuid", "", http.StatusBadRequest, nil)
+	send("GET", "/api/v1/wallets/"+newUUID().String(), "", http.StatusNotFound, nil)
+	send("GET", "/api/v1/wallets?limit=2&sort=balance&order=desc&include_total=true", "", http.StatusOK, nil)
+	send("GET", "/api/v1/wallets/not-a-uuid/events", "", http.StatusBadRequest, nil)
+
+	// Idempotent requests are replayed
+	req := httptest.NewRequest("POST", "/api/v1/wallets", nil)
+	req.Header.Set("Idempotency-Key", newUUID().String())
+	for range 2 {
+		handler.ServeHTTP(httptest.NewRecorder(), req.Clone(req.Context()))
+	}
+
+	// Operations and reversals
+	var page operationsPage
+	send("GET", walletPath+"/operations?operation_type=deposit&limit=10", "", http.StatusOK, &page)
+	if !assert.NotEmpty(t, page.Operations) {
+		t.FailNow()
+	}
+	send("POST", walletPath+"/operations/"+page.Operations[0].ID.String()+"/reverse", `{"amount":10}`, http.StatusCreated, nil)
+	send("POST", "/api/v1/operations/batch", `{"mode":"best_effort","operations":[`+
+		`{"wallet_id":"`+wallet.ID.String()+`","operation_type":"deposit","amount":10},`+
+		`{"wallet_id":"`+target.ID.String()+`","operation_type":"withdraw","amount":1000000}]}`, http.StatusOK, nil)
+	send("POST", "/api/v1/operations/batch", `{"operations":[`+
+		`{"wallet_id":"`+target.ID.String()+`","operation_type":"withdraw","amount":1000000}]}`, http.StatusUnprocessableEntity, nil)
+
+	// Credit and withdrawal limits
+	send("GET", walletPath+"/credit", "", http.StatusOK, nil)
+	send("PUT", walletPath+"/credit", `{"credit_limit":100}`, http.StatusOK, nil)
+	send("GET", walletPath+"/limits", "", http.StatusOK, nil)
+	send("PUT", walletPath+"/limits", `{"daily_withdrawal_limit":500,"monthly_withdrawal_limit":null}`, http.StatusOK, nil)
+	send("POST", walletPath, `{"operation_type":"withdraw","amount":600}`, http.StatusForbidden, nil)
+	send("DELETE", walletPath+"/limits", "", http.StatusNoContent, nil)
+
+	// Holds
+	var hold database.Hold
+	send("POST", walletPath+"/holds", `{"amount":50,"ttl_seconds":600}`, http.StatusCreated, &hold)
+	send("GET", walletPath+"/holds/"+hold.ID.String(), "", http.StatusOK, nil)
+	send("POST", walletPath+"/holds/"+hold.ID.String()+"/capture", `{"amount":20}`, http.StatusOK, nil)
+	send("POST", walletPath+"/holds/"+hold.ID.String()+"/void", "", http.StatusConflict, nil)
+	send("POST", walletPath+"/holds", `{"amount":10}`, http.StatusCreated, &hold)
+	send("POST", walletPath+"/holds/"+hold.ID.String()+"/void", "", http.StatusOK, nil)
+
+	// Wallet status
+	send("POST", targetPath+"/freeze", "", http.StatusOK, nil)
+	send("POST", targetPath+"/freeze", "", http.StatusConflict, nil)
+	send("POST", targetPath+"/unfreeze", "", http.StatusOK, nil)
+	var empty createWalletResponse
+	send("POST", "/api/v1/wallets", "", http.StatusCreated, &empty)
+	send("POST", "/api/v1/wallets/"+empty.ID.String()+"/close", "", http.StatusOK, nil)
+	send("POST", "/api/v1/wallets", "", http.StatusCreated, &empty)
+	send("DELETE", "/api/v1/wallets/"+empty.ID.String(), "", http.StatusNoContent, nil)
+
+	// Administration
+	var run database.ReconciliationRun
+	send("POST", "/api/v1/admin/reconciliation-runs", "", http.StatusCreated, &run)
+	send("GET", "/api/v1/admin/reconciliation-runs?limit=5", "", http.StatusOK, nil)
+	send("GET", "/api/v1/admin/reconciliation-runs/"+run.ID.String(), "", http.StatusOK, nil)
+	var subscription database.WebhookSubscription
+	send("POST", "/api/v1/admin/webhook-subscriptions", `{"url":"https://example.com/webhooks","wallet_id":"`+wallet.ID.String()+`"}`, http.StatusCreated, &subscription)
+	send("GET", "/api/v1/admin/webhook-subscriptions", "", http.StatusOK, nil)
+	send("DELETE", "/api/v1/admin/webhook-subscriptions/"+subscription.ID.String(), "", http.StatusNoContent, nil)
+	send("GET", "/api/v1/admin/webhook-deliveries?status=dead&limit=5", "", http.StatusOK, nil)
+	send("POST", "/api/v1/admin/webhook-deliveries/"+newUUID().String()+"/redeliver", "", http.StatusNotFound, nil)
+
+	// Service
+	send("GET", "/api/v1/healthz", "", http.StatusOK, nil)
+	send("GET", "/api/v1/openapi.json", "", http.StatusOK, nil)
+	send("GET", "/api/v1/unknown", "", http.StatusNotFound, nil)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// operationsPage is a page of wallet operations, newest first
+type operationsPage struct {
+	Operations []database.Operation `json:"operations"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+func (app *application) handleGetOperations(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet UUID from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Parse pagination and filter parameters
+	params := database.GetOperationsParams{WalletID: walletUUID}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	// Fetch one extra row to find out whether there is a next page
+	params.RowLimit = limit + 1
+
+	if operationType := r.URL.Query().Get("operation_type"); operationType != "" {
+		if operationType != operations.Deposit && operationType != operations.Withdraw {
+			writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: operation_type must be \"deposit\" or \"withdraw\"", nil)
+			return
+		}
+		params.OperationType = pgtype.Text{String: operationType, Valid: true}
+	}
+
+	if params.MinAmount, err = parseInt8Param(r, "min_amount"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if params.MaxAmount, err = parseInt8Param(r, "max_amount"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if params.CreatedFrom, err = parseTimestampParam(r, "from"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if params.CreatedTo, err = parseTimestampParam(r, "to"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		var cursor operationsCursor
+		err = decodeCursor(value, &cursor)
+		if err != nil || !cursor.ID.Valid {
+			writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: malformed cursor", nil)
+			return
+		}
+		params.CursorCreatedAt = pgtype.Timestamp{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = cursor.ID
+	}
+
+	// Make sure the wallet exists, so an unknown wallet is not reported as having no operations
+	_, err = app.queries.GetBalance(r.Context(), walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	// Get operations from the database
+	ops, err := app.queries.GetOperations(r.Context(), params)
+	if err != nil {
+		serverError(w, r, "Failed to get operations", err)
+		return
+	}
+
+	page := operationsPage{Operations: ops}
+	if page.Operations == nil {
+		page.Operations = []database.Operation{}
+	}
+
+	// Point the cursor at the last operation of the page if there are more operations
+	if len(ops) > int(limit) {
+		page.Operations = ops[:limit]
+		last := page.Operations[limit-1]
+		nextCursor, err := encodeCursor(operationsCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID})
+		if err != nil {
+			serverError(w, r, "Failed to encode cursor", err)
+			return
+		}
+		page.NextCursor = &nextCursor
+	}
+
+	// Write response with operations
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGetOperations(t *testing.T) {
+	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+
+	tests := []struct {
+		name            string
+		walletID        string
+		query           string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "No operations",
+			walletID:     validUUID,
+			query:        "?operation_type=deposit&min_amount=10&max_amount=100&from=2025-01-01T00:00:00Z",
+			mockError:    nil,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"operations\":[],\"next_cursor\":null}\n",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+		{
+			name:            "Invalid limit",
+			walletID:        validUUID,
+			query:           "?limit=0",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: limit must be an integer between 1 and 1000",
+		},
+		{
+			name:            "Invalid operation type",
+			walletID:        validUUID,
+			query:           "?operation_type=transfer",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: operation_type must be \"deposit\" or \"withdraw\"",
+		},
+		{
+			name:            "Invalid amount",
+			walletID:        validUUID,
+			query:           "?min_amount=ten",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: min_amount must be an integer",
+		},
+		{
+			name:            "Invalid time window",
+			walletID:        validUUID,
+			query:           "?to=yesterday",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: to must be an RFC 3339 timestamp",
+		},
+		{
+			name:            "Invalid cursor",
+			walletID:        validUUID,
+			query:           "?cursor=not-a-cursor",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: malformed cursor",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries
+			app := &application{
+				queries: database.New(mockDB),
+			}
+
+			// Create and configure a new HTTP request
+			req := httptest.NewRequest("GET", "/api/v1/wallets/"+tc.walletID+"/operations"+tc.query, nil)
+			req.SetPathValue("wallet_id", tc.walletID)
+
+			// Create a new response recorder
+			w := httptest.NewRecorder()
+
+			// Call the handler
+			contract(t, app.handleGetOperations).ServeHTTP(w, req)
+
+			// Check the response status code
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestOperationsCursor(t *testing.T) {
+	id := pgtype.UUID{}
+	if err := id.Scan("fe6403a7-8b42-4449-abe6-a8508199a0d4"); err != nil {
+		t.Fatalf("Failed to parse UUID: %v", err)
+	}
+	cursor := operationsCursor{CreatedAt: time.Date(2025, 1, 1, 12, 30, 0, 123456000, time.UTC), ID: id}
+
+	encoded, err := encodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("Failed to encode cursor: %v", err)
+	}
+
+	var decoded operationsCursor
+	err = decodeCursor(encoded, &decoded)
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	assert.Equal(t, cursor, decoded)
+}
+
+func TestWalletsCursor(t *testing.T) {
+	id := pgtype.UUID{}
+	if err := id.Scan("fe6403a7-8b42-4449-abe6-a8508199a0d4"); err != nil {
+		t.Fatalf("Failed to parse UUID: %v", err)
+	}
+	wallet := database.Wallet{
+		ID:        id,
+		Balance:   -250,
+		CreatedAt: pgtype.Timestamp{Time: time.Date(2025, 1, 1, 12, 30, 0, 123456000, time.UTC), Valid: true},
+		UpdatedAt: pgtype.Timestamp{Time: time.Date(2025, 2, 1, 8, 0, 0, 0, time.UTC), Valid: true},
+	}
+
+	tests := []struct {
+		name     string
+		params   database.ListWalletsParams
+		expected walletsCursor
+	}{
+		{
+			name:     "By balance",
+			params:   database.ListWalletsParams{SortBy: "balance", Descending: true},
+			expected: walletsCursor{SortBy: "balance", Descending: true, Balance: -250, ID: id},
+		},
+		{
+			name:     "By creation time",
+			params:   database.ListWalletsParams{SortBy: "created_at"},
+			expected: walletsCursor{SortBy: "created_at", Time: wallet.CreatedAt.Time, ID: id},
+		},
+		{
+			name:     "By update time",
+			params:   database.ListWalletsParams{SortBy: "updated_at"},
+			expected: walletsCursor{SortBy: "updated_at", Time: wallet.UpdatedAt.Time, ID: id},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cursor := newWalletsCursor(tc.params, wallet)
+			assert.Equal(t, tc.expected, cursor)
+
+			encoded, err := encodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("Failed to encode cursor: %v", err)
+			}
+
+			var decoded walletsCursor
+			err = decodeCursor(encoded, &decoded)
+			if err != nil {
+				t.Fatalf("Failed to decode cursor: %v", err)
+			}
+			assert.Equal(t, cursor, decoded)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+)
+
+// operationsCursor points at the last operation of a page in (created_at, id) order
+type operationsCursor struct {
+	CreatedAt time.Time   `json:"created_at"`
+	ID        pgtype.UUID `json:"id"`
+}
+
+// walletsCursor points at the last wallet of a page in (sort column, id) order.
+// Balance is set when sorting by balance, Time when sorting by created_at or updated_at.
+type walletsCursor struct {
+	SortBy     string      `json:"sort_by"`
+	Descending bool        `json:"descending,omitempty"`
+	Balance    int64       `json:"balance,omitempty"`
+	Time       time.Time   `json:"time,omitzero"`
+	ID         pgtype.UUID `json:"id"`
+}
+
+// newWalletsCursor returns the cursor pointing at the wallet in the sort order of params
+func newWalletsCursor(params database.ListWalletsParams, wallet database.Wallet) walletsCursor {
+	cursor := walletsCursor{SortBy: params.SortBy, Descending: params.Descending, ID: wallet.ID}
+	switch params.SortBy {
+	case "balance":
+		cursor.Balance = wallet.Balance
+	case "created_at":
+		cursor.Time = wallet.CreatedAt.Time
+	case "updated_at":
+		cursor.Time = wallet.UpdatedAt.Time
+	}
+	return cursor
+}
+
+// encodeCursor turns a cursor into an opaque URL-safe string
+func encodeCursor(cursor any) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(s string, cursor any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cursor)
+}
+
+// parseLimit reads the page size from the "limit" query parameter
+func parseLimit(r *http.Request) (int32, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, errors.New("limit must be an integer between 1 and 1000")
+	}
+	return int32(limit), nil
+}
+
+// parseInt8Param reads an optional integer query parameter
+func parseInt8Param(r *http.Request, name string) (pgtype.Int8, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return pgtype.Int8{}, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return pgtype.Int8{}, errors.New(name + " must be an integer")
+	}
+	return pgtype.Int8{Int64: n, Valid: true}, nil
+}
+
+// parseTimestampParam reads an optional RFC 3339 timestamp query parameter
+func parseTimestampParam(r *http.Request, name string) (pgtype.Timestamp, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return pgtype.Timestamp{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return pgtype.Timestamp{}, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return pgtype.Timestamp{Time: t.UTC(), Valid: true}, nil
+}
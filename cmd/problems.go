@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// mediaTypeProblem is the media type of problem details responses (RFC 9457, formerly RFC 7807)
+const mediaTypeProblem = "application/problem+json"
+
+// problemTypeURI prefixes the code of a problem type to make its type URI
+const problemTypeURI = "urn:problem-type:wallet:"
+
+// problemType is an entry of the error catalogue: a stable code that clients can match on,
+// the status code it is returned with and a title that does not change between occurrences
+type problemType struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+// Error catalogue. Codes are part of the API: they are never renamed or reused for another problem.
+var (
+	problemInvalidRequestBody     = problemType{"invalid_request_body", http.StatusBadRequest, "Invalid request body"}
+	problemInvalidQueryParameter  = problemType{"invalid_query_parameter", http.StatusBadRequest, "Invalid query parameter"}
+	problemInvalidHeader          = problemType{"invalid_header", http.StatusBadRequest, "Invalid header"}
+	problemInvalidWalletID        = problemType{"invalid_wallet_id", http.StatusBadRequest, "Invalid wallet ID"}
+	problemInvalidID              = problemType{"invalid_id", http.StatusBadRequest, "Invalid ID"}
+	problemInvalidAmount          = problemType{"invalid_amount", http.StatusBadRequest, "Invalid amount"}
+	problemInvalidOperationType   = problemType{"invalid_operation_type", http.StatusBadRequest, "Unsupported operation type"}
+	problemUnsupportedCurrency    = problemType{"unsupported_currency", http.StatusBadRequest, "Unsupported currency"}
+	problemSameWallet             = problemType{"same_wallet", http.StatusBadRequest, "Cannot transfer to the same wallet"}
+	problemInvalidLimit           = problemType{"invalid_limit", http.StatusBadRequest, "Invalid limit"}
+	problemInvalidTTL             = problemType{"invalid_ttl", http.StatusBadRequest, "Invalid TTL"}
+	problemInvalidBatch           = problemType{"invalid_batch", http.StatusBadRequest, "Invalid batch"}
+	problemInvalidURL             = problemType{"invalid_url", http.StatusBadRequest, "Invalid URL"}
+	problemUnauthorized           = problemType{"unauthorized", http.StatusUnauthorized, "Unauthorized"}
+	problemInsufficientFunds      = problemType{"insufficient_funds", http.StatusPaymentRequired, "Insufficient funds"}
+	problemWithdrawalLimit        = problemType{"withdrawal_limit_exceeded", http.StatusForbidden, "Withdrawal limit exceeded"}
+	problemWalletNotFound         = problemType{"wallet_not_found", http.StatusNotFound, "Wallet not found"}
+	problemTargetWalletNotFound   = problemType{"target_wallet_not_found", http.StatusNotFound, "Target wallet not found"}
+	problemOperationNotFound      = problemType{"operation_not_found", http.StatusNotFound, "Operation not found"}
+	problemHoldNotFound           = problemType{"hold_not_found", http.StatusNotFound, "Hold not found"}
+	problemReconciliationNotFound = problemType{"reconciliation_run_not_found", http.StatusNotFound, "Reconciliation run not found"}
+	problemSubscriptionNotFound   = problemType{"webhook_subscription_not_found", http.StatusNotFound, "Webhook subscription not found"}
+	problemDeliveryNotFound       = problemType{"webhook_delivery_not_found", http.StatusNotFound, "Webhook delivery not found"}
+	problemWalletClosed           = problemType{"wallet_closed", http.StatusConflict, "Wallet is closed"}
+	problemWalletFrozen           = problemType{"wallet_frozen", http.StatusConflict, "Wallet is frozen"}
+	problemTargetWalletClosed     = problemType{"target_wallet_closed", http.StatusConflict, "Target wallet is closed"}
+	problemWalletStatusUnchanged  = problemType{"wallet_status_unchanged", http.StatusConflict, "Wallet already has the status"}
+	problemWalletNotEmpty         = problemType{"wallet_not_empty", http.StatusConflict, "Wallet is not empty"}
+	problemCreditInUse            = problemType{"credit_limit_below_usage", http.StatusConflict, "Credit limit is less than the credit in use"}
+	problemHoldNotActive          = problemType{"hold_not_active", http.StatusConflict, "Hold is not active"}
+	problemAlreadyReversed        = problemType{"operation_already_reversed", http.StatusConflict, "Operation is already fully reversed"}
+	problemDeliveryPending        = problemType{"webhook_delivery_pending", http.StatusConflict, "Webhook delivery is already pending"}
+	problemIdempotencyKeyExpired  = problemType{"idempotency_key_expired", http.StatusConflict, "Idempotency key has expired"}
+	problemIdempotencyInProgress  = problemType{"idempotency_key_in_progress", http.StatusConflict, "Request with the idempotency key is in progress"}
+	problemIdempotencyKeyReused   = problemType{"idempotency_key_reused", http.StatusUnprocessableEntity, "Idempotency key has been used with a different request"}
+	problemCurrencyMismatch       = problemType{"currency_mismatch", http.StatusUnprocessableEntity, "Currency mismatch"}
+	problemBalanceOverflow        = problemType{"balance_overflow", http.StatusUnprocessableEntity, "Operation would overflow the balance"}
+	problemCaptureExceedsHold     = problemType{"capture_exceeds_hold", http.StatusUnprocessableEntity, "Capture amount exceeds held amount"}
+	problemReversalExceedsAmount  = problemType{"reversal_exceeds_amount", http.StatusUnprocessableEntity, "Reversal amount exceeds the amount left to reverse"}
+	problemNotReversible          = problemType{"operation_not_reversible", http.StatusUnprocessableEntity, "Operation cannot be reversed"}
+	problemInternal               = problemType{"internal_error", http.StatusInternalServerError, "Internal server error"}
+)
+
+// problem is an occurrence of a problem type: Detail explains this occurrence to a human,
+// and Fields are extension members with the details clients may act on, such as the current balance
+type problem struct {
+	problemType
+	Detail string
+	Fields map[string]any
+}
+
+// newProblem returns a problem of the given type
+func newProblem(typ problemType, detail string, fields map[string]any) *problem {
+	return &problem{problemType: typ, Detail: detail, Fields: fields}
+}
+
+// Error makes a problem an error, so that the business logic shared by the HTTP and gRPC servers can return it
+func (p *problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Detail
+}
+
+// write writes the problem as a problem details response
+func (p *problem) write(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, p.problemType, p.Detail, p.Fields)
+}
+
+// writeProblem writes a problem details response. The standard members cannot be overwritten by the fields.
+func writeProblem(w http.ResponseWriter, r *http.Request, typ problemType, detail string, fields map[string]any) {
+	body := make(map[string]any, len(fields)+6)
+	for name, value := range fields {
+		body[name] = value
+	}
+	body["type"] = problemTypeURI + typ.Code
+	body["title"] = typ.Title
+	body["status"] = typ.Status
+	body["code"] = typ.Code
+	if detail != "" {
+		body["detail"] = detail
+	}
+	body["instance"] = r.URL.Path
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to marshal problem into JSON", "error", err)
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeProblem)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(typ.Status)
+	writeResponse(w, string(data))
+}
+
+// serverError logs a failure to handle the request and responds with an internal error.
+// The log line carries a summary of the error rather than its text, which may contain data from the database.
+func serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	slog.ErrorContext(r.Context(), msg, "method", r.Method, "path", r.URL.Path, "error", errorSummary(err))
+	writeProblem(w, r, problemInternal, msg, nil)
+}
+
+// failure is an unexpected error of the business logic: Msg describes the failed step to the client,
+// and Err is the cause, which is only logged
+type failure struct {
+	Msg string
+	Err error
+}
+
+// internalError returns a failure of the step described by msg
+func internalError(msg string, err error) error {
+	return &failure{Msg: msg, Err: err}
+}
+
+func (f *failure) Error() string {
+	return f.Msg + ": " + f.Err.Error()
+}
+
+func (f *failure) Unwrap() error {
+	return f.Err
+}
+
+// writeError responds with the problem of a client error or with an internal error for any other error
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var p *problem
+	var f *failure
+	switch {
+	case errors.As(err, &p):
+		p.write(w, r)
+	case errors.As(err, &f):
+		serverError(w, r, f.Msg, f.Err)
+	default:
+		serverError(w, r, problemInternal.Title, err)
+	}
+}
+
+// errorSummary describes an error without its message: the SQLSTATE code and the constraint
+// of a database error, the cause of a canceled request, or the type of any other error
+func errorSummary(err error) string {
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		return "no error"
+	case errors.As(err, &pgErr):
+		summary := "SQLSTATE " + pgErr.Code
+		if pgErr.ConstraintName != "" {
+			summary += ", constraint " + pgErr.ConstraintName
+		}
+		return summary
+	case errors.Is(err, context.Canceled):
+		return context.Canceled.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return context.DeadlineExceeded.Error()
+	}
+
+	// Wrapped errors are described by their innermost cause
+	types := []string{fmt.Sprintf("%T", err)}
+	for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+		types = append(types, fmt.Sprintf("%T", cause))
+	}
+	return strings.Join(types, " -> ")
+}
+
+// insufficientFunds is the problem of debiting more than the available balance; action names the debit,
+// such as "withdraw" or "transfer"
+func insufficientFunds(action string, available, amount int64) *problem {
+	return newProblem(problemInsufficientFunds,
+		fmt.Sprintf("Insufficient funds to %s: balance %d, trying to %s %d", action, available, action, amount),
+		map[string]any{"available": available, "amount": amount})
+}
+
+// balanceOverflow is the problem of an operation whose result does not fit in the balance
+func balanceOverflow(balance, amount int64) *problem {
+	return newProblem(problemBalanceOverflow,
+		fmt.Sprintf("Operation would overflow wallet balance: balance %d, amount %d", balance, amount),
+		map[string]any{"balance": balance, "amount": amount})
+}
+
+// currencyMismatch is the problem of an operation in a currency other than the wallet's
+func currencyMismatch(walletCurrency, requestCurrency, what string) *problem {
+	return newProblem(problemCurrencyMismatch,
+		fmt.Sprintf("Currency mismatch: wallet currency is %s, %s currency is %s", walletCurrency, what, requestCurrency),
+		map[string]any{"wallet_currency": walletCurrency, "currency": requestCurrency})
+}
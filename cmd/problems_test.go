@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertProblem checks that the response is a problem details response with the code and the detail
+func assertProblem(t *testing.T, res *http.Response, code, detail string) {
+	t.Helper()
+	assert.Equal(t, mediaTypeProblem, res.Header.Get("Content-Type"))
+
+	var body map[string]any
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("Failed to decode problem details: %v", err)
+	}
+	assert.Equal(t, code, body["code"])
+	assert.Equal(t, problemTypeURI+code, body["type"])
+	assert.EqualValues(t, res.StatusCode, body["status"])
+	assert.Equal(t, detail, body["detail"])
+}
+
+func TestWriteProblem(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/wallets/fe6403a7-8b42-4449-abe6-a8508199a0d4", nil)
+	w := httptest.NewRecorder()
+
+	insufficientFunds("withdraw", 50, 100).write(w, req)
+
+	res := w.Result()
+	assert.Equal(t, http.StatusPaymentRequired, res.StatusCode)
+	assert.Equal(t, mediaTypeProblem, res.Header.Get("Content-Type"))
+
+	body, _ := io.ReadAll(res.Body)
+	assert.JSONEq(t, `{
+		"type": "urn:problem-type:wallet:insufficient_funds",
+		"title": "Insufficient funds",
+		"status": 402,
+		"code": "insufficient_funds",
+		"detail": "Insufficient funds to withdraw: balance 50, trying to withdraw 100",
+		"instance": "/api/v1/wallets/fe6403a7-8b42-4449-abe6-a8508199a0d4",
+		"available": 50,
+		"amount": 100
+	}`, string(body))
+
+	// Extension members cannot overwrite the standard ones
+	w = httptest.NewRecorder()
+	writeProblem(w, req, problemWalletNotFound, "Wallet not found", map[string]any{"status": 200, "code": "ok"})
+	res = w.Result()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assertProblem(t, res, problemWalletNotFound.Code, "Wallet not found")
+}
+
+func TestErrorSummary(t *testing.T) {
+	pgErr := &pgconn.PgError{
+		Code:           "23514",
+		Message:        `new row for relation "wallets" violates check constraint "wallets_balance_check"`,
+		Detail:         "Failing row contains (fe6403a7-8b42-4449-abe6-a8508199a0d4, -100).",
+		ConstraintName: "wallets_balance_check",
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "Database error", err: pgErr, expected: "SQLSTATE 23514, constraint wallets_balance_check"},
+		{name: "Wrapped database error", err: fmt.Errorf("record operation: %w", pgErr), expected: "SQLSTATE 23514, constraint wallets_balance_check"},
+		{name: "Database error without constraint", err: &pgconn.PgError{Code: "40001", Message: "could not serialize access"}, expected: "SQLSTATE 40001"},
+		{name: "Canceled request", err: fmt.Errorf("begin: %w", context.Canceled), expected: "context canceled"},
+		{name: "Other error", err: fmt.Errorf("get wallet limits: %w", errors.New("connection reset")), expected: "*fmt.wrapError -> *errors.errorString"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, errorSummary(tc.err))
+		})
+	}
+}
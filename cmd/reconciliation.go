@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/reconciliation"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// reconciliationAccount is the system ledger account that balances correcting entries
+const reconciliationAccount = "reconciliation"
+
+// reconciliationReport is a reconciliation run with the wallets whose balances did not match
+type reconciliationReport struct {
+	database.ReconciliationRun
+	Mismatches []database.ReconciliationMismatch `json:"mismatches"`
+}
+
+func (app *application) handleCreateReconciliationRun(w http.ResponseWriter, r *http.Request) {
+	// Read optional fix mode from query
+	fix := false
+	if value := r.URL.Query().Get("fix"); value != "" {
+		var err error
+		fix, err = strconv.ParseBool(value)
+		if err != nil {
+			writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: fix must be a boolean", nil)
+			return
+		}
+	}
+
+	// Run the reconciliation
+	run, err := app.reconcile(r.Context(), fix)
+	if err != nil {
+		serverError(w, r, "Failed to run reconciliation", err)
+		return
+	}
+
+	app.writeReconciliationReport(w, r, run, http.StatusCreated)
+}
+
+func (app *application) handleGetReconciliationRuns(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+
+	// Get the latest runs from the database
+	runs, err := app.queries.GetReconciliationRuns(r.Context(), limit)
+	if err != nil {
+		serverError(w, r, "Failed to get reconciliation runs", err)
+		return
+	}
+	if runs == nil {
+		runs = []database.ReconciliationRun{}
+	}
+
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (app *application) handleGetReconciliationRun(w http.ResponseWriter, r *http.Request) {
+	// Read and parse run UUID from path
+	runUUID := pgtype.UUID{}
+	err := runUUID.Scan(r.PathValue("run_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidID, "Invalid reconciliation run ID", nil)
+		return
+	}
+
+	run, err := app.queries.GetReconciliationRun(r.Context(), runUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemReconciliationNotFound, "Reconciliation run not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get reconciliation run", err)
+		return
+	}
+
+	app.writeReconciliationReport(w, r, run, http.StatusOK)
+}
+
+// writeReconciliationReport responds with the run and its mismatches
+func (app *application) writeReconciliationReport(w http.ResponseWriter, r *http.Request, run database.ReconciliationRun, status int) {
+	mismatches, err := app.queries.GetReconciliationMismatches(r.Context(), run.ID)
+	if err != nil {
+		serverError(w, r, "Failed to get reconciliation mismatches", err)
+		return
+	}
+	if mismatches == nil {
+		mismatches = []database.ReconciliationMismatch{}
+	}
+
+	writeJSON(w, status, reconciliationReport{ReconciliationRun: run, Mismatches: mismatches})
+}
+
+// runReconciliation periodically reconciles wallet balances without correcting them until ctx is canceled
+func (app *application) runReconciliation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run, err := app.reconcile(ctx, false)
+			if err != nil {
+				slog.Error("Failed to run reconciliation", "error", err)
+				continue
+			}
+			if run.MismatchesFound > 0 {
+				slog.Warn("Reconciliation found wallets with mismatching balances", "run_id", run.ID.String(), "mismatches", run.MismatchesFound)
+			}
+		}
+	}
+}
+
+// reconcile compares the stored balance of every wallet with its operation history and ledger entries,
+// records the mismatches in a new reconciliation run and, in fix mode, corrects them.
+// A failed run is still recorded with its error.
+func (app *application) reconcile(ctx context.Context, fix bool) (database.ReconciliationRun, error) {
+	run, err := app.queries.CreateReconciliationRun(ctx, fix)
+	if err != nil {
+		return database.ReconciliationRun{}, fmt.Errorf("create reconciliation run: %w", err)
+	}
+
+	result := database.FinishReconciliationRunParams{ID: run.ID, Status: reconciliation.Completed}
+	err = app.reconcileWallets(ctx, run.ID, fix, &result)
+	if err != nil {
+		result.Status = reconciliation.Failed
+		result.Error = pgtype.Text{String: err.Error(), Valid: true}
+	}
+
+	run, finishErr := app.queries.FinishReconciliationRun(ctx, result)
+	if finishErr != nil {
+		return database.ReconciliationRun{}, fmt.Errorf("finish reconciliation run: %w", errors.Join(err, finishErr))
+	}
+	return run, err
+}
+
+// reconcileWallets finds wallets with mismatching balances and counts them in result
+func (app *application) reconcileWallets(ctx context.Context, runID pgtype.UUID, fix bool, result *database.FinishReconciliationRunParams) error {
+	checked, err := app.queries.CountWallets(ctx)
+	if err != nil {
+		return fmt.Errorf("count wallets: %w", err)
+	}
+	result.WalletsChecked = checked
+
+	mismatches, err := app.queries.GetBalanceMismatches(ctx)
+	if err != nil {
+		return fmt.Errorf("get balance mismatches: %w", err)
+	}
+
+	for _, mismatch := range mismatches {
+		if fix {
+			found, err := app.correctBalance(ctx, runID, mismatch.WalletID)
+			if err != nil {
+				return fmt.Errorf("correct balance of wallet %s: %w", mismatch.WalletID.String(), err)
+			}
+			if found {
+				result.MismatchesFound++
+				result.MismatchesCorrected++
+			}
+			continue
+		}
+
+		err = app.queries.AddReconciliationMismatch(ctx, database.AddReconciliationMismatchParams{
+			RunID:             runID,
+			WalletID:          mismatch.WalletID,
+			StoredBalance:     mismatch.StoredBalance,
+			OperationsBalance: mismatch.OperationsBalance,
+			LedgerBalance:     mismatch.LedgerBalance,
+		})
+		if err != nil {
+			return fmt.Errorf("add reconciliation mismatch: %w", err)
+		}
+		result.MismatchesFound++
+	}
+	return nil
+}
+
+// correctBalance brings the wallet balance and its ledger account in line with the operation history.
+// The difference between the operation history and the ledger is posted as a correcting journal
+// against the reconciliation account, then the stored balance is recalculated from the ledger.
+// It reports whether the wallet still had a mismatch once it was locked.
+func (app *application) correctBalance(ctx context.Context, runID, walletUUID pgtype.UUID) (bool, error) {
+	tx, err := app.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock the wallet and check it again, as operations may have changed it since the scan
+	wallet, err := queriesWithTx.GetBalanceForUpdate(ctx, walletUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	balances, err := queriesWithTx.GetWalletReconciliation(ctx, walletUUID)
+	if err != nil {
+		return false, err
+	}
+	if balances.StoredBalance == balances.OperationsBalance && balances.LedgerBalance == balances.OperationsBalance {
+		return false, nil
+	}
+
+	// Post the difference between the operation history and the ledger
+	var journalID pgtype.UUID
+	if delta := balances.OperationsBalance - balances.LedgerBalance; delta != 0 {
+		accountID, err := app.systemAccountID(ctx, queriesWithTx, reconciliationAccount, wallet.Currency)
+		if err != nil {
+			return false, fmt.Errorf("get %s account: %w", reconciliationAccount, err)
+		}
+
+		journalID = newUUID()
+		journal := database.PostJournalParams{JournalID: journalID, Amount: delta}
+		journal.DebitAccountID, journal.CreditAccountID = accountID, walletUUID
+		if delta < 0 {
+			journal.Amount = -delta
+			journal.DebitAccountID, journal.CreditAccountID = walletUUID, accountID
+		}
+		err = queriesWithTx.PostJournal(ctx, journal)
+		if err != nil {
+			return false, fmt.Errorf("post correcting journal: %w", err)
+		}
+	}
+
+	// Recalculate the stored balance, which may have been changed bypassing the ledger
+	err = queriesWithTx.SyncWalletBalance(ctx, walletUUID)
+	if err != nil {
+		return false, fmt.Errorf("sync wallet balance: %w", err)
+	}
+
+	err = queriesWithTx.AddReconciliationMismatch(ctx, database.AddReconciliationMismatchParams{
+		RunID:               runID,
+		WalletID:            walletUUID,
+		StoredBalance:       balances.StoredBalance,
+		OperationsBalance:   balances.OperationsBalance,
+		LedgerBalance:       balances.LedgerBalance,
+		Corrected:           true,
+		CorrectionJournalID: journalID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("add reconciliation mismatch: %w", err)
+	}
+
+	return true, tx.Commit(ctx)
+}
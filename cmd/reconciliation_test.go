@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/chtozamm/javacode-wallet/internal/reconciliation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateReconciliationRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Report only",
+			query:        "",
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Fix mode",
+			query:        "?fix=true",
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Invalid fix mode",
+			query:           "?fix=maybe",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: fix must be a boolean",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/admin/reconciliation-runs"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			app.handleCreateReconciliationRun(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestHandleGetReconciliationRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		runID           string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Existing run",
+			runID:        "fe6403a7-8b42-4449-abe6-a8508199a0d4",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Run not found",
+			runID:           "fe6403a7-8b42-4449-abe6-a8508199a0d4",
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "reconciliation_run_not_found",
+			expectedDetail:  "Reconciliation run not found",
+		},
+		{
+			name:            "Invalid run ID",
+			runID:           "fe6403a7-8b421-449-abe6-a8508199a0d4",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_id",
+			expectedDetail:  "Invalid reconciliation run ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/admin/reconciliation-runs/"+tc.runID, nil)
+			req.SetPathValue("run_id", tc.runID)
+			w := httptest.NewRecorder()
+
+			app.handleGetReconciliationRun(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+// TestReconcile corrupts a wallet balance bypassing the ledger and checks that the reconciliation
+// reports the mismatch and corrects it in fix mode. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	code := postOperation(t, app, walletUUID.String(), operations.Operation{OperationType: operations.Deposit, Amount: 100})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to deposit: status code %d", code)
+	}
+
+	_, err = dbPool.Exec(ctx, "UPDATE wallets SET balance = balance + 7 WHERE id = $1", walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to corrupt wallet balance: %v", err)
+	}
+
+	// findMismatch returns the mismatch of the wallet recorded by the run
+	findMismatch := func(run database.ReconciliationRun) *database.ReconciliationMismatch {
+		mismatches, err := app.queries.GetReconciliationMismatches(ctx, run.ID)
+		if err != nil {
+			t.Fatalf("Failed to get reconciliation mismatches: %v", err)
+		}
+		for i := range mismatches {
+			if mismatches[i].WalletID == walletUUID {
+				return &mismatches[i]
+			}
+		}
+		return nil
+	}
+
+	run, err := app.reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	assert.Equal(t, reconciliation.Completed, run.Status)
+	if mismatch := findMismatch(run); assert.NotNil(t, mismatch) {
+		assert.Equal(t, int64(107), mismatch.StoredBalance)
+		assert.Equal(t, int64(100), mismatch.OperationsBalance)
+		assert.Equal(t, int64(100), mismatch.LedgerBalance)
+		assert.False(t, mismatch.Corrected)
+	}
+
+	run, err = app.reconcile(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if mismatch := findMismatch(run); assert.NotNil(t, mismatch) {
+		assert.True(t, mismatch.Corrected)
+	}
+
+	balance, err := app.queries.GetBalance(ctx, walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet balance: %v", err)
+	}
+	assert.Equal(t, int64(100), balance)
+	assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+	assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+}
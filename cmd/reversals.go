@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// handleReverseOperation refunds part or all of a deposit or withdrawal by recording an operation
+// of the opposite type that references the original one
+func (app *application) handleReverseOperation(w http.ResponseWriter, r *http.Request) {
+	// Read and parse wallet and operation UUIDs from path
+	walletUUID := pgtype.UUID{}
+	err := walletUUID.Scan(r.PathValue("wallet_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	operationUUID := pgtype.UUID{}
+	err = operationUUID.Scan(r.PathValue("operation_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidID, "Invalid operation ID", nil)
+		return
+	}
+
+	// Decode JSON from request to struct; the body is optional
+	var req operations.ReversalRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+	if req.Amount < 0 {
+		writeProblem(w, r, problemInvalidAmount, "Amount must be greater than zero", nil)
+		return
+	}
+
+	// Start transaction
+	tx, err := app.begin(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to begin reversal transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock the original operation first, so concurrent reversals of it are applied one after another
+	original, err := queriesWithTx.GetOperationForUpdate(r.Context(), database.GetOperationForUpdateParams{
+		ID:       operationUUID,
+		WalletID: walletUUID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeProblem(w, r, problemOperationNotFound, "Operation not found", nil)
+			return
+		}
+		serverError(w, r, "Failed to get operation", err)
+		return
+	}
+
+	// Check that the operation can be reversed
+	switch {
+	case original.ReversalOf.Valid:
+		writeProblem(w, r, problemNotReversible, "Cannot reverse a reversal", nil)
+		return
+	case original.TransferID.Valid:
+		writeProblem(w, r, problemNotReversible, "Cannot reverse a transfer: make a transfer in the opposite direction instead", nil)
+		return
+	}
+
+	// Check the amount against what is left to reverse
+	reversed, err := queriesWithTx.GetReversedAmount(r.Context(), operationUUID)
+	if err != nil {
+		serverError(w, r, "Failed to get reversed amount", err)
+		return
+	}
+	remaining := original.Amount - reversed
+	if remaining <= 0 {
+		writeProblem(w, r, problemAlreadyReversed, "Operation is already fully reversed", nil)
+		return
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		writeProblem(w, r, problemReversalExceedsAmount, fmt.Sprintf("Reversal amount exceeds the amount left to reverse: remaining %d, trying to reverse %d", remaining, amount),
+			map[string]any{"remaining": remaining, "amount": amount})
+		return
+	}
+
+	// Lock the wallet row
+	wallet, err := queriesWithTx.GetBalanceForUpdate(r.Context(), walletUUID)
+	if err != nil {
+		serverError(w, r, "Failed to get wallet balance", err)
+		return
+	}
+
+	// Reversing a deposit withdraws funds, reversing a withdrawal deposits them back
+	reversalType := operations.Opposite(original.OperationType)
+	if p := walletStatusProblem(wallet.Status, reversalType == operations.Withdraw); p != nil {
+		p.write(w, r)
+		return
+	}
+
+	switch reversalType {
+	case operations.Deposit:
+		_, err = operations.Credit(wallet.Balance, amount)
+		if err != nil {
+			balanceOverflow(wallet.Balance, amount).write(w, r)
+			return
+		}
+	case operations.Withdraw:
+		available := operations.Available(wallet.Balance, wallet.Held, wallet.CreditLimit)
+		if available < amount {
+			insufficientFunds("reverse", available, amount).write(w, r)
+			return
+		}
+	}
+
+	// Insert the reversal and post it to the ledger
+	reversalID, err := app.recordOperation(r.Context(), queriesWithTx, database.AddOperationParams{
+		WalletID:      walletUUID,
+		OperationType: reversalType,
+		Amount:        amount,
+		ReversalOf:    operationUUID,
+	}, wallet.Currency)
+	if err != nil {
+		serverError(w, r, "Failed to record reversal", err)
+		return
+	}
+
+	reversal, err := queriesWithTx.GetOperation(r.Context(), reversalID)
+	if err != nil {
+		serverError(w, r, "Failed to get reversal", err)
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to commit reversal transaction", err)
+		return
+	}
+	app.metrics.observeOperation(reversalType, amount)
+
+	writeJSON(w, http.StatusCreated, reversal)
+}
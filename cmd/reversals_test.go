@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleReverseOperation(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	operationID := "30504a06-1d08-4390-92ef-c03c253d702b"
+	linkedID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+
+	// operationRow returns the columns of an operation locked by GetOperationForUpdate.
+	// The first column is also read as the reversed amount and the wallet balance.
+	operationRow := func(reversed any, operationType string, transferID, reversalOf pgtype.UUID) []any {
+		return []any{reversed, nil, operationType, int64(100), nil, transferID, nil, reversalOf}
+	}
+
+	tests := []struct {
+		name            string
+		operationID     string
+		body            string
+		mockRow         []any
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Full refund of a withdrawal",
+			operationID:  operationID,
+			mockRow:      operationRow(nil, operations.Withdraw, pgtype.UUID{}, pgtype.UUID{}),
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Partial refund of a withdrawal",
+			operationID:  operationID,
+			body:         `{"amount":40}`,
+			mockRow:      operationRow(nil, operations.Withdraw, pgtype.UUID{}, pgtype.UUID{}),
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Reversal exceeds the original amount",
+			operationID:     operationID,
+			body:            `{"amount":150}`,
+			mockRow:         operationRow(nil, operations.Withdraw, pgtype.UUID{}, pgtype.UUID{}),
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "reversal_exceeds_amount",
+			expectedDetail:  "Reversal amount exceeds the amount left to reverse: remaining 100, trying to reverse 150",
+		},
+		{
+			name:            "Operation already reversed",
+			operationID:     operationID,
+			mockRow:         operationRow(int64(100), operations.Withdraw, pgtype.UUID{}, pgtype.UUID{}),
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "operation_already_reversed",
+			expectedDetail:  "Operation is already fully reversed",
+		},
+		{
+			name:            "Insufficient funds to reverse a deposit",
+			operationID:     operationID,
+			mockRow:         operationRow(nil, operations.Deposit, pgtype.UUID{}, pgtype.UUID{}),
+			expectedCode:    http.StatusPaymentRequired,
+			expectedProblem: "insufficient_funds",
+			expectedDetail:  "Insufficient funds to reverse: balance 0, trying to reverse 100",
+		},
+		{
+			name:            "Reversal of a reversal",
+			operationID:     operationID,
+			mockRow:         operationRow(nil, operations.Deposit, pgtype.UUID{}, linkedID),
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "operation_not_reversible",
+			expectedDetail:  "Cannot reverse a reversal",
+		},
+		{
+			name:            "Reversal of a transfer",
+			operationID:     operationID,
+			mockRow:         operationRow(nil, operations.Withdraw, linkedID, pgtype.UUID{}),
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "operation_not_reversible",
+			expectedDetail:  "Cannot reverse a transfer: make a transfer in the opposite direction instead",
+		},
+		{
+			name:            "Operation not found",
+			operationID:     operationID,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "operation_not_found",
+			expectedDetail:  "Operation not found",
+		},
+		{
+			name:            "Invalid operation ID",
+			operationID:     "30504a06-1d081-4390-92ef-c03c253d702b",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_id",
+			expectedDetail:  "Invalid operation ID",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Row: tc.mockRow,
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/operations/"+tc.operationID+"/reverse", strings.NewReader(tc.body))
+			req.SetPathValue("wallet_id", walletID)
+			req.SetPathValue("operation_id", tc.operationID)
+			w := httptest.NewRecorder()
+
+			app.handleReverseOperation(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+// TestReverseOperation refunds a deposit in parts and checks that it cannot be reversed
+// beyond its amount. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestReverseOperation(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	code := postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: 100})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to deposit: status code %d", code)
+	}
+
+	var depositUUID pgtype.UUID
+	err = dbPool.QueryRow(ctx, "SELECT id FROM operations WHERE wallet_id = $1", walletUUID).Scan(&depositUUID)
+	if err != nil {
+		t.Fatalf("Failed to get deposit: %v", err)
+	}
+
+	// reverse sends a reversal request for the deposit and returns the status code
+	reverse := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID+"/operations/"+depositUUID.String()+"/reverse", strings.NewReader(body))
+		req.SetPathValue("wallet_id", walletID)
+		req.SetPathValue("operation_id", depositUUID.String())
+		w := httptest.NewRecorder()
+
+		app.handleReverseOperation(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, reverse(`{"amount":30}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, reverse(`{"amount":80}`))
+	assert.Equal(t, http.StatusCreated, reverse(""))
+	assert.Equal(t, http.StatusConflict, reverse(""))
+
+	balance, err := app.queries.GetBalance(ctx, walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet balance: %v", err)
+	}
+	assert.Equal(t, int64(0), balance)
+	assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+	assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+)
+
+// route is an endpoint of the API: a net/http.ServeMux pattern and its handler.
+// Every route must be documented in the OpenAPI specification in internal/openapi.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes returns the endpoints of the API
+func (app *application) routes() []route {
+	return []route{
+		{"GET /api/v1/wallets/{wallet_id}", app.handleGetBalance},
+		{"GET /api/v1/wallets", app.basicAuthMiddleware(app.handleGetWallets)},
+		{"POST /api/v1/wallets", app.idempotencyMiddleware(app.handleCreateWallet)},
+		{"POST /api/v1/wallets/{wallet_id}", app.idempotencyMiddleware(app.handleOperation)},
+		{"DELETE /api/v1/wallets/{wallet_id}", app.basicAuthMiddleware(app.handleDeleteWallet)},
+		{"POST /api/v1/wallets/{wallet_id}/freeze", app.basicAuthMiddleware(app.handleFreezeWallet)},
+		{"POST /api/v1/wallets/{wallet_id}/unfreeze", app.basicAuthMiddleware(app.handleUnfreezeWallet)},
+		{"POST /api/v1/wallets/{wallet_id}/close", app.basicAuthMiddleware(app.handleCloseWallet)},
+		{"GET /api/v1/wallets/{wallet_id}/operations", app.handleGetOperations},
+		{"GET /api/v1/wallets/{wallet_id}/events", app.handleWalletEvents},
+		{"POST /api/v1/wallets/{wallet_id}/operations/{operation_id}/reverse", app.idempotencyMiddleware(app.handleReverseOperation)},
+		{"GET /api/v1/wallets/{wallet_id}/credit", app.handleGetCredit},
+		{"PUT /api/v1/wallets/{wallet_id}/credit", app.basicAuthMiddleware(app.handleSetCreditLimit)},
+		{"GET /api/v1/wallets/{wallet_id}/limits", app.handleGetLimits},
+		{"PUT /api/v1/wallets/{wallet_id}/limits", app.basicAuthMiddleware(app.handleSetLimits)},
+		{"DELETE /api/v1/wallets/{wallet_id}/limits", app.basicAuthMiddleware(app.handleResetLimits)},
+		{"POST /api/v1/wallets/{wallet_id}/holds", app.idempotencyMiddleware(app.handleCreateHold)},
+		{"GET /api/v1/wallets/{wallet_id}/holds/{hold_id}", app.handleGetHold},
+		{"POST /api/v1/wallets/{wallet_id}/holds/{hold_id}/capture", app.idempotencyMiddleware(app.handleCaptureHold)},
+		{"POST /api/v1/wallets/{wallet_id}/holds/{hold_id}/void", app.idempotencyMiddleware(app.handleVoidHold)},
+		{"POST /api/v1/admin/reconciliation-runs", app.basicAuthMiddleware(app.handleCreateReconciliationRun)},
+		{"GET /api/v1/admin/reconciliation-runs", app.basicAuthMiddleware(app.handleGetReconciliationRuns)},
+		{"GET /api/v1/admin/reconciliation-runs/{run_id}", app.basicAuthMiddleware(app.handleGetReconciliationRun)},
+		{"POST /api/v1/admin/webhook-subscriptions", app.basicAuthMiddleware(app.handleCreateWebhookSubscription)},
+		{"GET /api/v1/admin/webhook-subscriptions", app.basicAuthMiddleware(app.handleGetWebhookSubscriptions)},
+		{"DELETE /api/v1/admin/webhook-subscriptions/{subscription_id}", app.basicAuthMiddleware(app.handleDeleteWebhookSubscription)},
+		{"GET /api/v1/admin/webhook-deliveries", app.basicAuthMiddleware(app.handleGetWebhookDeliveries)},
+		{"POST /api/v1/admin/webhook-deliveries/{delivery_id}/redeliver", app.basicAuthMiddleware(app.handleRedeliverWebhook)},
+		{"POST /api/v1/operations/batch", app.basicAuthMiddleware(withWriteTimeout(batchWriteTimeout, app.idempotencyMiddleware(app.handleBatch)))},
+		{"GET /api/v1/healthz", app.handleHealthCheck},
+		{"GET /api/v1/openapi.json", app.handleGetOpenAPI},
+		{"GET /metrics", app.handleMetrics},
+	}
+}
+
+// router returns the handler that serves the API
+func (app *application) router() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range app.routes() {
+		handler := app.metrics.instrument(route.pattern, logRoute(route.pattern, route.handler))
+		mux.HandleFunc(route.pattern, traceRoute(app.tracerProvider, route.pattern, handler))
+	}
+	return logRequests(mux)
+}
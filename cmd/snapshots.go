@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// balanceSnapshotLag is how far in the past snapshots are taken. Operations get their creation time
+// when their transaction starts, so a snapshot of the present could miss operations that are still
+// being committed.
+const balanceSnapshotLag = 5 * time.Minute
+
+// balanceAsOf calculates the balance of the wallet at the given time from its operation history,
+// starting from the latest balance snapshot taken before that time.
+// It returns sql.ErrNoRows if the wallet did not exist yet.
+func (app *application) balanceAsOf(ctx context.Context, walletUUID pgtype.UUID, asOf pgtype.Timestamp) (int64, error) {
+	wallet, err := app.queries.GetWallet(ctx, walletUUID)
+	if err != nil {
+		return 0, err
+	}
+	if wallet.CreatedAt.Valid && wallet.CreatedAt.Time.After(asOf.Time) {
+		return 0, sql.ErrNoRows
+	}
+
+	return app.queries.GetBalanceAsOf(ctx, database.GetBalanceAsOfParams{WalletID: walletUUID, AsOf: asOf})
+}
+
+// runBalanceSnapshots periodically snapshots the balances of wallets with new operations until ctx is canceled
+func (app *application) runBalanceSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			takenAt, err := app.queries.GetBalanceSnapshotTime(ctx, pgtype.Interval{Microseconds: balanceSnapshotLag.Microseconds(), Valid: true})
+			if err != nil {
+				slog.Error("Failed to get balance snapshot time", "error", err)
+				continue
+			}
+			created, err := app.queries.CreateBalanceSnapshots(ctx, takenAt)
+			if err != nil {
+				slog.Error("Failed to create balance snapshots", "error", err)
+				continue
+			}
+			if created > 0 {
+				slog.Info("Created balance snapshots", "count", created)
+			}
+		}
+	}
+}
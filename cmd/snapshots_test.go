@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBalanceAsOf checks that historical balances are the same with and without a snapshot
+// between the operations. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	// now returns the current time of the database
+	now := func() pgtype.Timestamp {
+		var ts pgtype.Timestamp
+		if err := dbPool.QueryRow(ctx, "SELECT NOW()::timestamp").Scan(&ts); err != nil {
+			t.Fatalf("Failed to get database time: %v", err)
+		}
+		return ts
+	}
+
+	// deposit adds the amount to the wallet in a separate transaction
+	deposit := func(amount int64) {
+		code := postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: amount})
+		if code != http.StatusOK {
+			t.Fatalf("Failed to deposit: status code %d", code)
+		}
+	}
+
+	deposit(100)
+	afterFirst := now()
+	deposit(50)
+	afterSecond := now()
+
+	before := pgtype.Timestamp{Time: afterFirst.Time.Add(-time.Hour), Valid: true}
+	_, err = app.balanceAsOf(ctx, walletUUID, before)
+	assert.Error(t, err, "wallet did not exist an hour ago")
+
+	for _, snapshot := range []bool{false, true} {
+		if snapshot {
+			if _, err := app.queries.CreateBalanceSnapshots(ctx, afterFirst); err != nil {
+				t.Fatalf("Failed to create balance snapshots: %v", err)
+			}
+		}
+
+		balance, err := app.balanceAsOf(ctx, walletUUID, afterFirst)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(100), balance)
+		}
+		balance, err = app.balanceAsOf(ctx, walletUUID, afterSecond)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(150), balance)
+		}
+	}
+
+	// Scheduled snapshots are timed by the clock of the database
+	takenAt, err := app.queries.GetBalanceSnapshotTime(ctx, pgtype.Interval{Microseconds: time.Hour.Microseconds(), Valid: true})
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, now().Time.Add(-time.Hour), takenAt.Time, 2*time.Second)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation that creates the spans of database queries
+const tracerName = "github.com/chtozamm/javacode-wallet"
+
+// propagator reads and writes the W3C traceparent and tracestate headers, and baggage
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// newTracerProvider returns a tracer provider that exports spans with the given exporter:
+// "otlp" (configured with the standard OTEL_EXPORTER_OTLP_* environment variables), "stdout",
+// or "off" (or empty), for which it returns nil
+func newTracerProvider(ctx context.Context, exporter string) (*sdktrace.TracerProvider, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "off":
+		return nil, nil
+	case "otlp":
+		spanExporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		spanExporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER environment variable: %q must be \"otlp\", \"stdout\" or \"off\"", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("wallet-server")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter), sdktrace.WithResource(res)), nil
+}
+
+// traceRoute starts a server span for every request to the route, continuing the trace
+// of the traceparent header if there is one. A nil tracer provider traces nothing.
+func traceRoute(tp trace.TracerProvider, pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if tp == nil {
+		return next
+	}
+	return otelhttp.NewHandler(next, pattern,
+		otelhttp.WithTracerProvider(tp),
+		otelhttp.WithPropagators(propagator),
+	).ServeHTTP
+}
+
+// queryTracer creates a span for every query, batch and connection acquire made while handling
+// a traced request, so that the span of the request shows where its time goes. Queries made
+// outside of a trace, such as the ones of background jobs, are not traced.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func newQueryTracer(tp trace.TracerProvider) *queryTracer {
+	return &queryTracer{tracer: tp.Tracer(tracerName)}
+}
+
+// querySpanKey is the context key of the span started by the tracer, which pgx passes
+// from the start of a query to its end
+type querySpanKey struct{}
+
+// start starts a child span of the span in the context, if there is one
+func (t *queryTracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	attrs = append(attrs, semconv.DBSystemNamePostgreSQL)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+// end ends the span started by start. A failed span carries a summary of the error rather than its text,
+// which may contain data from the database; the expected absence of rows is not a failure.
+func (t *queryTracer) end(ctx context.Context, err error) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.SetStatus(codes.Error, errorSummary(err))
+	}
+	span.End()
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := queryName(data.SQL)
+	return t.start(ctx, name, semconv.DBOperationName(name), semconv.DBQueryText(data.SQL))
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if span, ok := ctx.Value(querySpanKey{}).(trace.Span); ok && data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	t.end(ctx, data.Err)
+}
+
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return t.start(ctx, "BATCH", semconv.DBOperationBatchSize(data.Batch.Len()))
+}
+
+// TraceBatchQuery records every query of a batch as an event of the span of the batch
+func (t *queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	attrs := []attribute.KeyValue{semconv.DBOperationName(queryName(data.SQL))}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error.summary", errorSummary(data.Err)))
+	}
+	span.AddEvent(queryName(data.SQL), trace.WithAttributes(attrs...))
+}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err)
+}
+
+// TraceAcquireStart traces waiting for a connection of the pool, which takes long when the pool is exhausted
+func (t *queryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return t.start(ctx, "ACQUIRE")
+}
+
+func (t *queryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	t.end(ctx, data.Err)
+}
+
+// queryName names the span of a query: the name of a query generated by sqlc, which starts with
+// a "-- name: GetBalance :one" comment, or the first keyword of any other statement, such as BEGIN or COMMIT
+func queryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if rest, ok := strings.CutPrefix(sql, "-- name: "); ok {
+		if name, _, ok := strings.Cut(rest, " "); ok {
+			return name
+		}
+	}
+	keywords := strings.Fields(sql)
+	if len(keywords) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(keywords[0])
+}
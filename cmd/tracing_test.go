@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestTracerProvider returns a tracer provider that exports spans to memory as soon as they end
+func newTestTracerProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return tp, exporter
+}
+
+func TestTraceRoute(t *testing.T) {
+	walletID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID := "00f067aa0ba902b7"
+
+	tests := []struct {
+		name        string
+		traceparent string
+		expectRoot  bool
+	}{
+		{
+			name:        "Propagated trace",
+			traceparent: "00-" + traceID + "-" + parentSpanID + "-01",
+		},
+		{
+			name:       "New trace",
+			expectRoot: true,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Balance: 100}
+
+		t.Run(tc.name, func(t *testing.T) {
+			tp, exporter := newTestTracerProvider(t)
+			app := &application{
+				db:             mockDB,
+				queries:        database.New(mockDB),
+				tracerProvider: tp,
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/wallets/"+walletID, nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			w := httptest.NewRecorder()
+			app.router().ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			// The request has a server span named after its route
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "GET /api/v1/wallets/{wallet_id}", span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+
+			if tc.expectRoot {
+				assert.False(t, span.Parent.IsValid())
+				return
+			}
+			assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+			assert.Equal(t, parentSpanID, span.Parent.SpanID().String())
+			assert.True(t, span.Parent.IsRemote())
+		})
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	tests := []struct {
+		name           string
+		sql            string
+		err            error
+		untraced       bool
+		expectedName   string
+		expectedStatus codes.Code
+	}{
+		{
+			name:         "Query generated by sqlc",
+			sql:          "-- name: GetBalanceForUpdate :one\nSELECT balance FROM wallets WHERE id = $1 FOR UPDATE",
+			expectedName: "GetBalanceForUpdate",
+		},
+		{
+			name:         "Transaction statement",
+			sql:          "begin",
+			expectedName: "BEGIN",
+		},
+		{
+			name:         "No rows",
+			sql:          "-- name: GetWallet :one\nSELECT * FROM wallets WHERE id = $1",
+			err:          pgx.ErrNoRows,
+			expectedName: "GetWallet",
+		},
+		{
+			name:           "Failed query",
+			sql:            "commit",
+			err:            errors.New("connection reset"),
+			expectedName:   "COMMIT",
+			expectedStatus: codes.Error,
+		},
+		{
+			name:     "Query outside of a trace",
+			sql:      "-- name: DeleteExpiredIdempotencyKeys :execrows\nDELETE FROM idempotency_keys",
+			untraced: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, exporter := newTestTracerProvider(t)
+			tracer := newQueryTracer(tp)
+
+			// Queries are traced as children of the span of a request
+			ctx := context.Background()
+			var parent trace.Span
+			if !tc.untraced {
+				ctx, parent = tp.Tracer("test").Start(ctx, "request")
+			}
+
+			queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: tc.sql})
+			tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{Err: tc.err})
+
+			spans := exporter.GetSpans()
+			if tc.untraced {
+				assert.Empty(t, spans)
+				return
+			}
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tc.expectedName, span.Name)
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+			assert.Equal(t, tc.expectedStatus, span.Status.Code)
+		})
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	tests := []struct {
+		exporter    string
+		expectNil   bool
+		expectedErr string
+	}{
+		{exporter: "", expectNil: true},
+		{exporter: "off", expectNil: true},
+		{exporter: "stdout"},
+		{exporter: "otlp"},
+		{exporter: "jaeger", expectNil: true, expectedErr: `invalid TRACING_EXPORTER environment variable: "jaeger" must be "otlp", "stdout" or "off"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%q", tc.exporter), func(t *testing.T) {
+			tp, err := newTracerProvider(context.Background(), tc.exporter)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectNil, tp == nil)
+			if tp != nil {
+				_ = tp.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+// TestTraceOperation checks that an operation has a span for every step of its transaction.
+// It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestTraceOperation(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	ctx := context.Background()
+	tp, exporter := newTestTracerProvider(t)
+
+	config, err := pgxpool.ParseConfig(dbURL)
+	require.NoError(t, err)
+	config.ConnConfig.Tracer = newQueryTracer(tp)
+	dbPool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(dbPool.Close)
+
+	app := &application{
+		db:             dbPool,
+		queries:        database.New(dbPool),
+		tracerProvider: tp,
+	}
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	require.NoError(t, err)
+	walletID := walletUUID.String()
+
+	req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID, strings.NewReader(`{"operation_type":"deposit","amount":100}`))
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Find the server span and collect the names of its children
+	spans := exporter.GetSpans()
+	var server tracetest.SpanStub
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindServer {
+			server = span
+		}
+	}
+	require.Equal(t, "POST /api/v1/wallets/{wallet_id}", server.Name)
+
+	var children []string
+	for _, span := range spans {
+		if span.Parent.SpanID() == server.SpanContext.SpanID() {
+			children = append(children, span.Name)
+		}
+	}
+	assert.Subset(t, children, []string{"ACQUIRE", "BEGIN", "GetBalanceForUpdate", "AddOperation", "COMMIT"})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// transfer moves funds from one wallet to another in a single transaction.
+// It records a withdrawal from the source wallet and a deposit to the target wallet
+// that share the same transfer ID.
+func (app *application) transfer(ctx context.Context, sourceUUID pgtype.UUID, op operations.Operation) (operationResponse, error) {
+	// Parse target wallet UUID from request body
+	targetUUID := pgtype.UUID{}
+	err := targetUUID.Scan(op.TargetWalletID)
+	if err != nil {
+		return operationResponse{}, newProblem(problemInvalidWalletID, "Invalid target wallet ID", nil)
+	}
+
+	if targetUUID == sourceUUID {
+		return operationResponse{}, newProblem(problemSameWallet, "Cannot transfer to the same wallet", nil)
+	}
+
+	// Start transaction
+	tx, err := app.begin(ctx)
+	if err != nil {
+		return operationResponse{}, internalError("Failed to begin transfer transaction", err)
+	}
+	var committed bool
+	defer func() {
+		if !committed {
+			tx.Rollback(ctx)
+		}
+		app.metrics.observeTransaction(committed)
+	}()
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Lock both wallet rows; they are always locked in the order of their IDs,
+	// so two opposite transfers between the same wallets cannot deadlock
+	wallets, err := queriesWithTx.GetBalancesForUpdate(ctx, []pgtype.UUID{sourceUUID, targetUUID})
+	if err != nil {
+		return operationResponse{}, internalError("Failed to get wallet balances", err)
+	}
+
+	var source, target *database.GetBalancesForUpdateRow
+	for i := range wallets {
+		switch wallets[i].ID {
+		case sourceUUID:
+			source = &wallets[i]
+		case targetUUID:
+			target = &wallets[i]
+		}
+	}
+	if source == nil {
+		return operationResponse{}, newProblem(problemWalletNotFound, "Wallet not found", nil)
+	}
+	if target == nil {
+		return operationResponse{}, newProblem(problemTargetWalletNotFound, "Target wallet not found", nil)
+	}
+	sourceBalance, targetBalance := source.Balance, target.Balance
+
+	// Check wallet statuses: a frozen wallet can receive funds but cannot send them
+	if p := walletStatusProblem(source.Status, true); p != nil {
+		return operationResponse{}, p
+	}
+	if target.Status == walletClosed {
+		return operationResponse{}, newProblem(problemTargetWalletClosed, "Target wallet is closed", map[string]any{"wallet_status": target.Status})
+	}
+
+	// Check currencies: funds can only move between wallets of the same currency
+	if op.Currency != "" && !strings.EqualFold(op.Currency, source.Currency) {
+		return operationResponse{}, currencyMismatch(source.Currency, op.Currency, "operation")
+	}
+	if target.Currency != source.Currency {
+		return operationResponse{}, newProblem(problemCurrencyMismatch, fmt.Sprintf("Currency mismatch: cannot transfer %s to a %s wallet", source.Currency, target.Currency),
+			map[string]any{"wallet_currency": source.Currency, "target_wallet_currency": target.Currency})
+	}
+
+	// Check balance of the source wallet; funds reserved by holds cannot be transferred
+	available := operations.Available(sourceBalance, source.Held, source.CreditLimit)
+	if available < op.Amount {
+		return operationResponse{}, insufficientFunds("transfer", available, op.Amount)
+	}
+
+	// Check withdrawal limits of the source wallet; transfers count as withdrawals
+	p, err := app.checkWithdrawalLimits(ctx, queriesWithTx, sourceUUID, op.Amount)
+	if err != nil {
+		return operationResponse{}, internalError("Failed to check withdrawal limits", err)
+	}
+	if p != nil {
+		return operationResponse{}, p
+	}
+
+	// Check that the target wallet balance does not overflow
+	_, err = operations.Credit(targetBalance, op.Amount)
+	if err != nil {
+		return operationResponse{}, newProblem(problemBalanceOverflow, fmt.Sprintf("Transfer would overflow target wallet balance: balance %d, amount %d", targetBalance, op.Amount),
+			map[string]any{"balance": targetBalance, "amount": op.Amount})
+	}
+
+	// Insert both sides of the transfer in database
+	transferID := newUUID()
+	var operationIDs [2]pgtype.UUID
+	for i, leg := range []struct {
+		walletID      pgtype.UUID
+		operationType string
+	}{
+		{walletID: sourceUUID, operationType: operations.Withdraw},
+		{walletID: targetUUID, operationType: operations.Deposit},
+	} {
+		operationIDs[i], err = queriesWithTx.AddOperation(ctx, database.AddOperationParams{
+			WalletID:      leg.walletID,
+			OperationType: leg.operationType,
+			Amount:        op.Amount,
+			TransferID:    transferID,
+		})
+		if err != nil {
+			return operationResponse{}, internalError("Failed to add transfer operation", err)
+		}
+	}
+
+	// Post the transfer to the ledger as one journal, which updates both wallet balances
+	err = queriesWithTx.PostJournal(ctx, database.PostJournalParams{
+		JournalID:         transferID,
+		DebitAccountID:    sourceUUID,
+		DebitOperationID:  operationIDs[0],
+		CreditAccountID:   targetUUID,
+		CreditOperationID: operationIDs[1],
+		Amount:            op.Amount,
+	})
+	if err != nil {
+		return operationResponse{}, internalError("Failed to post transfer journal", err)
+	}
+
+	// Commit transaction
+	err = tx.Commit(ctx)
+	if err != nil {
+		return operationResponse{}, internalError("Failed to commit transfer transaction", err)
+	}
+	committed = true
+	app.metrics.observeOperation(operations.Transfer, op.Amount)
+
+	// Return the withdrawal from the source wallet and its resulting balance
+	newBalance := sourceBalance - op.Amount
+	return operationResponse{
+		OperationID:    operationIDs[0],
+		WalletID:       sourceUUID,
+		OperationType:  operations.Transfer,
+		Amount:         op.Amount,
+		TransferID:     transferID,
+		Balance:        newBalance,
+		BalanceDecimal: formatAmount(newBalance, source.Currency),
+	}, nil
+}
@@ -1,15 +1,70 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/currency"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func writeResponse(w http.ResponseWriter, payload any) {
 	_, err := fmt.Fprintln(w, payload)
 	if err != nil {
-		log.Printf("Failed to write response: %v\n", err)
+		slog.Warn("Failed to write response", "error", err)
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
 	}
 }
+
+// writeJSON marshals the payload into JSON and writes it with the given status code
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to marshal response into JSON", "error", err)
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeResponse(w, string(data))
+}
+
+// getEnvDuration reads a duration such as "30s" or "24h" from an environment variable,
+// falling back to the default value if the variable is not set
+func getEnvDuration(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid %s environment variable: expected a positive duration, got %q", name, value)
+	}
+	return duration, nil
+}
+
+// newUUID generates a random (version 4) UUID
+func newUUID() pgtype.UUID {
+	id := pgtype.UUID{Valid: true}
+	_, _ = rand.Read(id.Bytes[:])
+	id.Bytes[6] = (id.Bytes[6] & 0x0f) | 0x40 // Version 4
+	id.Bytes[8] = (id.Bytes[8] & 0x3f) | 0x80 // Variant 10
+	return id
+}
+
+// formatAmount renders an amount of minor units as a decimal string in the given currency
+func formatAmount(amount int64, code string) string {
+	c, ok := currency.Lookup(code)
+	if !ok {
+		return strconv.FormatInt(amount, 10)
+	}
+	return c.Format(amount)
+}
@@ -1,31 +1,108 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/chtozamm/javacode-wallet/internal/currency"
 	"github.com/chtozamm/javacode-wallet/internal/database"
 	"github.com/chtozamm/javacode-wallet/internal/operations"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// createWalletRequest is the optional body of a wallet creation request
+type createWalletRequest struct {
+	Currency string `json:"currency"`
+}
+
+// walletsPage is a page of wallets in the requested sort order
+type walletsPage struct {
+	Wallets    []walletResponse `json:"wallets"`
+	NextCursor *string          `json:"next_cursor"`
+	Total      *int64           `json:"total,omitempty"`
+}
+
+// createWalletResponse is the JSON body of a wallet creation response
+type createWalletResponse struct {
+	ID pgtype.UUID `json:"id"`
+}
+
+// balanceResponse is the JSON body of a balance response. AsOf is only set for a historical balance.
+type balanceResponse struct {
+	WalletID       pgtype.UUID      `json:"wallet_id"`
+	Kind           string           `json:"kind"`
+	Balance        int64            `json:"balance"`
+	BalanceDecimal string           `json:"balance_decimal"`
+	Currency       string           `json:"currency"`
+	AsOf           pgtype.Timestamp `json:"as_of,omitzero"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+}
+
+// operationResponse is the result of an operation: the recorded operation and the wallet balance after it.
+// TransferID is only set for transfers.
+type operationResponse struct {
+	OperationID    pgtype.UUID `json:"operation_id"`
+	WalletID       pgtype.UUID `json:"wallet_id"`
+	OperationType  string      `json:"operation_type"`
+	Amount         int64       `json:"amount"`
+	TransferID     pgtype.UUID `json:"transfer_id,omitzero"`
+	Balance        int64       `json:"balance"`
+	BalanceDecimal string      `json:"balance_decimal"`
+}
+
+// walletResponse is a wallet with its balance formatted in major currency units
+// and the part of its credit limit in use
+type walletResponse struct {
+	database.Wallet
+	BalanceDecimal string `json:"balance_decimal"`
+	CreditUsed     int64  `json:"credit_used"`
+}
+
 func (app *application) handleCreateWallet(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON from request to struct; the body is optional
+	var req createWalletRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
 	// Create a new wallet
-	walletID, err := app.queries.CreateWallet(r.Context())
+	walletID, err := app.createWallet(r.Context(), req.Currency)
 	if err != nil {
-		log.Printf("Failed to create wallet: %v\n", err)
-		http.Error(w, "Failed to create wallet", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Respond with the wallet's ID
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "text/plain")
-	writeResponse(w, walletID.String())
+	writeNegotiated(w, r, http.StatusCreated, createWalletResponse{ID: walletID}, walletID.String())
+}
+
+// createWallet creates an empty wallet in the given currency, or in the default currency if it is empty
+func (app *application) createWallet(ctx context.Context, currencyCode string) (pgtype.UUID, error) {
+	// Check currency, falling back to the default one
+	if currencyCode == "" {
+		currencyCode = app.defaultCurrency
+	}
+	walletCurrency, ok := currency.Lookup(currencyCode)
+	if !ok {
+		return pgtype.UUID{}, newProblem(problemUnsupportedCurrency, "Unsupported currency: expected an ISO 4217 currency code", map[string]any{"currency": currencyCode})
+	}
+
+	// Create a new wallet
+	walletID, err := app.requestQueries(ctx).CreateWallet(ctx, walletCurrency.Code)
+	if err != nil {
+		return pgtype.UUID{}, internalError("Failed to create wallet", err)
+	}
+	return walletID, nil
 }
 
 func (app *application) handleGetBalance(w http.ResponseWriter, r *http.Request) {
@@ -33,25 +110,85 @@ func (app *application) handleGetBalance(w http.ResponseWriter, r *http.Request)
 	walletUUID := pgtype.UUID{}
 	err := walletUUID.Scan(r.PathValue("wallet_id"))
 	if err != nil {
-		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+		return
+	}
+
+	// Read optional point in time to get the historical balance at
+	asOf, err := parseTimestampParam(r, "as_of")
+	if err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+
+	// Get wallet balance
+	kind := r.URL.Query().Get("balance")
+	balance, err := app.getBalance(r.Context(), walletUUID, kind, asOf)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Get current wallet balance
-	balance, err := app.queries.GetBalance(r.Context(), walletUUID)
+	// Write response with the balance as plain text for existing callers
+	if !wantsJSON(r) {
+		writeNegotiated(w, r, http.StatusOK, nil, balance)
+		return
+	}
+
+	// Clients that accept JSON also get the currency and the time of the last balance change
+	response, err := app.describeBalance(r.Context(), walletUUID, kind, balance, asOf)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeNegotiated(w, r, http.StatusOK, response, balance)
+}
+
+// getBalance returns the balance of the given kind: the current ledger balance by default,
+// the historical ledger balance at asOf, or the available balance that excludes funds reserved by holds
+func (app *application) getBalance(ctx context.Context, walletUUID pgtype.UUID, kind string, asOf pgtype.Timestamp) (int64, error) {
+	var balance int64
+	var err error
+	switch {
+	case (kind == "" || kind == "ledger") && asOf.Valid:
+		balance, err = app.balanceAsOf(ctx, walletUUID, asOf)
+	case kind == "" || kind == "ledger":
+		balance, err = app.queries.GetBalance(ctx, walletUUID)
+	case kind == "available" && asOf.Valid:
+		return 0, newProblem(problemInvalidQueryParameter, "Invalid query parameter: as_of can only be used with the ledger balance", nil)
+	case kind == "available":
+		balance, err = app.queries.GetAvailableBalance(ctx, walletUUID)
+	default:
+		return 0, newProblem(problemInvalidQueryParameter, "Invalid query parameter: balance must be \"ledger\" or \"available\"", nil)
+	}
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Wallet not found", http.StatusNotFound)
-			return
+			return 0, newProblem(problemWalletNotFound, "Wallet not found", nil)
 		}
-		log.Printf("Failed to get wallet balance: %v\n", err)
-		http.Error(w, "Failed to get wallet balance", http.StatusInternalServerError)
-		return
+		return 0, internalError("Failed to get wallet balance", err)
+	}
+	return balance, nil
+}
+
+// describeBalance adds the currency and the time of the last balance change to a balance from getBalance
+func (app *application) describeBalance(ctx context.Context, walletUUID pgtype.UUID, kind string, balance int64, asOf pgtype.Timestamp) (balanceResponse, error) {
+	wallet, err := app.queries.GetWallet(ctx, walletUUID)
+	if err != nil {
+		return balanceResponse{}, internalError("Failed to get wallet", err)
 	}
 
-	// Write response with current balance
-	w.Header().Set("Content-Type", "text/plain")
-	writeResponse(w, balance)
+	if kind == "" {
+		kind = "ledger"
+	}
+	return balanceResponse{
+		WalletID:       walletUUID,
+		Kind:           kind,
+		Balance:        balance,
+		BalanceDecimal: formatAmount(balance, wallet.Currency),
+		Currency:       wallet.Currency,
+		AsOf:           asOf,
+		UpdatedAt:      wallet.UpdatedAt,
+	}, nil
 }
 
 func (app *application) handleOperation(w http.ResponseWriter, r *http.Request) {
@@ -59,7 +196,7 @@ func (app *application) handleOperation(w http.ResponseWriter, r *http.Request)
 	walletUUID := pgtype.UUID{}
 	err := walletUUID.Scan(r.PathValue("wallet_id"))
 	if err != nil {
-		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
 		return
 	}
 
@@ -67,141 +204,304 @@ func (app *application) handleOperation(w http.ResponseWriter, r *http.Request)
 	var op operations.Operation
 	err = json.NewDecoder(r.Body).Decode(&op)
 	if err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
 		return
 	}
 
-	// Check operation type
-	if op.OperationType != operations.Deposit && op.OperationType != operations.Withdraw {
-		http.Error(w, "Unsupported operation type: expected operation_type to be \"deposit\" or \"withdraw\"", http.StatusBadRequest)
+	// Apply the operation
+	res, err := app.applyOperation(r.Context(), walletUUID, op)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	// Respond with the operation and the resulting balance
+	writeNegotiated(w, r, http.StatusOK, res, res.Balance)
+}
+
+// applyOperation deposits to, withdraws from or transfers from the wallet in a single transaction
+// and returns the operation with the resulting balance
+func (app *application) applyOperation(ctx context.Context, walletUUID pgtype.UUID, op operations.Operation) (operationResponse, error) {
+	// Check operation type
+	if op.OperationType != operations.Deposit && op.OperationType != operations.Withdraw && op.OperationType != operations.Transfer {
+		return operationResponse{}, newProblem(problemInvalidOperationType, "Unsupported operation type: expected operation_type to be \"deposit\", \"withdraw\" or \"transfer\"", nil)
+	}
+	addLogAttrs(ctx, slog.String("operation_type", op.OperationType))
+
 	// Check amount
 	if op.Amount <= 0 {
-		http.Error(w, "Amount must be greater than zero", http.StatusBadRequest)
-		return
+		return operationResponse{}, newProblem(problemInvalidAmount, "Amount must be greater than zero", nil)
 	}
 
-	// Get current wallet balance
-	oldBalance, err := app.queries.GetBalance(r.Context(), walletUUID)
+	// Transfers move funds between two wallets and are handled separately
+	if op.OperationType == operations.Transfer {
+		return app.transfer(ctx, walletUUID, op)
+	}
+
+	// Start transaction
+	tx, err := app.begin(ctx)
+	if err != nil {
+		return operationResponse{}, internalError("Failed to begin operation transaction", err)
+	}
+	var committed bool
+	defer func() {
+		if !committed {
+			tx.Rollback(ctx)
+		}
+		app.metrics.observeTransaction(committed)
+	}()
+
+	// Wrap queries with transaction
+	queriesWithTx := app.queries.WithTx(tx)
+
+	// Get current wallet balance and lock the wallet row until the transaction ends,
+	// so concurrent operations on the same wallet are applied one after another
+	wallet, err := queriesWithTx.GetBalanceForUpdate(ctx, walletUUID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Wallet not found", http.StatusNotFound)
-			return
+			return operationResponse{}, newProblem(problemWalletNotFound, "Wallet not found", nil)
 		}
-		log.Printf("Failed to get wallet balance: %v\n", err)
-		http.Error(w, "Failed to get wallet balance", http.StatusInternalServerError)
-		return
+		return operationResponse{}, internalError("Failed to get wallet balance", err)
+	}
+	oldBalance := wallet.Balance
+
+	// Check that the wallet status allows the operation
+	if p := walletStatusProblem(wallet.Status, op.OperationType == operations.Withdraw); p != nil {
+		return operationResponse{}, p
 	}
 
-	// Calculate new balance
-	var newBalance int32
+	// Check currency if it is specified in the request
+	if op.Currency != "" && !strings.EqualFold(op.Currency, wallet.Currency) {
+		return operationResponse{}, currencyMismatch(wallet.Currency, op.Currency, "operation")
+	}
+
+	// Check that the new balance fits in a 64-bit integer
+	var newBalance int64
 	switch op.OperationType {
 	case operations.Deposit:
-		newBalance = oldBalance + op.Amount
+		newBalance, err = operations.Credit(oldBalance, op.Amount)
 	case operations.Withdraw:
-		newBalance = oldBalance - op.Amount
+		newBalance, err = operations.Debit(oldBalance, op.Amount)
 	}
-
-	// Check balance before withdrawal
-	if op.OperationType == operations.Withdraw && newBalance < 0 {
-		http.Error(w, fmt.Sprintf("Insufficient funds to withdraw: balance %d, trying to withdraw %d", oldBalance, op.Amount), http.StatusPaymentRequired)
-		return
+	if errors.Is(err, operations.ErrOverflow) {
+		return operationResponse{}, balanceOverflow(oldBalance, op.Amount)
 	}
 
-	// Start transaction
-	tx, err := app.db.Begin(r.Context())
-	if err != nil {
-		log.Printf("Failed to begin operation transaction: %v\n", err)
-		http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
-		return
+	// Check balance before withdrawal; funds reserved by holds cannot be withdrawn,
+	// and a credit wallet may go below zero down to its credit limit
+	available := operations.Available(oldBalance, wallet.Held, wallet.CreditLimit)
+	if op.OperationType == operations.Withdraw && available < op.Amount {
+		return operationResponse{}, insufficientFunds("withdraw", available, op.Amount)
 	}
-	defer tx.Rollback(r.Context())
 
-	// Wrap queries with transaction
-	queriesWithTx := app.queries.WithTx(tx)
+	// Check withdrawal limits of the wallet
+	if op.OperationType == operations.Withdraw {
+		p, err := app.checkWithdrawalLimits(ctx, queriesWithTx, walletUUID, op.Amount)
+		if err != nil {
+			return operationResponse{}, internalError("Failed to check withdrawal limits", err)
+		}
+		if p != nil {
+			return operationResponse{}, p
+		}
+	}
 
-	// Insert operation in database
-	err = queriesWithTx.AddOperation(r.Context(), database.AddOperationParams{
+	// Insert operation in database and post it to the ledger, which updates the wallet balance
+	operationID, err := app.recordOperation(ctx, queriesWithTx, database.AddOperationParams{
 		WalletID:      walletUUID,
 		OperationType: op.OperationType,
 		Amount:        op.Amount,
-	})
+	}, wallet.Currency)
 	if err != nil {
-		log.Printf("Failed to add operation: %v\n", err)
-		http.Error(w, "Failed to add operation", http.StatusInternalServerError)
-		return
-	}
-
-	// Update wallet balance
-	err = queriesWithTx.UpdateWallet(r.Context(), database.UpdateWalletParams{
-		ID:      walletUUID,
-		Balance: newBalance,
-	})
-	if err != nil {
-		http.Error(w, "Failed to update wallet balance: "+err.Error(), http.StatusInternalServerError)
-		return
+		return operationResponse{}, internalError("Failed to record operation", err)
 	}
 
 	// Commit transaction
-	err = tx.Commit(r.Context())
+	err = tx.Commit(ctx)
 	if err != nil {
-		log.Printf("Failed to commit operation transaction: %v\n", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
+		return operationResponse{}, internalError("Failed to commit operation transaction", err)
 	}
+	committed = true
+	app.metrics.observeOperation(op.OperationType, op.Amount)
 
-	w.WriteHeader(http.StatusNoContent)
+	return operationResponse{
+		OperationID:    operationID,
+		WalletID:       walletUUID,
+		OperationType:  op.OperationType,
+		Amount:         op.Amount,
+		Balance:        newBalance,
+		BalanceDecimal: formatAmount(newBalance, wallet.Currency),
+	}, nil
 }
 
 func (app *application) handleGetWallets(w http.ResponseWriter, r *http.Request) {
-	// Get wallets from the database
-	wallets, err := app.queries.GetWallets(r.Context())
+	// Parse pagination, sorting and filter parameters
+	var q walletsQuery
+
+	limit, err := parseLimit(r)
 	if err != nil {
-		log.Printf("Failed to get wallets: %v\n", err)
-		http.Error(w, "Failed to retrieve wallets", http.StatusInternalServerError)
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
 		return
 	}
+	q.Limit = limit
+	q.SortBy = r.URL.Query().Get("sort")
 
-	// If no wallets are found, return an empty JSON array
-	if len(wallets) == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		_, err := fmt.Fprintln(w, "[]")
+	switch order := r.URL.Query().Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: order must be \"asc\" or \"desc\"", nil)
+		return
+	}
+
+	q.Status = r.URL.Query().Get("status")
+
+	if q.MinBalance, err = parseInt8Param(r, "min_balance"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if q.MaxBalance, err = parseInt8Param(r, "max_balance"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if q.CreatedFrom, err = parseTimestampParam(r, "created_from"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+	if q.CreatedTo, err = parseTimestampParam(r, "created_to"); err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+
+	if value := r.URL.Query().Get("include_total"); value != "" {
+		q.IncludeTotal, err = strconv.ParseBool(value)
 		if err != nil {
-			log.Printf("Failed to write response: %v\n", err)
-			http.Error(w, "Failed to write response", http.StatusInternalServerError)
+			writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: include_total must be a boolean", nil)
+			return
 		}
-		return
 	}
 
-	// Marshal wallets slice into JSON
-	walletsJSON, err := json.Marshal(wallets)
+	q.Cursor = r.URL.Query().Get("cursor")
+
+	// Get the page of wallets
+	page, err := app.listWallets(r.Context(), q)
 	if err != nil {
-		log.Printf("Failed to marshal wallets into JSON: %v\n", err)
-		http.Error(w, "Failed to marshal wallets", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Write response with wallets
-	w.Header().Set("Content-Type", "application/json")
-	writeResponse(w, string(walletsJSON))
+	writeJSON(w, http.StatusOK, page)
 }
 
-func (app *application) handleDeleteWallet(w http.ResponseWriter, r *http.Request) {
-	// Read and parse wallet UUID from path
-	walletUUID := pgtype.UUID{}
-	err := walletUUID.Scan(r.PathValue("wallet_id"))
-	if err != nil {
-		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
-		return
+// walletsQuery selects a page of wallets; zero values select the first page of all wallets
+// sorted by creation time
+type walletsQuery struct {
+	Limit        int32
+	SortBy       string
+	Descending   bool
+	Status       string
+	MinBalance   pgtype.Int8
+	MaxBalance   pgtype.Int8
+	CreatedFrom  pgtype.Timestamp
+	CreatedTo    pgtype.Timestamp
+	Cursor       string
+	IncludeTotal bool
+}
+
+// listWallets returns a page of wallets with their balances formatted in major currency units
+func (app *application) listWallets(ctx context.Context, q walletsQuery) (walletsPage, error) {
+	params := database.ListWalletsParams{
+		Descending:  q.Descending,
+		MinBalance:  q.MinBalance,
+		MaxBalance:  q.MaxBalance,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 1 || limit > maxPageLimit {
+		return walletsPage{}, newProblem(problemInvalidQueryParameter, "Invalid query parameter: limit must be an integer between 1 and 1000", nil)
+	}
+	// Fetch one extra row to find out whether there is a next page
+	params.RowLimit = limit + 1
+
+	params.SortBy = q.SortBy
+	if params.SortBy == "" {
+		params.SortBy = "created_at"
+	}
+	if !slices.Contains(database.WalletSortColumns, params.SortBy) {
+		return walletsPage{}, newProblem(problemInvalidQueryParameter, "Invalid query parameter: sort must be \"balance\", \"created_at\" or \"updated_at\"", nil)
+	}
+
+	if q.Status != "" {
+		if q.Status != walletActive && q.Status != walletFrozen && q.Status != walletClosed {
+			return walletsPage{}, newProblem(problemInvalidQueryParameter, "Invalid query parameter: status must be \"active\", \"frozen\" or \"closed\"", nil)
+		}
+		params.Status = pgtype.Text{String: q.Status, Valid: true}
+	}
+
+	if q.Cursor != "" {
+		var cursor walletsCursor
+		err := decodeCursor(q.Cursor, &cursor)
+		if err != nil || !cursor.ID.Valid {
+			return walletsPage{}, newProblem(problemInvalidQueryParameter, "Invalid query parameter: malformed cursor", nil)
+		}
+		// A cursor only makes sense in the sort order of the page it was taken from
+		if cursor.SortBy != params.SortBy || cursor.Descending != params.Descending {
+			return walletsPage{}, newProblem(problemInvalidQueryParameter, "Invalid query parameter: cursor does not match the sort order", nil)
+		}
+		params.CursorBalance = pgtype.Int8{Int64: cursor.Balance, Valid: true}
+		params.CursorTime = pgtype.Timestamp{Time: cursor.Time, Valid: true}
+		params.CursorID = cursor.ID
 	}
 
-	// Delete the wallet
-	err = app.queries.DeleteWallet(r.Context(), walletUUID)
+	// Get wallets from the database
+	wallets, err := app.queries.ListWallets(ctx, params)
 	if err != nil {
-		log.Printf("Failed to delete wallet: %v\n", err)
-		http.Error(w, "Failed to delete wallet", http.StatusInternalServerError)
+		return walletsPage{}, internalError("Failed to get wallets", err)
+	}
+
+	page := walletsPage{Wallets: []walletResponse{}}
+
+	// Point the cursor at the last wallet of the page if there are more wallets
+	if len(wallets) > int(limit) {
+		wallets = wallets[:limit]
+		nextCursor, err := encodeCursor(newWalletsCursor(params, wallets[limit-1]))
+		if err != nil {
+			return walletsPage{}, internalError("Failed to encode cursor", err)
+		}
+		page.NextCursor = &nextCursor
+	}
+
+	// Add balances formatted in major currency units and credit usage
+	for _, wallet := range wallets {
+		page.Wallets = append(page.Wallets, walletResponse{
+			Wallet:         wallet,
+			BalanceDecimal: formatAmount(wallet.Balance, wallet.Currency),
+			CreditUsed:     operations.CreditUsed(wallet.Balance),
+		})
+	}
+
+	// Count all matching wallets if requested; this scans every matching row, so it is optional
+	if q.IncludeTotal {
+		total, err := app.queries.CountListWallets(ctx, params)
+		if err != nil {
+			return walletsPage{}, internalError("Failed to count wallets", err)
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// handleDeleteWallet closes the wallet; wallets are never deleted, so their history is kept
+func (app *application) handleDeleteWallet(w http.ResponseWriter, r *http.Request) {
+	_, ok := app.changeWalletStatus(w, r, walletClosed)
+	if !ok {
 		return
 	}
 
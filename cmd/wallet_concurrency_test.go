@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandleOperationConcurrent fires parallel deposits and withdrawals at a single wallet
+// and checks that no update is lost. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestHandleOperationConcurrent(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	const (
+		workers        = 200
+		depositAmount  = 10
+		withdrawAmount = 30
+	)
+
+	var deposited, withdrawn atomic.Int64
+	var wg sync.WaitGroup
+	for i := range workers {
+		op := operations.Operation{OperationType: operations.Deposit, Amount: depositAmount}
+		if i%2 == 1 {
+			op = operations.Operation{OperationType: operations.Withdraw, Amount: withdrawAmount}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			switch code := postOperation(t, app, walletID, op); code {
+			case http.StatusOK:
+				if op.OperationType == operations.Deposit {
+					deposited.Add(op.Amount)
+				} else {
+					withdrawn.Add(op.Amount)
+				}
+			case http.StatusPaymentRequired:
+				// Rejected withdrawals are expected while the balance is low
+			default:
+				t.Errorf("Unexpected status code %d", code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	balance, err := app.queries.GetBalance(ctx, walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet balance: %v", err)
+	}
+
+	assert.Equal(t, deposited.Load()-withdrawn.Load(), balance)
+	assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+	assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+	assert.GreaterOrEqual(t, balance, int64(0))
+}
+
+// TestTransferConcurrent fires transfers in both directions between two wallets
+// and checks that they neither deadlock nor lose money.
+func TestTransferConcurrent(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	const (
+		initialBalance = 1000
+		workers        = 200
+		transferAmount = 25
+	)
+
+	walletIDs := make([]string, 2)
+	walletUUIDs := make([]pgtype.UUID, 2)
+	for i := range walletUUIDs {
+		var err error
+		walletUUIDs[i], err = app.queries.CreateWallet(ctx, "RUB")
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		walletIDs[i] = walletUUIDs[i].String()
+
+		code := postOperation(t, app, walletIDs[i], operations.Operation{OperationType: operations.Deposit, Amount: initialBalance})
+		if code != http.StatusOK {
+			t.Fatalf("Failed to deposit initial balance: status code %d", code)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		source, target := walletIDs[i%2], walletIDs[(i+1)%2]
+		op := operations.Operation{OperationType: operations.Transfer, Amount: transferAmount, TargetWalletID: target}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			code := postOperation(t, app, source, op)
+			if code != http.StatusOK && code != http.StatusPaymentRequired {
+				t.Errorf("Unexpected status code %d", code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var total int64
+	for _, walletUUID := range walletUUIDs {
+		balance, err := app.queries.GetBalance(ctx, walletUUID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet balance: %v", err)
+		}
+		assert.Equal(t, historyBalance(t, dbPool, walletUUID), balance)
+		assert.Equal(t, ledgerBalance(t, dbPool, walletUUID), balance)
+		assert.GreaterOrEqual(t, balance, int64(0))
+		total += balance
+	}
+	assert.Equal(t, int64(2*initialBalance), total)
+}
+
+// newTestApp connects the application to the database in TEST_DB_URL,
+// skipping the test if it is not set
+func newTestApp(t *testing.T) (*application, *pgxpool.Pool) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	dbPool, err := pgxpool.New(context.Background(), dbURL)
+	if err != nil {
+		t.Fatalf("Failed to create database connection pool: %v", err)
+	}
+	t.Cleanup(dbPool.Close)
+
+	app := &application{
+		db:      dbPool,
+		queries: database.New(dbPool),
+	}
+	return app, dbPool
+}
+
+// postOperation sends an operation request for the wallet to the handler and returns the status code
+func postOperation(t *testing.T, app *application, walletID string, op operations.Operation) int {
+	body, err := json.Marshal(op)
+	if err != nil {
+		t.Errorf("Failed to marshal request body: %v", err)
+		return 0
+	}
+	req := httptest.NewRequest("POST", "/api/v1/wallets/"+walletID, bytes.NewBuffer(body))
+	req.SetPathValue("wallet_id", walletID)
+	w := httptest.NewRecorder()
+
+	contract(t, app.handleOperation).ServeHTTP(w, req)
+
+	if w.Code >= http.StatusInternalServerError {
+		t.Logf("Operation failed: %s", w.Body.String())
+	}
+	return w.Code
+}
+
+// historyBalance calculates the wallet balance from its operations
+func historyBalance(t *testing.T, dbPool *pgxpool.Pool, walletUUID pgtype.UUID) int64 {
+	var balance int64
+	err := dbPool.QueryRow(context.Background(), `
+		SELECT COALESCE(SUM(CASE WHEN operation_type = 'deposit' THEN amount ELSE -amount END), 0)::bigint
+		FROM operations WHERE wallet_id = $1`, walletUUID).Scan(&balance)
+	if err != nil {
+		t.Fatalf("Failed to sum wallet operations: %v", err)
+	}
+	return balance
+}
+
+// ledgerBalance calculates the wallet balance from the entries posted to its ledger account
+func ledgerBalance(t *testing.T, dbPool *pgxpool.Pool, walletUUID pgtype.UUID) int64 {
+	var balance int64
+	err := dbPool.QueryRow(context.Background(), `
+		SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)::bigint
+		FROM ledger_entries WHERE account_id = $1`, walletUUID).Scan(&balance)
+	if err != nil {
+		t.Fatalf("Failed to sum wallet ledger entries: %v", err)
+	}
+	return balance
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/chtozamm/javacode-wallet/internal/database"
 	"github.com/chtozamm/javacode-wallet/internal/mocks"
 	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,12 +26,16 @@ func TestHandleGetBalance(t *testing.T) {
 	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
 
 	tests := []struct {
-		name         string
-		walletID     string
-		mockBalance  int32
-		mockError    error
-		expectedCode int
-		expectedBody string
+		name            string
+		walletID        string
+		query           string
+		mockBalance     int64
+		mockError       error
+		accept          string
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
 	}{
 		{
 			name:         "Valid wallet ID",
@@ -37,28 +46,79 @@ func TestHandleGetBalance(t *testing.T) {
 			expectedBody: "100\n",
 		},
 		{
-			name:         "Wallet not found",
+			name:         "Available balance",
 			walletID:     validUUID,
-			mockBalance:  0,
-			mockError:    sql.ErrNoRows,
-			expectedCode: http.StatusNotFound,
-			expectedBody: "Wallet not found\n",
+			query:        "?balance=available",
+			mockBalance:  70,
+			mockError:    nil,
+			expectedCode: http.StatusOK,
+			expectedBody: "70\n",
 		},
 		{
-			name:         "Invalid wallet ID",
-			walletID:     invalidUUID,
-			mockBalance:  0,
-			mockError:    nil,
-			expectedCode: http.StatusBadRequest,
-			expectedBody: "Invalid wallet ID\n",
+			name:            "Invalid balance kind",
+			walletID:        validUUID,
+			query:           "?balance=pending",
+			mockBalance:     100,
+			mockError:       nil,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: balance must be \"ledger\" or \"available\"",
 		},
 		{
-			name:         "Unexpected error",
+			name:         "Historical balance",
 			walletID:     validUUID,
-			mockBalance:  100,
-			mockError:    errors.New("Unexpected error"),
-			expectedCode: http.StatusInternalServerError,
-			expectedBody: "Failed to get wallet balance\n",
+			query:        "?as_of=2025-01-01T00:00:00Z",
+			mockBalance:  40,
+			mockError:    nil,
+			expectedCode: http.StatusOK,
+			expectedBody: "40\n",
+		},
+		{
+			name:            "Invalid as_of",
+			walletID:        validUUID,
+			query:           "?as_of=yesterday",
+			mockBalance:     100,
+			mockError:       nil,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: as_of must be an RFC 3339 timestamp",
+		},
+		{
+			name:            "Historical available balance",
+			walletID:        validUUID,
+			query:           "?balance=available&as_of=2025-01-01T00:00:00Z",
+			mockBalance:     100,
+			mockError:       nil,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: as_of can only be used with the ledger balance",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			mockBalance:     0,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			mockBalance:     0,
+			mockError:       nil,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+		{
+			name:            "Unexpected error",
+			walletID:        validUUID,
+			mockBalance:     100,
+			mockError:       errors.New("Unexpected error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: "internal_error",
+			expectedDetail:  "Failed to get wallet balance",
 		},
 	}
 
@@ -75,20 +135,28 @@ func TestHandleGetBalance(t *testing.T) {
 			}
 
 			// Create and configure a new HTTP request
-			req := httptest.NewRequest("GET", "/api/v1/wallets/"+tc.walletID, nil)
+			req := httptest.NewRequest("GET", "/api/v1/wallets/"+tc.walletID+tc.query, nil)
 			req.SetPathValue("wallet_id", tc.walletID)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
 
 			// Create a new response recorder
 			w := httptest.NewRecorder()
 
 			// Call the handler
-			app.handleGetBalance(w, req)
+			contract(t, app.handleGetBalance).ServeHTTP(w, req)
 
 			// Check the response status code
 			res := w.Result()
 			assert.Equal(t, tc.expectedCode, res.StatusCode)
 
 			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
 			body, _ := io.ReadAll(res.Body)
 			assert.Equal(t, tc.expectedBody, string(body))
 		})
@@ -98,101 +166,248 @@ func TestHandleGetBalance(t *testing.T) {
 func TestHandleOperation(t *testing.T) {
 	validUUID := "fe6403a7-8b42-4449-abe6-a8508199a0d4"
 	invalidUUID := "fe6403a7-8b421-449-abe6-a8508199a0d4"
+	walletUUID := pgtype.UUID{}
+	_ = walletUUID.Scan(validUUID)
 
 	tests := []struct {
-		name         string
-		walletID     string
-		mockBalance  int32
-		mockError    error
-		op           operations.Operation
-		expectedCode int
-		expectedBody string
+		name            string
+		walletID        string
+		mockBalance     int64
+		mockRow         []any
+		mockQueryRows   map[string][]any
+		mockError       error
+		op              operations.Operation
+		accept          string
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
 	}{
-		// NOTE: the commented test cases below cause panic due to the unimplemented database connection for transaction mocking...
-		// Using two tables and transactions has made testing more complex.
-		// {
-		// 	name:         "Valid deposit operation",
-		// 	walletID:     validUUID,
-		// 	mockBalance:  100,
-		// 	mockError:    nil,
-		// 	op:           operation{OperationType: deposit, Amount: 50},
-		// 	expectedCode: http.StatusNoContent,
-		// 	expectedBody: "",
-		// },
-		// {
-		// 	name:         "Valid withdraw operation",
-		// 	walletID:     validUUID,
-		// 	mockBalance:  100,
-		// 	mockError:    nil,
-		// 	op:           operation{OperationType: withdraw, Amount: 30},
-		// 	expectedCode: http.StatusNoContent,
-		// 	expectedBody: "",
-		// },
-		{
-			name:         "Invalid wallet ID",
-			walletID:     invalidUUID,
-			mockBalance:  0,
+		{
+			name:         "Valid deposit operation",
+			walletID:     validUUID,
+			mockBalance:  100,
 			mockError:    nil,
-			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 100},
-			expectedCode: http.StatusBadRequest,
-			expectedBody: "Invalid wallet ID\n",
+			op:           operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode: http.StatusOK,
+			expectedBody: "150\n",
 		},
 		{
-			name:         "Insufficient funds for withdrawal",
+			name:         "Valid withdraw operation",
 			walletID:     validUUID,
-			mockBalance:  50,
+			mockBalance:  100,
 			mockError:    nil,
-			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 100},
-			expectedCode: http.StatusPaymentRequired,
-			expectedBody: "Insufficient funds to withdraw: balance 50, trying to withdraw 100\n",
+			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 30},
+			expectedCode: http.StatusOK,
+			expectedBody: "70\n",
 		},
 		{
-			name:         "Wallet not found",
+			name:         "JSON response",
 			walletID:     validUUID,
-			mockBalance:  0,
-			mockError:    sql.ErrNoRows,
+			mockBalance:  100,
 			op:           operations.Operation{OperationType: operations.Deposit, Amount: 50},
-			expectedCode: http.StatusNotFound,
-			expectedBody: "Wallet not found\n",
+			accept:       "application/json",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"operation_id":null,"wallet_id":"` + validUUID + `","operation_type":"deposit","amount":50,"balance":150,"balance_decimal":"150"}` + "\n",
+		},
+		{
+			name:            "Invalid wallet ID",
+			walletID:        invalidUUID,
+			mockBalance:     0,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Withdraw, Amount: 100},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+		{
+			name:            "Insufficient funds for withdrawal",
+			walletID:        validUUID,
+			mockBalance:     50,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Withdraw, Amount: 100},
+			expectedCode:    http.StatusPaymentRequired,
+			expectedProblem: "insufficient_funds",
+			expectedDetail:  "Insufficient funds to withdraw: balance 50, trying to withdraw 100",
+		},
+		{
+			name:            "Wallet not found",
+			walletID:        validUUID,
+			mockBalance:     0,
+			mockError:       sql.ErrNoRows,
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid operation type",
+			walletID:        validUUID,
+			mockBalance:     100,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: "invalid", Amount: 50},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_operation_type",
+			expectedDetail:  "Unsupported operation type: expected operation_type to be \"deposit\", \"withdraw\" or \"transfer\"",
+		},
+		{
+			name:            "Invalid amount",
+			walletID:        validUUID,
+			mockBalance:     100,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: -10},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_amount",
+			expectedDetail:  "Amount must be greater than zero",
+		},
+		{
+			name:            "Deposit overflows balance",
+			walletID:        validUUID,
+			mockBalance:     math.MaxInt64 - 10,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "balance_overflow",
+			expectedDetail:  "Operation would overflow wallet balance: balance 9223372036854775797, amount 50",
+		},
+		{
+			name:            "Currency mismatch",
+			walletID:        validUUID,
+			mockRow:         []any{int64(100), "RUB"},
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: 50, Currency: "USD"},
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: "currency_mismatch",
+			expectedDetail:  "Currency mismatch: wallet currency is RUB, operation currency is USD",
 		},
 		{
-			name:         "Invalid operation type",
+			name:         "Matching currency",
 			walletID:     validUUID,
-			mockBalance:  100,
+			mockRow:      []any{int64(100), "RUB"},
 			mockError:    nil,
-			op:           operations.Operation{OperationType: "invalid", Amount: 50},
-			expectedCode: http.StatusBadRequest,
-			expectedBody: "Unsupported operation type: expected operation_type to be \"deposit\" or \"withdraw\"\n",
+			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 50, Currency: "rub"},
+			expectedCode: http.StatusOK,
+			expectedBody: "50\n",
 		},
 		{
-			name:         "Invalid amount",
+			name:            "Withdrawal from a frozen wallet",
+			walletID:        validUUID,
+			mockRow:         []any{int64(100), "RUB", int64(0), walletFrozen},
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Withdraw, Amount: 50},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_frozen",
+			expectedDetail:  "Wallet is frozen: withdrawals are not allowed",
+		},
+		{
+			name:         "Deposit to a frozen wallet",
 			walletID:     validUUID,
-			mockBalance:  100,
+			mockRow:      []any{int64(100), "RUB", int64(0), walletFrozen},
 			mockError:    nil,
-			op:           operations.Operation{OperationType: operations.Deposit, Amount: -10},
-			expectedCode: http.StatusBadRequest,
-			expectedBody: "Amount must be greater than zero\n",
+			op:           operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode: http.StatusOK,
+			expectedBody: "150\n",
+		},
+		{
+			name:        "Daily withdrawal limit exceeded",
+			walletID:    validUUID,
+			mockBalance: 100,
+			mockQueryRows: map[string][]any{
+				"GetWalletLimits":     {walletUUID, pgtype.Int8{Int64: 110, Valid: true}},
+				"GetWithdrawalTotals": {int64(90), int64(90)},
+			},
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Withdraw, Amount: 30},
+			expectedCode:    http.StatusForbidden,
+			expectedProblem: "withdrawal_limit_exceeded",
+			expectedDetail:  "Daily withdrawal limit exceeded: limit 110, withdrawn today 90, trying to withdraw 30",
+		},
+		{
+			name:        "Withdrawal within daily limit",
+			walletID:    validUUID,
+			mockBalance: 100,
+			mockQueryRows: map[string][]any{
+				"GetWalletLimits":     {walletUUID, pgtype.Int8{Int64: 200, Valid: true}},
+				"GetWithdrawalTotals": {int64(90), int64(90)},
+			},
+			mockError:    nil,
+			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 30},
+			expectedCode: http.StatusOK,
+			expectedBody: "70\n",
 		},
 		{
-			name:         "Unexpected error",
+			name:         "Withdrawal within credit limit",
 			walletID:     validUUID,
-			mockBalance:  0,
-			mockError:    errors.New("unexpected error"),
-			op:           operations.Operation{OperationType: operations.Deposit, Amount: 50},
-			expectedCode: http.StatusInternalServerError,
-			expectedBody: "Failed to get wallet balance\n",
+			mockRow:      []any{int64(50), "RUB", int64(0), walletActive, int64(100)},
+			mockError:    nil,
+			op:           operations.Operation{OperationType: operations.Withdraw, Amount: 100},
+			expectedCode: http.StatusOK,
+			expectedBody: "-50\n",
+		},
+		{
+			name:            "Withdrawal beyond credit limit",
+			walletID:        validUUID,
+			mockRow:         []any{int64(50), "RUB", int64(0), walletActive, int64(20)},
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Withdraw, Amount: 100},
+			expectedCode:    http.StatusPaymentRequired,
+			expectedProblem: "insufficient_funds",
+			expectedDetail:  "Insufficient funds to withdraw: balance 70, trying to withdraw 100",
+		},
+		{
+			name:            "Deposit to a closed wallet",
+			walletID:        validUUID,
+			mockRow:         []any{int64(0), "RUB", int64(0), walletClosed},
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: "wallet_closed",
+			expectedDetail:  "Wallet is closed",
+		},
+		{
+			name:            "Invalid target wallet ID for transfer",
+			walletID:        validUUID,
+			mockBalance:     100,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Transfer, Amount: 50, TargetWalletID: invalidUUID},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid target wallet ID",
+		},
+		{
+			name:            "Transfer to the same wallet",
+			walletID:        validUUID,
+			mockBalance:     100,
+			mockError:       nil,
+			op:              operations.Operation{OperationType: operations.Transfer, Amount: 50, TargetWalletID: validUUID},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "same_wallet",
+			expectedDetail:  "Cannot transfer to the same wallet",
+		},
+		{
+			name:            "Unexpected error",
+			walletID:        validUUID,
+			mockBalance:     0,
+			mockError:       errors.New("unexpected error"),
+			op:              operations.Operation{OperationType: operations.Deposit, Amount: 50},
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: "internal_error",
+			expectedDetail:  "Failed to get wallet balance",
 		},
 	}
 
 	for _, tc := range tests {
 		mockDB := &mocks.DBTX{
-			Balance: tc.mockBalance,
-			Err:     tc.mockError,
+			Balance:   tc.mockBalance,
+			Row:       tc.mockRow,
+			QueryRows: tc.mockQueryRows,
+			Err:       tc.mockError,
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
 			// Create a new application with the mock queries
 			app := &application{
+				db:      mockDB,
 				queries: database.New(mockDB),
 			}
 
@@ -204,6 +419,9 @@ func TestHandleOperation(t *testing.T) {
 			req := httptest.NewRequest("POST", "/api/v1/wallets/"+tc.walletID, bytes.NewBuffer(body))
 			req.SetPathValue("wallet_id", tc.walletID)
 			req.Header.Set("Content-Type", "application/json")
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
 
 			// Create a new response recorder
 			w := httptest.NewRecorder()
@@ -216,6 +434,10 @@ func TestHandleOperation(t *testing.T) {
 			assert.Equal(t, tc.expectedCode, res.StatusCode)
 
 			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
 			body, err = io.ReadAll(res.Body)
 			if err != nil {
 				t.Fatalf("Failed to read response body: %v", err)
@@ -225,3 +447,276 @@ func TestHandleOperation(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleCreateWallet(t *testing.T) {
+	walletUUID := pgtype.UUID{}
+	if err := walletUUID.Scan("fe6403a7-8b42-4449-abe6-a8508199a0d4"); err != nil {
+		t.Fatalf("Failed to parse UUID: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		body            string
+		mockError       error
+		accept          string
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Default currency",
+			body:         "",
+			mockError:    nil,
+			expectedCode: http.StatusCreated,
+			expectedBody: walletUUID.String() + "\n",
+		},
+		{
+			name:         "Explicit currency",
+			body:         `{"currency":"usd"}`,
+			mockError:    nil,
+			expectedCode: http.StatusCreated,
+			expectedBody: walletUUID.String() + "\n",
+		},
+		{
+			name:         "JSON response",
+			body:         "",
+			accept:       "application/json",
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"` + walletUUID.String() + `"}` + "\n",
+		},
+		{
+			name:            "Unsupported currency",
+			body:            `{"currency":"ABC"}`,
+			mockError:       nil,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "unsupported_currency",
+			expectedDetail:  "Unsupported currency: expected an ISO 4217 currency code",
+		},
+		{
+			name:            "Unexpected error",
+			body:            "",
+			mockError:       errors.New("unexpected error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: "internal_error",
+			expectedDetail:  "Failed to create wallet",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Row: []any{walletUUID},
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a new application with the mock queries
+			app := &application{
+				queries:         database.New(mockDB),
+				defaultCurrency: "RUB",
+			}
+
+			// Create and configure a new HTTP request
+			req := httptest.NewRequest("POST", "/api/v1/wallets", strings.NewReader(tc.body))
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			// Create a new response recorder
+			w := httptest.NewRecorder()
+
+			// Call the handler
+			contract(t, app.handleCreateWallet).ServeHTTP(w, req)
+
+			// Check the response status code
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			// Check the response body
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestHandleGetWallets(t *testing.T) {
+	id := pgtype.UUID{}
+	if err := id.Scan("fe6403a7-8b42-4449-abe6-a8508199a0d4"); err != nil {
+		t.Fatalf("Failed to parse UUID: %v", err)
+	}
+	balanceCursor, _ := encodeCursor(walletsCursor{SortBy: "balance", Descending: true, Balance: 100, ID: id})
+
+	tests := []struct {
+		name            string
+		query           string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Default page",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"wallets":[],"next_cursor":null}` + "\n",
+		},
+		{
+			name:         "Sorted and filtered page with total",
+			query:        "?sort=balance&order=desc&status=active&min_balance=10&max_balance=1000&created_from=2025-01-01T00:00:00Z&include_total=true&cursor=" + balanceCursor,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"wallets":[],"next_cursor":null,"total":0}` + "\n",
+		},
+		{
+			name:            "Unsupported sort column",
+			query:           "?sort=currency",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: sort must be \"balance\", \"created_at\" or \"updated_at\"",
+		},
+		{
+			name:            "Invalid order",
+			query:           "?order=random",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: order must be \"asc\" or \"desc\"",
+		},
+		{
+			name:            "Invalid status",
+			query:           "?status=deleted",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: status must be \"active\", \"frozen\" or \"closed\"",
+		},
+		{
+			name:            "Invalid balance range",
+			query:           "?max_balance=lots",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: max_balance must be an integer",
+		},
+		{
+			name:            "Invalid creation window",
+			query:           "?created_to=tomorrow",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: created_to must be an RFC 3339 timestamp",
+		},
+		{
+			name:            "Invalid include_total",
+			query:           "?include_total=maybe",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: include_total must be a boolean",
+		},
+		{
+			name:            "Invalid limit",
+			query:           "?limit=0",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: limit must be an integer between 1 and 1000",
+		},
+		{
+			name:            "Malformed cursor",
+			query:           "?cursor=not-a-cursor",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: malformed cursor",
+		},
+		{
+			name:            "Cursor from another sort order",
+			query:           "?sort=balance&cursor=" + balanceCursor,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_query_parameter",
+			expectedDetail:  "Invalid query parameter: cursor does not match the sort order",
+		},
+		{
+			name:            "Unexpected error",
+			mockError:       errors.New("unexpected error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: "internal_error",
+			expectedDetail:  "Failed to get wallets",
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{
+			Err: tc.mockError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/wallets"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			contract(t, app.handleGetWallets).ServeHTTP(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+				return
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+// TestListWallets pages through wallets sorted by balance and checks that every wallet
+// is listed exactly once. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestListWallets(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	// Only list the wallets created by this test
+	var start time.Time
+	if err := dbPool.QueryRow(ctx, "SELECT LOCALTIMESTAMP").Scan(&start); err != nil {
+		t.Fatalf("Failed to get database time: %v", err)
+	}
+
+	for _, amount := range []int64{30, 10, 50, 20, 40} {
+		walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, postOperation(t, app, walletUUID.String(), operations.Operation{OperationType: operations.Deposit, Amount: amount}))
+	}
+
+	var balances []int64
+	query := "?sort=balance&order=desc&limit=2&include_total=true&created_from=" + start.UTC().Format(time.RFC3339Nano)
+	for cursor := ""; ; {
+		req := httptest.NewRequest("GET", "/api/v1/wallets"+query+cursor, nil)
+		w := httptest.NewRecorder()
+		contract(t, app.handleGetWallets).ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			return
+		}
+
+		var page walletsPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode wallets page: %v", err)
+		}
+		if assert.NotNil(t, page.Total) {
+			assert.Equal(t, int64(5), *page.Total)
+		}
+		for _, wallet := range page.Wallets {
+			balances = append(balances, wallet.Balance)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = "&cursor=" + *page.NextCursor
+	}
+
+	assert.Equal(t, []int64{50, 40, 30, 20, 10}, balances)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/webhooks"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// webhookBatchSize limits the number of events fanned out and deliveries sent in one dispatch
+	webhookBatchSize = 100
+	// webhookLeaseMargin is added to the webhook timeout to get how long a claimed delivery
+	// is hidden from other dispatchers
+	webhookLeaseMargin = 30 * time.Second
+	// maxWebhookResponseSize limits how much of a receiver's response is read
+	maxWebhookResponseSize = 64 << 10
+)
+
+// webhookSubscriptionResponse is a webhook subscription; its secret is only shown when it is created
+type webhookSubscriptionResponse struct {
+	database.WebhookSubscription
+	Secret string `json:"secret,omitempty"`
+}
+
+func (app *application) handleCreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON from request to struct
+	var req webhooks.SubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequestBody, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	// Check subscriber URL
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeProblem(w, r, problemInvalidURL, "Invalid URL: expected an absolute http or https URL", nil)
+		return
+	}
+
+	// Parse optional wallet UUID to receive the events of a single wallet
+	walletUUID := pgtype.UUID{}
+	if req.WalletID != "" {
+		err = walletUUID.Scan(req.WalletID)
+		if err != nil {
+			writeProblem(w, r, problemInvalidWalletID, "Invalid wallet ID", nil)
+			return
+		}
+
+		_, err = app.queries.GetWallet(r.Context(), walletUUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeProblem(w, r, problemWalletNotFound, "Wallet not found", nil)
+				return
+			}
+			serverError(w, r, "Failed to get wallet", err)
+			return
+		}
+	}
+
+	subscription, err := app.queries.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		Url:      u.String(),
+		Secret:   newWebhookSecret(),
+		WalletID: walletUUID,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to create webhook subscription", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, webhookSubscriptionResponse{WebhookSubscription: subscription, Secret: subscription.Secret})
+}
+
+func (app *application) handleGetWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := app.queries.GetWebhookSubscriptions(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to get webhook subscriptions", err)
+		return
+	}
+
+	response := make([]webhookSubscriptionResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		response[i] = webhookSubscriptionResponse{WebhookSubscription: subscription}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleDeleteWebhookSubscription deactivates the subscription; its deliveries are kept
+func (app *application) handleDeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	// Read and parse subscription UUID from path
+	subscriptionUUID := pgtype.UUID{}
+	err := subscriptionUUID.Scan(r.PathValue("subscription_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidID, "Invalid webhook subscription ID", nil)
+		return
+	}
+
+	deactivated, err := app.queries.DeactivateWebhookSubscription(r.Context(), subscriptionUUID)
+	if err != nil {
+		serverError(w, r, "Failed to deactivate webhook subscription", err)
+		return
+	}
+	if deactivated == 0 {
+		writeProblem(w, r, problemSubscriptionNotFound, "Webhook subscription not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: "+err.Error(), nil)
+		return
+	}
+
+	// Read optional status filter, e.g. "dead" to find deliveries that need to be redelivered
+	status := pgtype.Text{}
+	if value := r.URL.Query().Get("status"); value != "" {
+		if value != webhooks.Pending && value != webhooks.Delivered && value != webhooks.Dead {
+			writeProblem(w, r, problemInvalidQueryParameter, "Invalid query parameter: status must be \"pending\", \"delivered\" or \"dead\"", nil)
+			return
+		}
+		status = pgtype.Text{String: value, Valid: true}
+	}
+
+	deliveries, err := app.queries.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		Status:        status,
+		MaxDeliveries: limit,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to get webhook deliveries", err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []database.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// handleRedeliverWebhook schedules a delivered or dead delivery to be sent again with a fresh set of attempts
+func (app *application) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	// Read and parse delivery UUID from path
+	deliveryUUID := pgtype.UUID{}
+	err := deliveryUUID.Scan(r.PathValue("delivery_id"))
+	if err != nil {
+		writeProblem(w, r, problemInvalidID, "Invalid webhook delivery ID", nil)
+		return
+	}
+
+	delivery, err := app.queries.RedeliverWebhookDelivery(r.Context(), deliveryUUID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			serverError(w, r, "Failed to redeliver webhook", err)
+			return
+		}
+
+		// Nothing was updated: the delivery either does not exist or is still pending
+		_, err = app.queries.GetWebhookDelivery(r.Context(), deliveryUUID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeProblem(w, r, problemDeliveryNotFound, "Webhook delivery not found", nil)
+		case err != nil:
+			serverError(w, r, "Failed to get webhook delivery", err)
+		default:
+			writeProblem(w, r, problemDeliveryPending, "Webhook delivery is already pending", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+// runWebhookDispatcher periodically delivers webhooks for new outbox events until ctx is canceled
+func (app *application) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				sent, err := app.dispatchWebhooks(ctx)
+				if err != nil {
+					slog.Error("Failed to dispatch webhooks", "error", err)
+					break
+				}
+				if sent < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// runWebhookEventCleanup periodically deletes dispatched outbox events older than retention,
+// together with their finished deliveries, until ctx is canceled
+func (app *application) runWebhookEventCleanup(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := app.queries.DeleteExpiredWebhookEvents(ctx, pgtype.Interval{Microseconds: retention.Microseconds(), Valid: true})
+			if err != nil {
+				slog.Error("Failed to delete expired webhook events", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("Deleted expired webhook events", "count", deleted)
+			}
+		}
+	}
+}
+
+// dispatchWebhooks fans new outbox events out to the subscriptions they match and sends one batch
+// of due deliveries. It returns the number of deliveries sent, successfully or not.
+func (app *application) dispatchWebhooks(ctx context.Context) (int, error) {
+	for {
+		fannedOut, err := app.queries.FanOutOutboxEvents(ctx, webhookBatchSize)
+		if err != nil {
+			return 0, fmt.Errorf("fan out outbox events: %w", err)
+		}
+		if fannedOut < webhookBatchSize {
+			break
+		}
+	}
+
+	lease := app.webhookClient.Timeout + webhookLeaseMargin
+	deliveries, err := app.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		Lease:         pgtype.Interval{Microseconds: lease.Microseconds(), Valid: true},
+		MaxDeliveries: webhookBatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.deliverWebhook(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliverWebhook makes one attempt to send a claimed delivery and records its outcome:
+// a failed delivery is retried with exponential backoff until it runs out of attempts and becomes dead
+func (app *application) deliverWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+	statusCode, sendErr := sendWebhook(ctx, app.webhookClient, delivery)
+	lastStatusCode := pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0}
+
+	var err error
+	if sendErr == nil {
+		err = app.queries.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: lastStatusCode,
+		})
+	} else {
+		status := webhooks.Pending
+		if delivery.Attempts >= webhooks.MaxAttempts {
+			status = webhooks.Dead
+			slog.Warn("Webhook delivery is dead", "delivery_id", delivery.ID.String(), "attempts", delivery.Attempts, "error", sendErr)
+		}
+		err = app.queries.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			ID:             delivery.ID,
+			Status:         status,
+			NextAttemptAt:  pgtype.Timestamp{Time: time.Now().UTC().Add(webhooks.Backoff(int(delivery.Attempts))), Valid: true},
+			LastStatusCode: lastStatusCode,
+			LastError:      pgtype.Text{String: sendErr.Error(), Valid: true},
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID.String(), "error", err)
+	}
+}
+
+// sendWebhook posts the event payload to the subscriber URL, signed with the subscription secret.
+// It returns the response status code, if any, and an error unless the receiver responded with 2xx.
+func sendWebhook(ctx context.Context, client *http.Client, delivery database.ClaimWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.HeaderDeliveryID, delivery.ID.String())
+	req.Header.Set(webhooks.HeaderEvent, delivery.EventType)
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// newWebhookSecret generates a random secret for signing the webhooks of a subscription
+func newWebhookSecret() string {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return "whsec_" + hex.EncodeToString(secret)
+}
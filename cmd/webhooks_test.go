@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chtozamm/javacode-wallet/internal/database"
+	"github.com/chtozamm/javacode-wallet/internal/mocks"
+	"github.com/chtozamm/javacode-wallet/internal/operations"
+	"github.com/chtozamm/javacode-wallet/internal/webhooks"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver is a local subscriber that checks webhook signatures and records the events it receives
+type webhookReceiver struct {
+	*httptest.Server
+	secret string
+	// statusCode is the status code the receiver responds with
+	statusCode atomic.Int32
+
+	mu     sync.Mutex
+	events []map[string]any
+}
+
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	receiver := &webhookReceiver{secret: secret}
+	receiver.statusCode.Store(http.StatusNoContent)
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+		if !webhooks.Verify(receiver.secret, timestamp, body, r.Header.Get(webhooks.HeaderSignature)) {
+			t.Errorf("Invalid webhook signature %q", r.Header.Get(webhooks.HeaderSignature))
+		}
+
+		var event map[string]any
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("Invalid webhook body: %v", err)
+		}
+		assert.Equal(t, r.Header.Get(webhooks.HeaderEvent), event["type"])
+
+		receiver.mu.Lock()
+		receiver.events = append(receiver.events, event)
+		receiver.mu.Unlock()
+
+		w.WriteHeader(int(receiver.statusCode.Load()))
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// received returns the events received so far
+func (receiver *webhookReceiver) received() []map[string]any {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return append([]map[string]any(nil), receiver.events...)
+}
+
+func TestSendWebhook(t *testing.T) {
+	receiver := newWebhookReceiver(t, "secret")
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		statusCode   int
+		expectedCode int
+		expectError  bool
+	}{
+		{name: "Delivered", url: receiver.URL, statusCode: http.StatusNoContent, expectedCode: http.StatusNoContent},
+		{name: "Receiver fails", url: receiver.URL, statusCode: http.StatusInternalServerError, expectedCode: http.StatusInternalServerError, expectError: true},
+		{name: "Receiver redirects", url: receiver.URL, statusCode: http.StatusNotModified, expectedCode: http.StatusNotModified, expectError: true},
+		{name: "Receiver is unreachable", url: closed.URL, expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver.statusCode.Store(int32(tc.statusCode))
+
+			statusCode, err := sendWebhook(context.Background(), receiver.Client(), database.ClaimWebhookDeliveriesRow{
+				ID:        newUUID(),
+				Attempts:  1,
+				EventType: "balance.changed",
+				Payload:   []byte(`{"type":"balance.changed","balance":100}`),
+				Url:       tc.url,
+				Secret:    "secret",
+			})
+			assert.Equal(t, tc.expectedCode, statusCode)
+			assert.Equal(t, tc.expectError, err != nil)
+		})
+	}
+}
+
+func TestHandleCreateWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		mockError       error
+		expectedCode    int
+		expectedBody    string
+		expectedProblem string
+		expectedDetail  string
+	}{
+		{
+			name:         "Subscription to all wallets",
+			body:         `{"url":"https://example.com/webhooks"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Subscription to a single wallet",
+			body:         `{"url":"https://example.com/webhooks","wallet_id":"fe6403a7-8b42-4449-abe6-a8508199a0d4"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Wallet not found",
+			body:            `{"url":"https://example.com/webhooks","wallet_id":"fe6403a7-8b42-4449-abe6-a8508199a0d4"}`,
+			mockError:       sql.ErrNoRows,
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: "wallet_not_found",
+			expectedDetail:  "Wallet not found",
+		},
+		{
+			name:            "Invalid wallet ID",
+			body:            `{"url":"https://example.com/webhooks","wallet_id":"fe6403a7-8b421-449-abe6-a8508199a0d4"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_wallet_id",
+			expectedDetail:  "Invalid wallet ID",
+		},
+		{
+			name:            "Relative URL",
+			body:            `{"url":"/webhooks"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_url",
+			expectedDetail:  "Invalid URL: expected an absolute http or https URL",
+		},
+		{
+			name:            "Unsupported URL scheme",
+			body:            `{"url":"ftp://example.com/webhooks"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: "invalid_url",
+			expectedDetail:  "Invalid URL: expected an absolute http or https URL",
+		},
+		{
+			name:         "Invalid request body",
+			body:         `{"url":`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		mockDB := &mocks.DBTX{Err: tc.mockError}
+
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				db:      mockDB,
+				queries: database.New(mockDB),
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/admin/webhook-subscriptions", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			app.handleCreateWebhookSubscription(w, req)
+
+			res := w.Result()
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+
+			if tc.expectedProblem != "" {
+				assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+			}
+			if tc.expectedBody != "" {
+				if tc.expectedProblem != "" {
+					assertProblem(t, res, tc.expectedProblem, tc.expectedDetail)
+					return
+				}
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+// TestWebhookDispatch delivers wallet events to a local receiver, retries a failing delivery
+// until it is dead and redelivers it. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestWebhookDispatch(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	receiver := newWebhookReceiver(t, "secret")
+	app.webhookClient = receiver.Client()
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	subscription, err := app.queries.CreateWebhookSubscription(ctx, database.CreateWebhookSubscriptionParams{
+		Url:      receiver.URL,
+		Secret:   receiver.secret,
+		WalletID: walletUUID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create webhook subscription: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = app.queries.DeactivateWebhookSubscription(context.Background(), subscription.ID)
+	})
+
+	// A deposit is delivered with the new balance
+	assert.Equal(t, http.StatusOK, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: 100}))
+	_, err = app.dispatchWebhooks(ctx)
+	assert.NoError(t, err)
+
+	events := receiver.received()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "balance.changed", events[0]["type"])
+		assert.Equal(t, walletID, events[0]["wallet_id"])
+		assert.EqualValues(t, 100, events[0]["balance"])
+		assert.EqualValues(t, 100, events[0]["change"])
+	}
+
+	// A withdrawal fails to be delivered and is retried until it is dead
+	receiver.statusCode.Store(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusOK, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Withdraw, Amount: 30}))
+	for range webhooks.MaxAttempts {
+		// Make the delivery due without waiting for the backoff
+		_, err = dbPool.Exec(ctx, "UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE subscription_id = $1 AND status = 'pending'", subscription.ID)
+		assert.NoError(t, err)
+		_, err = app.dispatchWebhooks(ctx)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, receiver.received(), 1+webhooks.MaxAttempts)
+
+	var deliveryUUID pgtype.UUID
+	var status string
+	var attempts int32
+	err = dbPool.QueryRow(ctx, "SELECT id, status, attempts FROM webhook_deliveries WHERE subscription_id = $1 ORDER BY created_at DESC LIMIT 1", subscription.ID).
+		Scan(&deliveryUUID, &status, &attempts)
+	if err != nil {
+		t.Fatalf("Failed to get webhook delivery: %v", err)
+	}
+	assert.Equal(t, webhooks.Dead, status)
+	assert.EqualValues(t, webhooks.MaxAttempts, attempts)
+
+	// The dead delivery is redelivered once the receiver recovers
+	receiver.statusCode.Store(http.StatusOK)
+	req := httptest.NewRequest("POST", "/api/v1/admin/webhook-deliveries/"+deliveryUUID.String()+"/redeliver", nil)
+	req.SetPathValue("delivery_id", deliveryUUID.String())
+	w := httptest.NewRecorder()
+	app.handleRedeliverWebhook(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err = app.dispatchWebhooks(ctx)
+	assert.NoError(t, err)
+
+	delivery, err := app.queries.GetWebhookDelivery(ctx, deliveryUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, webhooks.Delivered, delivery.Status)
+
+	events = receiver.received()
+	if assert.Len(t, events, 2+webhooks.MaxAttempts) {
+		last := events[len(events)-1]
+		assert.EqualValues(t, 70, last["balance"])
+		assert.EqualValues(t, -30, last["change"])
+		assert.Equal(t, operations.Withdraw, last["operation"].(map[string]any)["operation_type"])
+	}
+}
+
+// TestWebhookEventCleanup deletes dispatched events past the retention period with their deliveries
+// and keeps events that are still being delivered. It needs a migrated PostgreSQL database in TEST_DB_URL.
+func TestWebhookEventCleanup(t *testing.T) {
+	ctx := context.Background()
+	app, dbPool := newTestApp(t)
+
+	receiver := newWebhookReceiver(t, "secret")
+	app.webhookClient = receiver.Client()
+
+	walletUUID, err := app.queries.CreateWallet(ctx, "RUB")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	walletID := walletUUID.String()
+
+	subscription, err := app.queries.CreateWebhookSubscription(ctx, database.CreateWebhookSubscriptionParams{
+		Url:      receiver.URL,
+		Secret:   receiver.secret,
+		WalletID: walletUUID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create webhook subscription: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = app.queries.DeactivateWebhookSubscription(context.Background(), subscription.ID)
+	})
+
+	// The deposit is delivered, the withdrawal is left pending for a retry
+	assert.Equal(t, http.StatusOK, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Deposit, Amount: 100}))
+	_, err = app.dispatchWebhooks(ctx)
+	assert.NoError(t, err)
+	receiver.statusCode.Store(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusOK, postOperation(t, app, walletID, operations.Operation{OperationType: operations.Withdraw, Amount: 30}))
+	_, err = app.dispatchWebhooks(ctx)
+	assert.NoError(t, err)
+
+	// countEvents returns the number of events of the wallet and of their deliveries
+	countEvents := func() (events, deliveries int) {
+		err := dbPool.QueryRow(ctx, `
+			SELECT COUNT(DISTINCT e.id), COUNT(d.id)
+			FROM outbox_events e LEFT JOIN webhook_deliveries d ON d.event_id = e.id AND d.subscription_id = $2
+			WHERE e.wallet_id = $1 AND e.event_type = 'balance.changed'`, walletUUID, subscription.ID).Scan(&events, &deliveries)
+		if err != nil {
+			t.Fatalf("Failed to count webhook events: %v", err)
+		}
+		return events, deliveries
+	}
+	events, deliveries := countEvents()
+	assert.Equal(t, 2, events)
+	assert.Equal(t, 2, deliveries)
+
+	// Events within the retention period are kept
+	retention := pgtype.Interval{Microseconds: time.Hour.Microseconds(), Valid: true}
+	_, err = app.queries.DeleteExpiredWebhookEvents(ctx, retention)
+	assert.NoError(t, err)
+	events, _ = countEvents()
+	assert.Equal(t, 2, events)
+
+	// Once they are older, only the event that is still pending delivery is kept
+	_, err = dbPool.Exec(ctx, "UPDATE outbox_events SET created_at = NOW() - INTERVAL '2 hours' WHERE wallet_id = $1", walletUUID)
+	if err != nil {
+		t.Fatalf("Failed to age webhook events: %v", err)
+	}
+	_, err = app.queries.DeleteExpiredWebhookEvents(ctx, retention)
+	assert.NoError(t, err)
+	events, deliveries = countEvents()
+	assert.Equal(t, 1, events)
+	assert.Equal(t, 1, deliveries)
+
+	// Pending deliveries to an inactive subscription do not keep the event
+	_, err = app.queries.DeactivateWebhookSubscription(ctx, subscription.ID)
+	assert.NoError(t, err)
+	_, err = app.queries.DeleteExpiredWebhookEvents(ctx, retention)
+	assert.NoError(t, err)
+	events, deliveries = countEvents()
+	assert.Equal(t, 0, events)
+	assert.Equal(t, 0, deliveries)
+}
@@ -0,0 +1,81 @@
+package currency
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency. Amounts in this currency are stored as integers
+// in minor units, e.g. cents: 10^Exponent minor units make up one major unit.
+type Currency struct {
+	Code     string
+	Exponent int
+}
+
+// exponents maps the codes of active ISO 4217 currencies to their minor-unit exponents.
+// Codes that are missing here have the default exponent of 2.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// codes lists the active ISO 4217 currency codes
+var codes = []string{
+	"AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN", "BAM", "BBD", "BDT",
+	"BGN", "BHD", "BIF", "BMD", "BND", "BOB", "BOV", "BRL", "BSD", "BTN", "BWP", "BYN", "BZD",
+	"CAD", "CDF", "CHE", "CHF", "CHW", "CLF", "CLP", "CNY", "COP", "COU", "CRC", "CUP", "CVE",
+	"CZK", "DJF", "DKK", "DOP", "DZD", "EGP", "ERN", "ETB", "EUR", "FJD", "FKP", "GBP", "GEL",
+	"GHS", "GIP", "GMD", "GNF", "GTQ", "GYD", "HKD", "HNL", "HTG", "HUF", "IDR", "ILS", "INR",
+	"IQD", "IRR", "ISK", "JMD", "JOD", "JPY", "KES", "KGS", "KHR", "KMF", "KPW", "KRW", "KWD",
+	"KYD", "KZT", "LAK", "LBP", "LKR", "LRD", "LSL", "LYD", "MAD", "MDL", "MGA", "MKD", "MMK",
+	"MNT", "MOP", "MRU", "MUR", "MVR", "MWK", "MXN", "MXV", "MYR", "MZN", "NAD", "NGN", "NIO",
+	"NOK", "NPR", "NZD", "OMR", "PAB", "PEN", "PGK", "PHP", "PKR", "PLN", "PYG", "QAR", "RON",
+	"RSD", "RUB", "RWF", "SAR", "SBD", "SCR", "SDG", "SEK", "SGD", "SHP", "SLE", "SOS", "SRD",
+	"SSP", "STN", "SVC", "SYP", "SZL", "THB", "TJS", "TMT", "TND", "TOP", "TRY", "TTD", "TWD",
+	"TZS", "UAH", "UGX", "USD", "USN", "UYI", "UYU", "UYW", "UZS", "VED", "VES", "VND", "VUV",
+	"WST", "XAF", "XCD", "XCG", "XOF", "XPF", "YER", "ZAR", "ZMW", "ZWG",
+}
+
+var currencies = make(map[string]Currency, len(codes))
+
+func init() {
+	for _, code := range codes {
+		exponent, ok := exponents[code]
+		if !ok {
+			exponent = 2
+		}
+		currencies[code] = Currency{Code: code, Exponent: exponent}
+	}
+}
+
+// Lookup finds a currency by its ISO 4217 code, ignoring case
+func Lookup(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// Format renders an amount of minor units as a decimal string in major units,
+// e.g. 12345 is "123.45" for USD and "12345" for JPY
+func (c Currency) Format(amount int64) string {
+	// Take the absolute value as unsigned, so the minimum int64 does not overflow
+	abs := uint64(amount)
+	sign := ""
+	if amount < 0 {
+		abs = -abs
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if c.Exponent == 0 {
+		return sign + digits
+	}
+
+	// Pad with zeros to have at least one digit before the decimal point
+	if len(digits) <= c.Exponent {
+		digits = strings.Repeat("0", c.Exponent-len(digits)+1) + digits
+	}
+	point := len(digits) - c.Exponent
+	return sign + digits[:point] + "." + digits[point:]
+}
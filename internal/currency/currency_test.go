@@ -0,0 +1,59 @@
+package currency
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code             string
+		expectedFound    bool
+		expectedExponent int
+	}{
+		{code: "RUB", expectedFound: true, expectedExponent: 2},
+		{code: "usd", expectedFound: true, expectedExponent: 2},
+		{code: "JPY", expectedFound: true, expectedExponent: 0},
+		{code: "KWD", expectedFound: true, expectedExponent: 3},
+		{code: "CLF", expectedFound: true, expectedExponent: 4},
+		{code: "XXX", expectedFound: false},
+		{code: "", expectedFound: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.code, func(t *testing.T) {
+			c, ok := Lookup(tc.code)
+			assert.Equal(t, tc.expectedFound, ok)
+			assert.Equal(t, tc.expectedExponent, c.Exponent)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		code     string
+		amount   int64
+		expected string
+	}{
+		{code: "USD", amount: 12345, expected: "123.45"},
+		{code: "USD", amount: 5, expected: "0.05"},
+		{code: "USD", amount: 0, expected: "0.00"},
+		{code: "USD", amount: -150, expected: "-1.50"},
+		{code: "JPY", amount: 12345, expected: "12345"},
+		{code: "KWD", amount: 1234, expected: "1.234"},
+		{code: "CLF", amount: 1, expected: "0.0001"},
+		{code: "RUB", amount: math.MinInt64, expected: "-92233720368547758.08"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			c, ok := Lookup(tc.code)
+			if !ok {
+				t.Fatalf("Unknown currency %s", tc.code)
+			}
+			assert.Equal(t, tc.expected, c.Format(tc.amount))
+		})
+	}
+}
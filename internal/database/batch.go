@@ -0,0 +1,83 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: batch.go
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrBatchAlreadyClosed = errors.New("batch already closed")
+)
+
+const recordOperation = `-- name: RecordOperation :batchexec
+WITH operation AS (
+	INSERT INTO operations (id, wallet_id, operation_type, amount)
+	VALUES ($4, $5, $6, $2)
+	RETURNING id
+)
+INSERT INTO ledger_entries (journal_id, account_id, operation_id, direction, amount)
+VALUES
+	((SELECT id FROM operation), $1, (SELECT id FROM operation), 'debit', $2),
+	((SELECT id FROM operation), $3, (SELECT id FROM operation), 'credit', $2)
+`
+
+type RecordOperationBatchResults struct {
+	br     pgx.BatchResults
+	tot    int
+	closed bool
+}
+
+type RecordOperationParams struct {
+	DebitAccountID  pgtype.UUID `json:"debit_account_id"`
+	Amount          int64       `json:"amount"`
+	CreditAccountID pgtype.UUID `json:"credit_account_id"`
+	ID              pgtype.UUID `json:"id"`
+	WalletID        pgtype.UUID `json:"wallet_id"`
+	OperationType   string      `json:"operation_type"`
+}
+
+func (q *Queries) RecordOperation(ctx context.Context, arg []RecordOperationParams) *RecordOperationBatchResults {
+	batch := &pgx.Batch{}
+	for _, a := range arg {
+		vals := []interface{}{
+			a.DebitAccountID,
+			a.Amount,
+			a.CreditAccountID,
+			a.ID,
+			a.WalletID,
+			a.OperationType,
+		}
+		batch.Queue(recordOperation, vals...)
+	}
+	br := q.db.SendBatch(ctx, batch)
+	return &RecordOperationBatchResults{br, len(arg), false}
+}
+
+func (b *RecordOperationBatchResults) Exec(f func(int, error)) {
+	defer b.br.Close()
+	for t := 0; t < b.tot; t++ {
+		if b.closed {
+			if f != nil {
+				f(t, ErrBatchAlreadyClosed)
+			}
+			continue
+		}
+		_, err := b.br.Exec()
+		if f != nil {
+			f(t, err)
+		}
+	}
+}
+
+func (b *RecordOperationBatchResults) Close() error {
+	b.closed = true
+	return b.br.Close()
+}
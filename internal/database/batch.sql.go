@@ -0,0 +1,74 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: batch.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getWithdrawalUsages = `-- name: GetWithdrawalUsages :many
+SELECT
+	w.id::uuid AS wallet_id,
+	(l.wallet_id IS NOT NULL)::bool AS custom_limits,
+	l.daily_withdrawal_limit,
+	l.monthly_withdrawal_limit,
+	COALESCE(t.daily, 0)::bigint AS daily_withdrawn,
+	COALESCE(t.monthly, 0)::bigint AS monthly_withdrawn
+FROM unnest($1::uuid[]) AS w(id)
+LEFT JOIN wallet_limits l ON l.wallet_id = w.id
+LEFT JOIN LATERAL (
+	SELECT
+		SUM(o.amount - COALESCE(r.refunded, 0)) FILTER (WHERE o.created_at >= date_trunc('day', NOW())) AS daily,
+		SUM(o.amount - COALESCE(r.refunded, 0)) AS monthly
+	FROM operations o
+	LEFT JOIN LATERAL (
+		SELECT SUM(amount) AS refunded FROM operations WHERE reversal_of = o.id
+	) r ON TRUE
+	WHERE o.wallet_id = w.id
+		AND o.operation_type = 'withdraw'
+		AND o.reversal_of IS NULL
+		AND o.created_at >= date_trunc('month', NOW())
+) t ON TRUE
+`
+
+type GetWithdrawalUsagesRow struct {
+	WalletID               pgtype.UUID `json:"wallet_id"`
+	CustomLimits           bool        `json:"custom_limits"`
+	DailyWithdrawalLimit   pgtype.Int8 `json:"daily_withdrawal_limit"`
+	MonthlyWithdrawalLimit pgtype.Int8 `json:"monthly_withdrawal_limit"`
+	DailyWithdrawn         int64       `json:"daily_withdrawn"`
+	MonthlyWithdrawn       int64       `json:"monthly_withdrawn"`
+}
+
+// Reversed parts of the withdrawals are refunded and do not count towards the limits
+func (q *Queries) GetWithdrawalUsages(ctx context.Context, ids []pgtype.UUID) ([]GetWithdrawalUsagesRow, error) {
+	rows, err := q.db.Query(ctx, getWithdrawalUsages, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWithdrawalUsagesRow
+	for rows.Next() {
+		var i GetWithdrawalUsagesRow
+		if err := rows.Scan(
+			&i.WalletID,
+			&i.CustomLimits,
+			&i.DailyWithdrawalLimit,
+			&i.MonthlyWithdrawalLimit,
+			&i.DailyWithdrawn,
+			&i.MonthlyWithdrawn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
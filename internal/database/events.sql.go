@@ -0,0 +1,51 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: events.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getWalletEventsAfter = `-- name: GetWalletEventsAfter :many
+SELECT seq, event_type, payload FROM outbox_events
+WHERE wallet_id = $1 AND seq > $2
+ORDER BY seq
+LIMIT $3
+`
+
+type GetWalletEventsAfterParams struct {
+	WalletID  pgtype.UUID `json:"wallet_id"`
+	AfterSeq  int64       `json:"after_seq"`
+	MaxEvents int32       `json:"max_events"`
+}
+
+type GetWalletEventsAfterRow struct {
+	Seq       int64  `json:"seq"`
+	EventType string `json:"event_type"`
+	Payload   []byte `json:"payload"`
+}
+
+func (q *Queries) GetWalletEventsAfter(ctx context.Context, arg GetWalletEventsAfterParams) ([]GetWalletEventsAfterRow, error) {
+	rows, err := q.db.Query(ctx, getWalletEventsAfter, arg.WalletID, arg.AfterSeq, arg.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWalletEventsAfterRow
+	for rows.Next() {
+		var i GetWalletEventsAfterRow
+		if err := rows.Scan(&i.Seq, &i.EventType, &i.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,191 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: holds.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createHold = `-- name: CreateHold :one
+INSERT INTO holds (id, wallet_id, amount, expires_at)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	NOW() + $3::interval
+)
+RETURNING id, wallet_id, amount, captured_amount, status, expires_at, created_at, updated_at
+`
+
+type CreateHoldParams struct {
+	WalletID pgtype.UUID     `json:"wallet_id"`
+	Amount   int64           `json:"amount"`
+	Ttl      pgtype.Interval `json:"ttl"`
+}
+
+func (q *Queries) CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error) {
+	row := q.db.QueryRow(ctx, createHold, arg.WalletID, arg.Amount, arg.Ttl)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.Amount,
+		&i.CapturedAmount,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const expireHolds = `-- name: ExpireHolds :many
+UPDATE holds SET status = 'expired', updated_at = NOW()
+WHERE id IN (
+	SELECT id FROM holds
+	WHERE status = 'active' AND expires_at <= NOW()
+	ORDER BY expires_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING wallet_id, amount
+`
+
+type ExpireHoldsRow struct {
+	WalletID pgtype.UUID `json:"wallet_id"`
+	Amount   int64       `json:"amount"`
+}
+
+func (q *Queries) ExpireHolds(ctx context.Context, batchSize int32) ([]ExpireHoldsRow, error) {
+	rows, err := q.db.Query(ctx, expireHolds, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpireHoldsRow
+	for rows.Next() {
+		var i ExpireHoldsRow
+		if err := rows.Scan(&i.WalletID, &i.Amount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getHold = `-- name: GetHold :one
+SELECT id, wallet_id, amount, captured_amount, status, expires_at, created_at, updated_at FROM holds
+WHERE id = $1 AND wallet_id = $2 LIMIT 1
+`
+
+type GetHoldParams struct {
+	ID       pgtype.UUID `json:"id"`
+	WalletID pgtype.UUID `json:"wallet_id"`
+}
+
+func (q *Queries) GetHold(ctx context.Context, arg GetHoldParams) (Hold, error) {
+	row := q.db.QueryRow(ctx, getHold, arg.ID, arg.WalletID)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.Amount,
+		&i.CapturedAmount,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getHoldForUpdate = `-- name: GetHoldForUpdate :one
+SELECT id, wallet_id, amount, captured_amount, status, expires_at, created_at, updated_at, expires_at <= NOW() AS expired FROM holds
+WHERE id = $1 AND wallet_id = $2 LIMIT 1
+FOR UPDATE
+`
+
+type GetHoldForUpdateParams struct {
+	ID       pgtype.UUID `json:"id"`
+	WalletID pgtype.UUID `json:"wallet_id"`
+}
+
+type GetHoldForUpdateRow struct {
+	ID             pgtype.UUID      `json:"id"`
+	WalletID       pgtype.UUID      `json:"wallet_id"`
+	Amount         int64            `json:"amount"`
+	CapturedAmount int64            `json:"captured_amount"`
+	Status         string           `json:"status"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	Expired        bool             `json:"expired"`
+}
+
+func (q *Queries) GetHoldForUpdate(ctx context.Context, arg GetHoldForUpdateParams) (GetHoldForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getHoldForUpdate, arg.ID, arg.WalletID)
+	var i GetHoldForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.Amount,
+		&i.CapturedAmount,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Expired,
+	)
+	return i, err
+}
+
+const updateHold = `-- name: UpdateHold :one
+UPDATE holds SET status = $1, captured_amount = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING id, wallet_id, amount, captured_amount, status, expires_at, created_at, updated_at
+`
+
+type UpdateHoldParams struct {
+	Status         string      `json:"status"`
+	CapturedAmount int64       `json:"captured_amount"`
+	ID             pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateHold(ctx context.Context, arg UpdateHoldParams) (Hold, error) {
+	row := q.db.QueryRow(ctx, updateHold, arg.Status, arg.CapturedAmount, arg.ID)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.Amount,
+		&i.CapturedAmount,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateWalletHeld = `-- name: UpdateWalletHeld :exec
+UPDATE wallets SET held = held + $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateWalletHeldParams struct {
+	Delta int64       `json:"delta"`
+	ID    pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateWalletHeld(ctx context.Context, arg UpdateWalletHeldParams) error {
+	_, err := q.db.Exec(ctx, updateWalletHeld, arg.Delta, arg.ID)
+	return err
+}
@@ -0,0 +1,131 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: idempotency_keys.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createIdempotencyKey = `-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (key, fingerprint, expires_at, locked_until)
+VALUES (
+	$1,
+	$2,
+	NOW() + $3::interval,
+	NOW() + $4::interval
+)
+ON CONFLICT (key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+	status_code = NULL,
+	content_type = NULL,
+	response_body = NULL,
+	created_at = NOW(),
+	expires_at = EXCLUDED.expires_at,
+	locked_until = EXCLUDED.locked_until
+WHERE idempotency_keys.expires_at <= NOW()
+	OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW())
+RETURNING locked_until
+`
+
+type CreateIdempotencyKeyParams struct {
+	Key         string          `json:"key"`
+	Fingerprint string          `json:"fingerprint"`
+	Ttl         pgtype.Interval `json:"ttl"`
+	Lease       pgtype.Interval `json:"lease"`
+}
+
+// Claims the key until the lease ends. Nothing is returned if the key is taken: it has not expired yet
+// and either holds a response or is locked by a request that is still running.
+func (q *Queries) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (pgtype.Timestamp, error) {
+	row := q.db.QueryRow(ctx, createIdempotencyKey,
+		arg.Key,
+		arg.Fingerprint,
+		arg.Ttl,
+		arg.Lease,
+	)
+	var locked_until pgtype.Timestamp
+	err := row.Scan(&locked_until)
+	return locked_until, err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredIdempotencyKeys)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1 AND locked_until = $2
+`
+
+type DeleteIdempotencyKeyParams struct {
+	Key         string           `json:"key"`
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+}
+
+// Releases the key, unless another request has taken it over
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, deleteIdempotencyKey, arg.Key, arg.LockedUntil)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT key, fingerprint, status_code, content_type, response_body, created_at, expires_at, locked_until FROM idempotency_keys
+WHERE key = $1 LIMIT 1
+`
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.Fingerprint,
+		&i.StatusCode,
+		&i.ContentType,
+		&i.ResponseBody,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const saveIdempotencyKeyResponse = `-- name: SaveIdempotencyKeyResponse :execrows
+UPDATE idempotency_keys
+SET status_code = $1, content_type = $2, response_body = $3, locked_until = NULL
+WHERE key = $4 AND locked_until = $5
+`
+
+type SaveIdempotencyKeyResponseParams struct {
+	StatusCode   pgtype.Int4      `json:"status_code"`
+	ContentType  pgtype.Text      `json:"content_type"`
+	ResponseBody []byte           `json:"response_body"`
+	Key          string           `json:"key"`
+	LockedUntil  pgtype.Timestamp `json:"locked_until"`
+}
+
+// Stores the response and unlocks the key, unless another request has taken it over
+func (q *Queries) SaveIdempotencyKeyResponse(ctx context.Context, arg SaveIdempotencyKeyResponseParams) (int64, error) {
+	result, err := q.db.Exec(ctx, saveIdempotencyKeyResponse,
+		arg.StatusCode,
+		arg.ContentType,
+		arg.ResponseBody,
+		arg.Key,
+		arg.LockedUntil,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
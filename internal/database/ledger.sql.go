@@ -0,0 +1,78 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: ledger.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createSystemAccount = `-- name: CreateSystemAccount :exec
+INSERT INTO accounts (id, account_type, name, currency)
+VALUES (
+	gen_random_uuid(),
+	'system',
+	$1,
+	$2
+)
+ON CONFLICT (name, currency) WHERE account_type = 'system' DO NOTHING
+`
+
+type CreateSystemAccountParams struct {
+	Name     pgtype.Text `json:"name"`
+	Currency string      `json:"currency"`
+}
+
+func (q *Queries) CreateSystemAccount(ctx context.Context, arg CreateSystemAccountParams) error {
+	_, err := q.db.Exec(ctx, createSystemAccount, arg.Name, arg.Currency)
+	return err
+}
+
+const getSystemAccount = `-- name: GetSystemAccount :one
+SELECT id FROM accounts
+WHERE account_type = 'system' AND name = $1 AND currency = $2 LIMIT 1
+`
+
+type GetSystemAccountParams struct {
+	Name     pgtype.Text `json:"name"`
+	Currency string      `json:"currency"`
+}
+
+func (q *Queries) GetSystemAccount(ctx context.Context, arg GetSystemAccountParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, getSystemAccount, arg.Name, arg.Currency)
+	var id pgtype.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const postJournal = `-- name: PostJournal :exec
+INSERT INTO ledger_entries (journal_id, account_id, operation_id, direction, amount)
+VALUES
+	($1, $2, $3, 'debit', $4),
+	($1, $5, $6, 'credit', $4)
+`
+
+type PostJournalParams struct {
+	JournalID         pgtype.UUID `json:"journal_id"`
+	DebitAccountID    pgtype.UUID `json:"debit_account_id"`
+	DebitOperationID  pgtype.UUID `json:"debit_operation_id"`
+	Amount            int64       `json:"amount"`
+	CreditAccountID   pgtype.UUID `json:"credit_account_id"`
+	CreditOperationID pgtype.UUID `json:"credit_operation_id"`
+}
+
+func (q *Queries) PostJournal(ctx context.Context, arg PostJournalParams) error {
+	_, err := q.db.Exec(ctx, postJournal,
+		arg.JournalID,
+		arg.DebitAccountID,
+		arg.DebitOperationID,
+		arg.Amount,
+		arg.CreditAccountID,
+		arg.CreditOperationID,
+	)
+	return err
+}
@@ -0,0 +1,97 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: limits.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteWalletLimits = `-- name: DeleteWalletLimits :exec
+DELETE FROM wallet_limits WHERE wallet_id = $1
+`
+
+func (q *Queries) DeleteWalletLimits(ctx context.Context, walletID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteWalletLimits, walletID)
+	return err
+}
+
+const getWalletLimits = `-- name: GetWalletLimits :one
+SELECT wallet_id, daily_withdrawal_limit, monthly_withdrawal_limit, updated_at FROM wallet_limits
+WHERE wallet_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetWalletLimits(ctx context.Context, walletID pgtype.UUID) (WalletLimit, error) {
+	row := q.db.QueryRow(ctx, getWalletLimits, walletID)
+	var i WalletLimit
+	err := row.Scan(
+		&i.WalletID,
+		&i.DailyWithdrawalLimit,
+		&i.MonthlyWithdrawalLimit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWithdrawalTotals = `-- name: GetWithdrawalTotals :one
+SELECT
+	COALESCE(SUM(o.amount - COALESCE(r.refunded, 0)) FILTER (WHERE o.created_at >= date_trunc('day', NOW())), 0)::bigint AS daily,
+	COALESCE(SUM(o.amount - COALESCE(r.refunded, 0)), 0)::bigint AS monthly
+FROM operations o
+LEFT JOIN LATERAL (
+	SELECT SUM(amount) AS refunded FROM operations WHERE reversal_of = o.id
+) r ON TRUE
+WHERE o.wallet_id = $1
+	AND o.operation_type = 'withdraw'
+	AND o.reversal_of IS NULL
+	AND o.created_at >= date_trunc('month', NOW())
+`
+
+type GetWithdrawalTotalsRow struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// Reversed parts of the withdrawals are refunded and do not count towards the limits
+func (q *Queries) GetWithdrawalTotals(ctx context.Context, walletID pgtype.UUID) (GetWithdrawalTotalsRow, error) {
+	row := q.db.QueryRow(ctx, getWithdrawalTotals, walletID)
+	var i GetWithdrawalTotalsRow
+	err := row.Scan(&i.Daily, &i.Monthly)
+	return i, err
+}
+
+const setWalletLimits = `-- name: SetWalletLimits :one
+INSERT INTO wallet_limits (wallet_id, daily_withdrawal_limit, monthly_withdrawal_limit)
+VALUES (
+	$1,
+	$2,
+	$3
+)
+ON CONFLICT (wallet_id) DO UPDATE
+SET daily_withdrawal_limit = EXCLUDED.daily_withdrawal_limit,
+	monthly_withdrawal_limit = EXCLUDED.monthly_withdrawal_limit,
+	updated_at = NOW()
+RETURNING wallet_id, daily_withdrawal_limit, monthly_withdrawal_limit, updated_at
+`
+
+type SetWalletLimitsParams struct {
+	WalletID               pgtype.UUID `json:"wallet_id"`
+	DailyWithdrawalLimit   pgtype.Int8 `json:"daily_withdrawal_limit"`
+	MonthlyWithdrawalLimit pgtype.Int8 `json:"monthly_withdrawal_limit"`
+}
+
+func (q *Queries) SetWalletLimits(ctx context.Context, arg SetWalletLimitsParams) (WalletLimit, error) {
+	row := q.db.QueryRow(ctx, setWalletLimits, arg.WalletID, arg.DailyWithdrawalLimit, arg.MonthlyWithdrawalLimit)
+	var i WalletLimit
+	err := row.Scan(
+		&i.WalletID,
+		&i.DailyWithdrawalLimit,
+		&i.MonthlyWithdrawalLimit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
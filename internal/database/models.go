@@ -8,17 +8,139 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Account struct {
+	ID          pgtype.UUID      `json:"id"`
+	AccountType string           `json:"account_type"`
+	Name        pgtype.Text      `json:"name"`
+	Currency    string           `json:"currency"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
+
+type AccountBalance struct {
+	AccountID   pgtype.UUID `json:"account_id"`
+	AccountType string      `json:"account_type"`
+	Name        pgtype.Text `json:"name"`
+	Currency    string      `json:"currency"`
+	Balance     int64       `json:"balance"`
+}
+
+type BalanceSnapshot struct {
+	WalletID pgtype.UUID      `json:"wallet_id"`
+	TakenAt  pgtype.Timestamp `json:"taken_at"`
+	Balance  int64            `json:"balance"`
+}
+
+type Hold struct {
+	ID             pgtype.UUID      `json:"id"`
+	WalletID       pgtype.UUID      `json:"wallet_id"`
+	Amount         int64            `json:"amount"`
+	CapturedAmount int64            `json:"captured_amount"`
+	Status         string           `json:"status"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+}
+
+type IdempotencyKey struct {
+	Key          string           `json:"key"`
+	Fingerprint  string           `json:"fingerprint"`
+	StatusCode   pgtype.Int4      `json:"status_code"`
+	ContentType  pgtype.Text      `json:"content_type"`
+	ResponseBody []byte           `json:"response_body"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	ExpiresAt    pgtype.Timestamp `json:"expires_at"`
+	LockedUntil  pgtype.Timestamp `json:"locked_until"`
+}
+
+type LedgerEntry struct {
+	ID          int64            `json:"id"`
+	JournalID   pgtype.UUID      `json:"journal_id"`
+	AccountID   pgtype.UUID      `json:"account_id"`
+	OperationID pgtype.UUID      `json:"operation_id"`
+	Direction   string           `json:"direction"`
+	Amount      int64            `json:"amount"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
+
 type Operation struct {
 	ID            pgtype.UUID      `json:"id"`
 	WalletID      pgtype.UUID      `json:"wallet_id"`
 	OperationType string           `json:"operation_type"`
-	Amount        int32            `json:"amount"`
+	Amount        int64            `json:"amount"`
 	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	TransferID    pgtype.UUID      `json:"transfer_id"`
+	HoldID        pgtype.UUID      `json:"hold_id"`
+	ReversalOf    pgtype.UUID      `json:"reversal_of"`
+}
+
+type OutboxEvent struct {
+	ID           pgtype.UUID      `json:"id"`
+	EventType    string           `json:"event_type"`
+	WalletID     pgtype.UUID      `json:"wallet_id"`
+	Payload      []byte           `json:"payload"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	DispatchedAt pgtype.Timestamp `json:"dispatched_at"`
+	Seq          int64            `json:"seq"`
+}
+
+type ReconciliationMismatch struct {
+	RunID               pgtype.UUID `json:"run_id"`
+	WalletID            pgtype.UUID `json:"wallet_id"`
+	StoredBalance       int64       `json:"stored_balance"`
+	OperationsBalance   int64       `json:"operations_balance"`
+	LedgerBalance       int64       `json:"ledger_balance"`
+	Corrected           bool        `json:"corrected"`
+	CorrectionJournalID pgtype.UUID `json:"correction_journal_id"`
+}
+
+type ReconciliationRun struct {
+	ID                  pgtype.UUID      `json:"id"`
+	Fix                 bool             `json:"fix"`
+	Status              string           `json:"status"`
+	WalletsChecked      int64            `json:"wallets_checked"`
+	MismatchesFound     int32            `json:"mismatches_found"`
+	MismatchesCorrected int32            `json:"mismatches_corrected"`
+	Error               pgtype.Text      `json:"error"`
+	StartedAt           pgtype.Timestamp `json:"started_at"`
+	FinishedAt          pgtype.Timestamp `json:"finished_at"`
 }
 
 type Wallet struct {
+	ID          pgtype.UUID      `json:"id"`
+	Balance     int64            `json:"balance"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
+	Currency    string           `json:"currency"`
+	Held        int64            `json:"held"`
+	Status      string           `json:"status"`
+	CreditLimit int64            `json:"credit_limit"`
+}
+
+type WalletLimit struct {
+	WalletID               pgtype.UUID      `json:"wallet_id"`
+	DailyWithdrawalLimit   pgtype.Int8      `json:"daily_withdrawal_limit"`
+	MonthlyWithdrawalLimit pgtype.Int8      `json:"monthly_withdrawal_limit"`
+	UpdatedAt              pgtype.Timestamp `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             pgtype.UUID      `json:"id"`
+	EventID        pgtype.UUID      `json:"event_id"`
+	SubscriptionID pgtype.UUID      `json:"subscription_id"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamp `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4      `json:"last_status_code"`
+	LastError      pgtype.Text      `json:"last_error"`
+	DeliveredAt    pgtype.Timestamp `json:"delivered_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type WebhookSubscription struct {
 	ID        pgtype.UUID      `json:"id"`
-	Balance   int32            `json:"balance"`
+	Url       string           `json:"url"`
+	Secret    string           `json:"secret"`
+	WalletID  pgtype.UUID      `json:"wallet_id"`
+	Active    bool             `json:"active"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
-	UpdatedAt pgtype.Timestamp `json:"updated_at"`
 }
@@ -0,0 +1,307 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: reconciliation.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const addReconciliationMismatch = `-- name: AddReconciliationMismatch :exec
+INSERT INTO reconciliation_mismatches (run_id, wallet_id, stored_balance, operations_balance, ledger_balance, corrected, correction_journal_id)
+VALUES (
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+)
+`
+
+type AddReconciliationMismatchParams struct {
+	RunID               pgtype.UUID `json:"run_id"`
+	WalletID            pgtype.UUID `json:"wallet_id"`
+	StoredBalance       int64       `json:"stored_balance"`
+	OperationsBalance   int64       `json:"operations_balance"`
+	LedgerBalance       int64       `json:"ledger_balance"`
+	Corrected           bool        `json:"corrected"`
+	CorrectionJournalID pgtype.UUID `json:"correction_journal_id"`
+}
+
+func (q *Queries) AddReconciliationMismatch(ctx context.Context, arg AddReconciliationMismatchParams) error {
+	_, err := q.db.Exec(ctx, addReconciliationMismatch,
+		arg.RunID,
+		arg.WalletID,
+		arg.StoredBalance,
+		arg.OperationsBalance,
+		arg.LedgerBalance,
+		arg.Corrected,
+		arg.CorrectionJournalID,
+	)
+	return err
+}
+
+const countWallets = `-- name: CountWallets :one
+SELECT COUNT(*) FROM wallets
+`
+
+func (q *Queries) CountWallets(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countWallets)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createReconciliationRun = `-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs (id, fix)
+VALUES (
+	gen_random_uuid(),
+	$1
+)
+RETURNING id, fix, status, wallets_checked, mismatches_found, mismatches_corrected, error, started_at, finished_at
+`
+
+func (q *Queries) CreateReconciliationRun(ctx context.Context, fix bool) (ReconciliationRun, error) {
+	row := q.db.QueryRow(ctx, createReconciliationRun, fix)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.Fix,
+		&i.Status,
+		&i.WalletsChecked,
+		&i.MismatchesFound,
+		&i.MismatchesCorrected,
+		&i.Error,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const finishReconciliationRun = `-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET status = $1, wallets_checked = $2, mismatches_found = $3,
+	mismatches_corrected = $4, error = $5, finished_at = NOW()
+WHERE id = $6
+RETURNING id, fix, status, wallets_checked, mismatches_found, mismatches_corrected, error, started_at, finished_at
+`
+
+type FinishReconciliationRunParams struct {
+	Status              string      `json:"status"`
+	WalletsChecked      int64       `json:"wallets_checked"`
+	MismatchesFound     int32       `json:"mismatches_found"`
+	MismatchesCorrected int32       `json:"mismatches_corrected"`
+	Error               pgtype.Text `json:"error"`
+	ID                  pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) FinishReconciliationRun(ctx context.Context, arg FinishReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRow(ctx, finishReconciliationRun,
+		arg.Status,
+		arg.WalletsChecked,
+		arg.MismatchesFound,
+		arg.MismatchesCorrected,
+		arg.Error,
+		arg.ID,
+	)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.Fix,
+		&i.Status,
+		&i.WalletsChecked,
+		&i.MismatchesFound,
+		&i.MismatchesCorrected,
+		&i.Error,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getBalanceMismatches = `-- name: GetBalanceMismatches :many
+SELECT
+	w.id AS wallet_id,
+	w.balance AS stored_balance,
+	COALESCE(o.balance, 0)::bigint AS operations_balance,
+	COALESCE(l.balance, 0)::bigint AS ledger_balance
+FROM wallets w
+LEFT JOIN (
+	SELECT wallet_id, SUM(CASE WHEN operation_type = 'deposit' THEN amount ELSE -amount END) AS balance
+	FROM operations GROUP BY wallet_id
+) o ON o.wallet_id = w.id
+LEFT JOIN (
+	SELECT account_id, SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END) AS balance
+	FROM ledger_entries GROUP BY account_id
+) l ON l.account_id = w.id
+WHERE w.balance <> COALESCE(o.balance, 0) OR w.balance <> COALESCE(l.balance, 0)
+ORDER BY w.id
+`
+
+type GetBalanceMismatchesRow struct {
+	WalletID          pgtype.UUID `json:"wallet_id"`
+	StoredBalance     int64       `json:"stored_balance"`
+	OperationsBalance int64       `json:"operations_balance"`
+	LedgerBalance     int64       `json:"ledger_balance"`
+}
+
+func (q *Queries) GetBalanceMismatches(ctx context.Context) ([]GetBalanceMismatchesRow, error) {
+	rows, err := q.db.Query(ctx, getBalanceMismatches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBalanceMismatchesRow
+	for rows.Next() {
+		var i GetBalanceMismatchesRow
+		if err := rows.Scan(
+			&i.WalletID,
+			&i.StoredBalance,
+			&i.OperationsBalance,
+			&i.LedgerBalance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReconciliationMismatches = `-- name: GetReconciliationMismatches :many
+SELECT run_id, wallet_id, stored_balance, operations_balance, ledger_balance, corrected, correction_journal_id FROM reconciliation_mismatches
+WHERE run_id = $1
+ORDER BY wallet_id
+`
+
+func (q *Queries) GetReconciliationMismatches(ctx context.Context, runID pgtype.UUID) ([]ReconciliationMismatch, error) {
+	rows, err := q.db.Query(ctx, getReconciliationMismatches, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReconciliationMismatch
+	for rows.Next() {
+		var i ReconciliationMismatch
+		if err := rows.Scan(
+			&i.RunID,
+			&i.WalletID,
+			&i.StoredBalance,
+			&i.OperationsBalance,
+			&i.LedgerBalance,
+			&i.Corrected,
+			&i.CorrectionJournalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReconciliationRun = `-- name: GetReconciliationRun :one
+SELECT id, fix, status, wallets_checked, mismatches_found, mismatches_corrected, error, started_at, finished_at FROM reconciliation_runs
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetReconciliationRun(ctx context.Context, id pgtype.UUID) (ReconciliationRun, error) {
+	row := q.db.QueryRow(ctx, getReconciliationRun, id)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.Fix,
+		&i.Status,
+		&i.WalletsChecked,
+		&i.MismatchesFound,
+		&i.MismatchesCorrected,
+		&i.Error,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getReconciliationRuns = `-- name: GetReconciliationRuns :many
+SELECT id, fix, status, wallets_checked, mismatches_found, mismatches_corrected, error, started_at, finished_at FROM reconciliation_runs
+ORDER BY started_at DESC
+LIMIT $1
+`
+
+func (q *Queries) GetReconciliationRuns(ctx context.Context, limit int32) ([]ReconciliationRun, error) {
+	rows, err := q.db.Query(ctx, getReconciliationRuns, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReconciliationRun
+	for rows.Next() {
+		var i ReconciliationRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.Fix,
+			&i.Status,
+			&i.WalletsChecked,
+			&i.MismatchesFound,
+			&i.MismatchesCorrected,
+			&i.Error,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWalletReconciliation = `-- name: GetWalletReconciliation :one
+SELECT
+	w.balance AS stored_balance,
+	(SELECT COALESCE(SUM(CASE WHEN operation_type = 'deposit' THEN amount ELSE -amount END), 0)
+		FROM operations WHERE wallet_id = w.id)::bigint AS operations_balance,
+	(SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+		FROM ledger_entries WHERE account_id = w.id)::bigint AS ledger_balance
+FROM wallets w
+WHERE w.id = $1
+`
+
+type GetWalletReconciliationRow struct {
+	StoredBalance     int64 `json:"stored_balance"`
+	OperationsBalance int64 `json:"operations_balance"`
+	LedgerBalance     int64 `json:"ledger_balance"`
+}
+
+func (q *Queries) GetWalletReconciliation(ctx context.Context, id pgtype.UUID) (GetWalletReconciliationRow, error) {
+	row := q.db.QueryRow(ctx, getWalletReconciliation, id)
+	var i GetWalletReconciliationRow
+	err := row.Scan(&i.StoredBalance, &i.OperationsBalance, &i.LedgerBalance)
+	return i, err
+}
+
+const syncWalletBalance = `-- name: SyncWalletBalance :exec
+UPDATE wallets
+SET balance = (
+	SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+	FROM ledger_entries WHERE account_id = wallets.id
+), updated_at = NOW()
+WHERE wallets.id = $1
+`
+
+func (q *Queries) SyncWalletBalance(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, syncWalletBalance, id)
+	return err
+}
@@ -0,0 +1,83 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: snapshots.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createBalanceSnapshots = `-- name: CreateBalanceSnapshots :execrows
+INSERT INTO balance_snapshots (wallet_id, taken_at, balance)
+SELECT
+	o.wallet_id,
+	$1::timestamp,
+	(COALESCE(s.balance, 0) + SUM(CASE WHEN o.operation_type = 'deposit' THEN o.amount ELSE -o.amount END))::bigint
+FROM operations o
+LEFT JOIN LATERAL (
+	SELECT taken_at, balance FROM balance_snapshots
+	WHERE wallet_id = o.wallet_id
+	ORDER BY taken_at DESC
+	LIMIT 1
+) s ON TRUE
+WHERE o.created_at <= $1::timestamp
+	AND o.created_at > COALESCE(s.taken_at, '-infinity'::timestamp)
+GROUP BY o.wallet_id, s.balance
+ON CONFLICT (wallet_id, taken_at) DO NOTHING
+`
+
+func (q *Queries) CreateBalanceSnapshots(ctx context.Context, takenAt pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, createBalanceSnapshots, takenAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getBalanceAsOf = `-- name: GetBalanceAsOf :one
+WITH snapshot AS (
+	SELECT taken_at, balance FROM balance_snapshots
+	WHERE wallet_id = $1 AND taken_at <= $2
+	ORDER BY taken_at DESC
+	LIMIT 1
+)
+SELECT (
+	COALESCE((SELECT balance FROM snapshot), 0)
+	+ COALESCE((
+		SELECT SUM(CASE WHEN o.operation_type = 'deposit' THEN o.amount ELSE -o.amount END)
+		FROM operations o
+		WHERE o.wallet_id = $1
+			AND o.created_at > COALESCE((SELECT taken_at FROM snapshot), '-infinity'::timestamp)
+			AND o.created_at <= $2
+	), 0)
+)::bigint AS balance
+`
+
+type GetBalanceAsOfParams struct {
+	WalletID pgtype.UUID      `json:"wallet_id"`
+	AsOf     pgtype.Timestamp `json:"as_of"`
+}
+
+func (q *Queries) GetBalanceAsOf(ctx context.Context, arg GetBalanceAsOfParams) (int64, error) {
+	row := q.db.QueryRow(ctx, getBalanceAsOf, arg.WalletID, arg.AsOf)
+	var balance int64
+	err := row.Scan(&balance)
+	return balance, err
+}
+
+const getBalanceSnapshotTime = `-- name: GetBalanceSnapshotTime :one
+SELECT date_trunc('second', NOW() - $1::interval)::timestamp AS taken_at
+`
+
+// Returns the time of a snapshot taken @lag in the past, by the clock and in the time zone
+// of the database that timestamps the operations
+func (q *Queries) GetBalanceSnapshotTime(ctx context.Context, lag pgtype.Interval) (pgtype.Timestamp, error) {
+	row := q.db.QueryRow(ctx, getBalanceSnapshotTime, lag)
+	var taken_at pgtype.Timestamp
+	err := row.Scan(&taken_at)
+	return taken_at, err
+}
@@ -11,49 +11,74 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const addOperation = `-- name: AddOperation :exec
-INSERT INTO operations (id, wallet_id, operation_type, amount)
+const addOperation = `-- name: AddOperation :one
+INSERT INTO operations (id, wallet_id, operation_type, amount, transfer_id, hold_id, reversal_of)
 VALUES (
 	gen_random_uuid(),
 	$1,
 	$2,
-	$3
+	$3,
+	$4,
+	$5,
+	$6
 )
+RETURNING id
 `
 
 type AddOperationParams struct {
 	WalletID      pgtype.UUID `json:"wallet_id"`
 	OperationType string      `json:"operation_type"`
-	Amount        int32       `json:"amount"`
+	Amount        int64       `json:"amount"`
+	TransferID    pgtype.UUID `json:"transfer_id"`
+	HoldID        pgtype.UUID `json:"hold_id"`
+	ReversalOf    pgtype.UUID `json:"reversal_of"`
 }
 
-func (q *Queries) AddOperation(ctx context.Context, arg AddOperationParams) error {
-	_, err := q.db.Exec(ctx, addOperation, arg.WalletID, arg.OperationType, arg.Amount)
-	return err
+func (q *Queries) AddOperation(ctx context.Context, arg AddOperationParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, addOperation,
+		arg.WalletID,
+		arg.OperationType,
+		arg.Amount,
+		arg.TransferID,
+		arg.HoldID,
+		arg.ReversalOf,
+	)
+	var id pgtype.UUID
+	err := row.Scan(&id)
+	return id, err
 }
 
 const createWallet = `-- name: CreateWallet :one
-INSERT INTO wallets (id)
-VALUES (
-	gen_random_uuid()
+WITH wallet AS (
+	INSERT INTO wallets (id, currency)
+	VALUES (
+		gen_random_uuid(),
+		$1
+	)
+	RETURNING id, currency
 )
+INSERT INTO accounts (id, account_type, currency)
+SELECT id, 'wallet', currency FROM wallet
 RETURNING id
 `
 
-func (q *Queries) CreateWallet(ctx context.Context) (pgtype.UUID, error) {
-	row := q.db.QueryRow(ctx, createWallet)
+func (q *Queries) CreateWallet(ctx context.Context, currency string) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, createWallet, currency)
 	var id pgtype.UUID
 	err := row.Scan(&id)
 	return id, err
 }
 
-const deleteWallet = `-- name: DeleteWallet :exec
-DELETE FROM wallets WHERE id = $1
+const getAvailableBalance = `-- name: GetAvailableBalance :one
+SELECT LEAST(balance::numeric - held + credit_limit, 9223372036854775807)::bigint AS available FROM wallets
+WHERE id = $1 LIMIT 1
 `
 
-func (q *Queries) DeleteWallet(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteWallet, id)
-	return err
+func (q *Queries) GetAvailableBalance(ctx context.Context, id pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, getAvailableBalance, id)
+	var available int64
+	err := row.Scan(&available)
+	return available, err
 }
 
 const getBalance = `-- name: GetBalance :one
@@ -61,31 +86,187 @@ SELECT balance FROM wallets
 WHERE id = $1 LIMIT 1
 `
 
-func (q *Queries) GetBalance(ctx context.Context, id pgtype.UUID) (int32, error) {
+func (q *Queries) GetBalance(ctx context.Context, id pgtype.UUID) (int64, error) {
 	row := q.db.QueryRow(ctx, getBalance, id)
-	var balance int32
+	var balance int64
 	err := row.Scan(&balance)
 	return balance, err
 }
 
-const getWallets = `-- name: GetWallets :many
-SELECT id, balance, created_at, updated_at FROM wallets ORDER BY created_at
+const getBalanceForUpdate = `-- name: GetBalanceForUpdate :one
+SELECT balance, currency, held, status, credit_limit FROM wallets
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+type GetBalanceForUpdateRow struct {
+	Balance     int64  `json:"balance"`
+	Currency    string `json:"currency"`
+	Held        int64  `json:"held"`
+	Status      string `json:"status"`
+	CreditLimit int64  `json:"credit_limit"`
+}
+
+func (q *Queries) GetBalanceForUpdate(ctx context.Context, id pgtype.UUID) (GetBalanceForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getBalanceForUpdate, id)
+	var i GetBalanceForUpdateRow
+	err := row.Scan(
+		&i.Balance,
+		&i.Currency,
+		&i.Held,
+		&i.Status,
+		&i.CreditLimit,
+	)
+	return i, err
+}
+
+const getBalancesForUpdate = `-- name: GetBalancesForUpdate :many
+SELECT id, balance, currency, held, status, credit_limit FROM wallets
+WHERE id = ANY($1::uuid[])
+ORDER BY id
+FOR UPDATE
 `
 
-func (q *Queries) GetWallets(ctx context.Context) ([]Wallet, error) {
-	rows, err := q.db.Query(ctx, getWallets)
+type GetBalancesForUpdateRow struct {
+	ID          pgtype.UUID `json:"id"`
+	Balance     int64       `json:"balance"`
+	Currency    string      `json:"currency"`
+	Held        int64       `json:"held"`
+	Status      string      `json:"status"`
+	CreditLimit int64       `json:"credit_limit"`
+}
+
+func (q *Queries) GetBalancesForUpdate(ctx context.Context, ids []pgtype.UUID) ([]GetBalancesForUpdateRow, error) {
+	rows, err := q.db.Query(ctx, getBalancesForUpdate, ids)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Wallet
+	var items []GetBalancesForUpdateRow
 	for rows.Next() {
-		var i Wallet
+		var i GetBalancesForUpdateRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Balance,
+			&i.Currency,
+			&i.Held,
+			&i.Status,
+			&i.CreditLimit,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOperation = `-- name: GetOperation :one
+SELECT id, wallet_id, operation_type, amount, created_at, transfer_id, hold_id, reversal_of FROM operations
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetOperation(ctx context.Context, id pgtype.UUID) (Operation, error) {
+	row := q.db.QueryRow(ctx, getOperation, id)
+	var i Operation
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.OperationType,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.TransferID,
+		&i.HoldID,
+		&i.ReversalOf,
+	)
+	return i, err
+}
+
+const getOperationForUpdate = `-- name: GetOperationForUpdate :one
+SELECT id, wallet_id, operation_type, amount, created_at, transfer_id, hold_id, reversal_of FROM operations
+WHERE id = $1 AND wallet_id = $2 LIMIT 1
+FOR UPDATE
+`
+
+type GetOperationForUpdateParams struct {
+	ID       pgtype.UUID `json:"id"`
+	WalletID pgtype.UUID `json:"wallet_id"`
+}
+
+func (q *Queries) GetOperationForUpdate(ctx context.Context, arg GetOperationForUpdateParams) (Operation, error) {
+	row := q.db.QueryRow(ctx, getOperationForUpdate, arg.ID, arg.WalletID)
+	var i Operation
+	err := row.Scan(
+		&i.ID,
+		&i.WalletID,
+		&i.OperationType,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.TransferID,
+		&i.HoldID,
+		&i.ReversalOf,
+	)
+	return i, err
+}
+
+const getOperations = `-- name: GetOperations :many
+SELECT id, wallet_id, operation_type, amount, created_at, transfer_id, hold_id, reversal_of FROM operations
+WHERE wallet_id = $1
+	AND ($2::text IS NULL OR operation_type = $2)
+	AND ($3::bigint IS NULL OR amount >= $3)
+	AND ($4::bigint IS NULL OR amount <= $4)
+	AND ($5::timestamp IS NULL OR created_at >= $5)
+	AND ($6::timestamp IS NULL OR created_at < $6)
+	AND (
+		$7::timestamp IS NULL
+		OR (created_at, id) < ($7, $8::uuid)
+	)
+ORDER BY created_at DESC, id DESC
+LIMIT $9
+`
+
+type GetOperationsParams struct {
+	WalletID        pgtype.UUID      `json:"wallet_id"`
+	OperationType   pgtype.Text      `json:"operation_type"`
+	MinAmount       pgtype.Int8      `json:"min_amount"`
+	MaxAmount       pgtype.Int8      `json:"max_amount"`
+	CreatedFrom     pgtype.Timestamp `json:"created_from"`
+	CreatedTo       pgtype.Timestamp `json:"created_to"`
+	CursorCreatedAt pgtype.Timestamp `json:"cursor_created_at"`
+	CursorID        pgtype.UUID      `json:"cursor_id"`
+	RowLimit        int32            `json:"row_limit"`
+}
+
+func (q *Queries) GetOperations(ctx context.Context, arg GetOperationsParams) ([]Operation, error) {
+	rows, err := q.db.Query(ctx, getOperations,
+		arg.WalletID,
+		arg.OperationType,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Operation
+	for rows.Next() {
+		var i Operation
+		if err := rows.Scan(
+			&i.ID,
+			&i.WalletID,
+			&i.OperationType,
+			&i.Amount,
 			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.TransferID,
+			&i.HoldID,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -97,17 +278,89 @@ func (q *Queries) GetWallets(ctx context.Context) ([]Wallet, error) {
 	return items, nil
 }
 
-const updateWallet = `-- name: UpdateWallet :exec
-UPDATE wallets SET balance = $1, updated_at = NOW()
+const getReversedAmount = `-- name: GetReversedAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS reversed FROM operations
+WHERE reversal_of = $1
+`
+
+func (q *Queries) GetReversedAmount(ctx context.Context, reversalOf pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, getReversedAmount, reversalOf)
+	var reversed int64
+	err := row.Scan(&reversed)
+	return reversed, err
+}
+
+const getWallet = `-- name: GetWallet :one
+SELECT id, balance, created_at, updated_at, currency, held, status, credit_limit FROM wallets
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetWallet(ctx context.Context, id pgtype.UUID) (Wallet, error) {
+	row := q.db.QueryRow(ctx, getWallet, id)
+	var i Wallet
+	err := row.Scan(
+		&i.ID,
+		&i.Balance,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Held,
+		&i.Status,
+		&i.CreditLimit,
+	)
+	return i, err
+}
+
+const updateWalletCreditLimit = `-- name: UpdateWalletCreditLimit :one
+UPDATE wallets SET credit_limit = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, balance, created_at, updated_at, currency, held, status, credit_limit
+`
+
+type UpdateWalletCreditLimitParams struct {
+	CreditLimit int64       `json:"credit_limit"`
+	ID          pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateWalletCreditLimit(ctx context.Context, arg UpdateWalletCreditLimitParams) (Wallet, error) {
+	row := q.db.QueryRow(ctx, updateWalletCreditLimit, arg.CreditLimit, arg.ID)
+	var i Wallet
+	err := row.Scan(
+		&i.ID,
+		&i.Balance,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Held,
+		&i.Status,
+		&i.CreditLimit,
+	)
+	return i, err
+}
+
+const updateWalletStatus = `-- name: UpdateWalletStatus :one
+UPDATE wallets SET status = $1, updated_at = NOW()
 WHERE id = $2
+RETURNING id, balance, created_at, updated_at, currency, held, status, credit_limit
 `
 
-type UpdateWalletParams struct {
-	Balance int32       `json:"balance"`
-	ID      pgtype.UUID `json:"id"`
+type UpdateWalletStatusParams struct {
+	Status string      `json:"status"`
+	ID     pgtype.UUID `json:"id"`
 }
 
-func (q *Queries) UpdateWallet(ctx context.Context, arg UpdateWalletParams) error {
-	_, err := q.db.Exec(ctx, updateWallet, arg.Balance, arg.ID)
-	return err
+func (q *Queries) UpdateWalletStatus(ctx context.Context, arg UpdateWalletStatusParams) (Wallet, error) {
+	row := q.db.QueryRow(ctx, updateWalletStatus, arg.Status, arg.ID)
+	var i Wallet
+	err := row.Scan(
+		&i.ID,
+		&i.Balance,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Held,
+		&i.Status,
+		&i.CreditLimit,
+	)
+	return i, err
 }
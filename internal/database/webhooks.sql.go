@@ -0,0 +1,332 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = NOW() + $1::interval
+FROM outbox_events e, webhook_subscriptions s
+WHERE d.id IN (
+	SELECT wd.id FROM webhook_deliveries wd
+	JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+	WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW() AND ws.active
+	ORDER BY wd.next_attempt_at
+	LIMIT $2
+	FOR UPDATE OF wd SKIP LOCKED
+) AND e.id = d.event_id AND s.id = d.subscription_id
+RETURNING d.id, d.attempts, e.event_type, e.payload, s.url, s.secret
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	Lease         pgtype.Interval `json:"lease"`
+	MaxDeliveries int32           `json:"max_deliveries"`
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID        pgtype.UUID `json:"id"`
+	Attempts  int32       `json:"attempts"`
+	EventType string      `json:"event_type"`
+	Payload   []byte      `json:"payload"`
+	Url       string      `json:"url"`
+	Secret    string      `json:"secret"`
+}
+
+// Claims due deliveries for one attempt: their next attempt is moved past the lease,
+// so another dispatcher does not pick them up while they are being sent
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.Lease, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Attempts,
+			&i.EventType,
+			&i.Payload,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, url, secret, wallet_id)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	$3
+)
+RETURNING id, url, secret, wallet_id, active, created_at
+`
+
+type CreateWebhookSubscriptionParams struct {
+	Url      string      `json:"url"`
+	Secret   string      `json:"secret"`
+	WalletID pgtype.UUID `json:"wallet_id"`
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, createWebhookSubscription, arg.Url, arg.Secret, arg.WalletID)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.WalletID,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deactivateWebhookSubscription = `-- name: DeactivateWebhookSubscription :execrows
+UPDATE webhook_subscriptions
+SET active = FALSE
+WHERE id = $1 AND active
+`
+
+func (q *Queries) DeactivateWebhookSubscription(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deactivateWebhookSubscription, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredWebhookEvents = `-- name: DeleteExpiredWebhookEvents :execrows
+DELETE FROM outbox_events e
+WHERE e.dispatched_at IS NOT NULL
+	AND e.created_at < NOW() - $1::interval
+	AND NOT EXISTS (
+		SELECT 1 FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.event_id = e.id AND d.status = 'pending' AND s.active
+	)
+`
+
+// Deletes dispatched events older than the retention period together with their deliveries,
+// unless a delivery to an active subscription is still pending
+func (q *Queries) DeleteExpiredWebhookEvents(ctx context.Context, retention pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredWebhookEvents, retention)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const fanOutOutboxEvents = `-- name: FanOutOutboxEvents :execrows
+WITH events AS (
+	SELECT id, wallet_id FROM outbox_events
+	WHERE dispatched_at IS NULL
+	ORDER BY created_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+), deliveries AS (
+	INSERT INTO webhook_deliveries (id, event_id, subscription_id)
+	SELECT gen_random_uuid(), e.id, s.id
+	FROM events e
+	JOIN webhook_subscriptions s ON s.active AND (s.wallet_id IS NULL OR s.wallet_id = e.wallet_id)
+	ON CONFLICT (event_id, subscription_id) DO NOTHING
+)
+UPDATE outbox_events
+SET dispatched_at = NOW()
+WHERE id IN (SELECT id FROM events)
+`
+
+// Creates a delivery of each undispatched event for every active subscription it matches
+// and marks the events as dispatched
+func (q *Queries) FanOutOutboxEvents(ctx context.Context, limit int32) (int64, error) {
+	result, err := q.db.Exec(ctx, fanOutOutboxEvents, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_deliveries
+WHERE $1::text IS NULL OR status = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetWebhookDeliveriesParams struct {
+	Status        pgtype.Text `json:"status"`
+	MaxDeliveries int32       `json:"max_deliveries"`
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, getWebhookDeliveries, arg.Status, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_deliveries
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id pgtype.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhookSubscriptions = `-- name: GetWebhookSubscriptions :many
+SELECT id, url, secret, wallet_id, active, created_at FROM webhook_subscriptions
+WHERE active
+ORDER BY created_at
+`
+
+func (q *Queries) GetWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	rows, err := q.db.Query(ctx, getWebhookSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.WalletID,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDelivered = `-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $1, last_error = NULL, delivered_at = NOW()
+WHERE id = $2
+`
+
+type MarkWebhookDeliveredParams struct {
+	LastStatusCode pgtype.Int4 `json:"last_status_code"`
+	ID             pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) MarkWebhookDelivered(ctx context.Context, arg MarkWebhookDeliveredParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDelivered, arg.LastStatusCode, arg.ID)
+	return err
+}
+
+const markWebhookDeliveryFailed = `-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $1, next_attempt_at = $2,
+	last_status_code = $3, last_error = $4
+WHERE id = $5
+`
+
+type MarkWebhookDeliveryFailedParams struct {
+	Status         string           `json:"status"`
+	NextAttemptAt  pgtype.Timestamp `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4      `json:"last_status_code"`
+	LastError      pgtype.Text      `json:"last_error"`
+	ID             pgtype.UUID      `json:"id"`
+}
+
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDeliveryFailed,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const redeliverWebhookDelivery = `-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+WHERE id = $1 AND status <> 'pending'
+RETURNING id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+// Schedules a delivered or dead delivery to be sent again right away with a fresh set of attempts
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, id pgtype.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, redeliverWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
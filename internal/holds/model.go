@@ -0,0 +1,22 @@
+package holds
+
+// Hold statuses: an active hold reserves funds until it is captured, voided or expires
+const (
+	Active   = "active"
+	Captured = "captured"
+	Voided   = "voided"
+	Expired  = "expired"
+)
+
+// Request is the body of a request to place a hold on wallet funds
+type Request struct {
+	Amount     int64  `json:"amount"`
+	TTLSeconds int64  `json:"ttl_seconds,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+}
+
+// CaptureRequest is the optional body of a request to capture a hold.
+// A zero amount captures the whole held amount.
+type CaptureRequest struct {
+	Amount int64 `json:"amount,omitempty"`
+}
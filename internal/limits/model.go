@@ -0,0 +1,8 @@
+package limits
+
+// Request is the body of a request to set the withdrawal limits of a wallet.
+// A missing or null limit means that withdrawals are not limited for that period.
+type Request struct {
+	DailyWithdrawalLimit   *int64 `json:"daily_withdrawal_limit"`
+	MonthlyWithdrawalLimit *int64 `json:"monthly_withdrawal_limit"`
+}
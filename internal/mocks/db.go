@@ -2,6 +2,9 @@ package mocks
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -9,32 +12,176 @@ import (
 
 // DBTX is a mock implementation of the database.DBTX interface
 type DBTX struct {
-	Balance int32
-	Err     error
+	Balance int64
+	// Row holds the columns returned by QueryRow; if it is empty, the row holds Balance only
+	Row []any
+	// QueryRows holds the columns returned by QueryRow for particular queries, keyed by their sqlc name.
+	// Other queries return Row.
+	QueryRows map[string][]any
+	Err error
+	// RowsAffected is the number of rows reported by Exec
+	RowsAffected int64
 }
 
 func (m *DBTX) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
-	return pgconn.CommandTag{}, nil
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", m.RowsAffected)), nil
 }
 
 func (m *DBTX) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
-	return nil, nil
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &MockRows{}, nil
 }
 
 func (m *DBTX) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
-	return &MockRow{Balance: m.Balance, Err: m.Err}
+	if values, ok := m.QueryRows[queryName(sql)]; ok {
+		return &MockRow{Values: values, Err: m.Err}
+	}
+	if len(m.Row) > 0 {
+		return &MockRow{Values: m.Row, Err: m.Err}
+	}
+	return &MockRow{Values: []any{m.Balance}, Err: m.Err}
+}
+
+// queryName returns the name of a query generated by sqlc from its "-- name: Name :kind" comment
+func queryName(sql string) string {
+	fields := strings.Fields(strings.TrimPrefix(sql, "-- name:"))
+	if !strings.HasPrefix(sql, "-- name:") || len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// SendBatch runs all queued queries against the same DBTX
+func (m *DBTX) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &BatchResults{DBTX: m}
+}
+
+// Begin starts a mock transaction that runs all queries against the same DBTX
+func (m *DBTX) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &Tx{DBTX: m}, nil
+}
+
+func (m *DBTX) Ping(ctx context.Context) error {
+	return m.Err
 }
 
 // MockRow is a mock implementation of pgx.Row
 type MockRow struct {
-	Balance int32
-	Err     error
+	Values []any
+	Err    error
 }
 
+// Scan copies the values into the destinations of matching types.
+// Destinations with nil or mismatching values are left untouched.
 func (r *MockRow) Scan(dest ...any) error {
 	if r.Err != nil {
 		return r.Err
 	}
-	*dest[0].(*int32) = r.Balance
+	for i, value := range r.Values {
+		if i >= len(dest) {
+			break
+		}
+		if value == nil {
+			continue
+		}
+		target := reflect.ValueOf(dest[i]).Elem()
+		source := reflect.ValueOf(value)
+		if source.Type().AssignableTo(target.Type()) {
+			target.Set(source)
+		}
+	}
+	return nil
+}
+
+// MockRows is a mock implementation of pgx.Rows that holds no rows
+type MockRows struct{}
+
+func (r *MockRows) Close() {}
+
+func (r *MockRows) Err() error {
+	return nil
+}
+
+func (r *MockRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (r *MockRows) FieldDescriptions() []pgconn.FieldDescription {
+	return nil
+}
+
+func (r *MockRows) Next() bool {
+	return false
+}
+
+func (r *MockRows) Scan(dest ...any) error {
+	return nil
+}
+
+func (r *MockRows) Values() ([]any, error) {
+	return nil, nil
+}
+
+func (r *MockRows) RawValues() [][]byte {
+	return nil
+}
+
+func (r *MockRows) Conn() *pgx.Conn {
+	return nil
+}
+
+// BatchResults is a mock implementation of pgx.BatchResults
+type BatchResults struct {
+	*DBTX
+}
+
+func (b *BatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, b.Err
+}
+
+func (b *BatchResults) Query() (pgx.Rows, error) {
+	return b.DBTX.Query(context.Background(), "")
+}
+
+func (b *BatchResults) QueryRow() pgx.Row {
+	return b.DBTX.QueryRow(context.Background(), "")
+}
+
+func (b *BatchResults) Close() error {
+	return nil
+}
+
+// Tx is a mock implementation of pgx.Tx
+type Tx struct {
+	*DBTX
+}
+
+func (t *Tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return t, nil
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (t *Tx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+func (t *Tx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return 0, nil
+}
+
+func (t *Tx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *Tx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, nil
+}
+
+func (t *Tx) Conn() *pgx.Conn {
 	return nil
 }
@@ -0,0 +1,193 @@
+// Package openapi holds the OpenAPI specification of the wallet API and a middleware
+// that checks requests and responses against it.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+func init() {
+	// Event streams are validated as plain text, and UUIDs are checked rather than accepted as any string
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.PlainBodyDecoder)
+	openapi3.DefineStringFormatValidator("uuid", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForUUIDOfRFC9562))
+}
+
+// Load parses and validates the specification
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI specification: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI specification: %w", err)
+	}
+	return doc, nil
+}
+
+// Patterns returns the operations of the specification as net/http.ServeMux patterns, e.g. "GET /api/v1/wallets"
+func Patterns(doc *openapi3.T) []string {
+	var patterns []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			patterns = append(patterns, method+" "+path)
+		}
+	}
+	return patterns
+}
+
+// pathParam matches the wildcards of a path, which have the same syntax in OpenAPI and net/http.ServeMux
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Validator checks requests and responses against the specification.
+// It is meant for tests: every request is buffered and every response is recorded.
+type Validator struct {
+	mux    *http.ServeMux
+	report func(r *http.Request, err error)
+}
+
+// match is where the routes of the validator mux store the operation a request matched
+type match struct {
+	route  *routers.Route
+	params map[string]string
+}
+
+type matchKey struct{}
+
+// NewValidator returns a validator for the specification that calls report for every violation.
+// Requests are matched to operations with the same rules as net/http.ServeMux.
+func NewValidator(doc *openapi3.T, report func(r *http.Request, err error)) *Validator {
+	v := &Validator{mux: http.NewServeMux(), report: report}
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			route := &routers.Route{Spec: doc, Path: path, PathItem: item, Method: method, Operation: operation}
+			names := pathParam.FindAllStringSubmatch(path, -1)
+			v.mux.HandleFunc(method+" "+path, func(_ http.ResponseWriter, r *http.Request) {
+				m := r.Context().Value(matchKey{}).(*match)
+				m.route = route
+				m.params = make(map[string]string, len(names))
+				for _, name := range names {
+					m.params[name[1]] = r.PathValue(name[1])
+				}
+			})
+		}
+	}
+	return v
+}
+
+// findRoute returns the operation the request matches and its path parameters, or nil if there is none
+func (v *Validator) findRoute(r *http.Request) (*routers.Route, map[string]string) {
+	m := &match{}
+	v.mux.ServeHTTP(discardWriter{}, r.WithContext(context.WithValue(r.Context(), matchKey{}, m)))
+	return m.route, m.params
+}
+
+// Middleware validates the requests to the handler and its responses.
+// A request that violates the specification is only reported if the handler accepted it,
+// and a request to an undocumented route is only reported if the handler did not reject it as unknown.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Buffer the body so that it can be read by both the handler and the validator
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(r.Body)
+			if err != nil {
+				v.report(r, fmt.Errorf("failed to read request body: %w", err))
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route, params := v.findRoute(r)
+		if route == nil {
+			if rec.status != http.StatusNotFound && rec.status != http.StatusMethodNotAllowed {
+				v.report(r, fmt.Errorf("route is not documented, but the handler responded with %d", rec.status))
+			}
+			return
+		}
+
+		// Handlers decode the body as JSON whatever its content type
+		req := r.Clone(r.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) > 0 && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		options := &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+			SkipSettingDefaults:   true,
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		}
+		input := &openapi3filter.RequestValidationInput{Request: req, PathParams: params, Route: route, Options: options}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil && rec.status < http.StatusBadRequest {
+			v.report(r, fmt.Errorf("request violates the specification, but the handler responded with %d: %w", rec.status, err))
+		}
+
+		err := openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+			Options:                options,
+		})
+		if err != nil {
+			v.report(r, fmt.Errorf("response with status %d violates the specification: %w", rec.status, err))
+		}
+	})
+}
+
+// recorder passes the response through and keeps a copy of its status code and body
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (rec *recorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying response writer
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// discardWriter is the response writer of the validator mux, which only matches routes
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header         { return http.Header{} }
+func (discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardWriter) WriteHeader(int)             {}
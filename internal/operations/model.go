@@ -1,11 +1,91 @@
 package operations
 
+import (
+	"errors"
+	"math"
+)
+
 const (
 	Deposit  = "deposit"
 	Withdraw = "withdraw"
+	Transfer = "transfer"
 )
 
+// ErrOverflow is returned when the result of an operation does not fit in a 64-bit balance
+var ErrOverflow = errors.New("balance overflow")
+
 type Operation struct {
+	OperationType  string `json:"operation_type"`
+	Amount         int64  `json:"amount"`
+	TargetWalletID string `json:"target_wallet_id,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+}
+
+// Credit adds a non-negative amount to the balance, reporting overflow instead of wrapping around
+func Credit(balance, amount int64) (int64, error) {
+	if balance > 0 && amount > math.MaxInt64-balance {
+		return 0, ErrOverflow
+	}
+	return balance + amount, nil
+}
+
+// Debit subtracts a non-negative amount from the balance, reporting overflow instead of wrapping around
+func Debit(balance, amount int64) (int64, error) {
+	if balance < math.MinInt64+amount {
+		return 0, ErrOverflow
+	}
+	return balance - amount, nil
+}
+
+// Available returns the funds that can be spent from a wallet: the balance without the funds
+// reserved by holds plus the credit limit. It saturates at the maximum balance instead of overflowing.
+func Available(balance, held, creditLimit int64) int64 {
+	available, err := Credit(balance-held, creditLimit)
+	if err != nil {
+		return math.MaxInt64
+	}
+	return available
+}
+
+// CreditUsed returns the part of the credit limit in use, that is the negative part of the balance
+func CreditUsed(balance int64) int64 {
+	if balance >= 0 {
+		return 0
+	}
+	return -balance
+}
+
+// ReversalRequest is the optional body of a request to reverse an operation.
+// A zero amount reverses everything that has not been reversed yet.
+type ReversalRequest struct {
+	Amount int64 `json:"amount,omitempty"`
+}
+
+// Opposite returns the operation type that reverses the given one
+func Opposite(operationType string) string {
+	if operationType == Deposit {
+		return Withdraw
+	}
+	return Deposit
+}
+
+// Batch modes: an atomic batch is applied only if every operation succeeds,
+// a best-effort batch applies the operations that succeed and skips the rest
+const (
+	Atomic     = "atomic"
+	BestEffort = "best_effort"
+)
+
+// BatchRequest is the body of a request to apply many deposits and withdrawals at once
+type BatchRequest struct {
+	Mode       string      `json:"mode,omitempty"`
+	Operations []BatchItem `json:"operations"`
+}
+
+// BatchItem is a single deposit or withdrawal in a batch
+type BatchItem struct {
+	WalletID      string `json:"wallet_id"`
 	OperationType string `json:"operation_type"`
-	Amount        int32  `json:"amount"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency,omitempty"`
 }
@@ -0,0 +1,75 @@
+package operations
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredit(t *testing.T) {
+	tests := []struct {
+		name          string
+		balance       int64
+		amount        int64
+		expected      int64
+		expectedError error
+	}{
+		{name: "Regular deposit", balance: 100, amount: 50, expected: 150},
+		{name: "Up to the maximum", balance: math.MaxInt64 - 1, amount: 1, expected: math.MaxInt64},
+		{name: "Overflow", balance: math.MaxInt64, amount: 1, expectedError: ErrOverflow},
+		{name: "Negative balance", balance: -100, amount: math.MaxInt64, expected: math.MaxInt64 - 100},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Credit(tc.balance, tc.amount)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestDebit(t *testing.T) {
+	tests := []struct {
+		name          string
+		balance       int64
+		amount        int64
+		expected      int64
+		expectedError error
+	}{
+		{name: "Regular withdrawal", balance: 100, amount: 30, expected: 70},
+		{name: "Below zero", balance: 10, amount: 30, expected: -20},
+		{name: "Down to the minimum", balance: math.MinInt64 + 1, amount: 1, expected: math.MinInt64},
+		{name: "Overflow", balance: math.MinInt64, amount: 1, expectedError: ErrOverflow},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Debit(tc.balance, tc.amount)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		held        int64
+		creditLimit int64
+		expected    int64
+	}{
+		{name: "Without credit", balance: 100, held: 30, creditLimit: 0, expected: 70},
+		{name: "With credit", balance: 100, held: 30, creditLimit: 500, expected: 570},
+		{name: "Credit in use", balance: -200, held: 0, creditLimit: 500, expected: 300},
+		{name: "Saturated", balance: math.MaxInt64, held: 0, creditLimit: math.MaxInt64, expected: math.MaxInt64},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Available(tc.balance, tc.held, tc.creditLimit))
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package reconciliation
+
+// Run statuses: a run is running until all wallets are checked or it fails
+const (
+	Running   = "running"
+	Completed = "completed"
+	Failed    = "failed"
+)
@@ -0,0 +1,4 @@
+// Package walletpb holds the gRPC interface of the wallet API generated from wallet.proto
+package walletpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative wallet.proto
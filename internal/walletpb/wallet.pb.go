@@ -0,0 +1,1235 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: wallet.proto
+
+package walletpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type OperationType int32
+
+const (
+	OperationType_OPERATION_TYPE_UNSPECIFIED OperationType = 0
+	OperationType_OPERATION_TYPE_DEPOSIT     OperationType = 1
+	OperationType_OPERATION_TYPE_WITHDRAW    OperationType = 2
+	OperationType_OPERATION_TYPE_TRANSFER    OperationType = 3
+)
+
+// Enum value maps for OperationType.
+var (
+	OperationType_name = map[int32]string{
+		0: "OPERATION_TYPE_UNSPECIFIED",
+		1: "OPERATION_TYPE_DEPOSIT",
+		2: "OPERATION_TYPE_WITHDRAW",
+		3: "OPERATION_TYPE_TRANSFER",
+	}
+	OperationType_value = map[string]int32{
+		"OPERATION_TYPE_UNSPECIFIED": 0,
+		"OPERATION_TYPE_DEPOSIT":     1,
+		"OPERATION_TYPE_WITHDRAW":    2,
+		"OPERATION_TYPE_TRANSFER":    3,
+	}
+)
+
+func (x OperationType) Enum() *OperationType {
+	p := new(OperationType)
+	*p = x
+	return p
+}
+
+func (x OperationType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OperationType) Descriptor() protoreflect.EnumDescriptor {
+	return file_wallet_proto_enumTypes[0].Descriptor()
+}
+
+func (OperationType) Type() protoreflect.EnumType {
+	return &file_wallet_proto_enumTypes[0]
+}
+
+func (x OperationType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OperationType.Descriptor instead.
+func (OperationType) EnumDescriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{0}
+}
+
+type BalanceKind int32
+
+const (
+	// The ledger balance is returned if the kind is not specified
+	BalanceKind_BALANCE_KIND_UNSPECIFIED BalanceKind = 0
+	BalanceKind_BALANCE_KIND_LEDGER      BalanceKind = 1
+	// The available balance excludes funds reserved by holds
+	BalanceKind_BALANCE_KIND_AVAILABLE BalanceKind = 2
+)
+
+// Enum value maps for BalanceKind.
+var (
+	BalanceKind_name = map[int32]string{
+		0: "BALANCE_KIND_UNSPECIFIED",
+		1: "BALANCE_KIND_LEDGER",
+		2: "BALANCE_KIND_AVAILABLE",
+	}
+	BalanceKind_value = map[string]int32{
+		"BALANCE_KIND_UNSPECIFIED": 0,
+		"BALANCE_KIND_LEDGER":      1,
+		"BALANCE_KIND_AVAILABLE":   2,
+	}
+)
+
+func (x BalanceKind) Enum() *BalanceKind {
+	p := new(BalanceKind)
+	*p = x
+	return p
+}
+
+func (x BalanceKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BalanceKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_wallet_proto_enumTypes[1].Descriptor()
+}
+
+func (BalanceKind) Type() protoreflect.EnumType {
+	return &file_wallet_proto_enumTypes[1]
+}
+
+func (x BalanceKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BalanceKind.Descriptor instead.
+func (BalanceKind) EnumDescriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{1}
+}
+
+type WalletStatus int32
+
+const (
+	WalletStatus_WALLET_STATUS_UNSPECIFIED WalletStatus = 0
+	WalletStatus_WALLET_STATUS_ACTIVE      WalletStatus = 1
+	WalletStatus_WALLET_STATUS_FROZEN      WalletStatus = 2
+	WalletStatus_WALLET_STATUS_CLOSED      WalletStatus = 3
+)
+
+// Enum value maps for WalletStatus.
+var (
+	WalletStatus_name = map[int32]string{
+		0: "WALLET_STATUS_UNSPECIFIED",
+		1: "WALLET_STATUS_ACTIVE",
+		2: "WALLET_STATUS_FROZEN",
+		3: "WALLET_STATUS_CLOSED",
+	}
+	WalletStatus_value = map[string]int32{
+		"WALLET_STATUS_UNSPECIFIED": 0,
+		"WALLET_STATUS_ACTIVE":      1,
+		"WALLET_STATUS_FROZEN":      2,
+		"WALLET_STATUS_CLOSED":      3,
+	}
+)
+
+func (x WalletStatus) Enum() *WalletStatus {
+	p := new(WalletStatus)
+	*p = x
+	return p
+}
+
+func (x WalletStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WalletStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_wallet_proto_enumTypes[2].Descriptor()
+}
+
+func (WalletStatus) Type() protoreflect.EnumType {
+	return &file_wallet_proto_enumTypes[2]
+}
+
+func (x WalletStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WalletStatus.Descriptor instead.
+func (WalletStatus) EnumDescriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{2}
+}
+
+type WalletSort int32
+
+const (
+	// Wallets are sorted by creation time if the sort is not specified
+	WalletSort_WALLET_SORT_UNSPECIFIED WalletSort = 0
+	WalletSort_WALLET_SORT_CREATED_AT  WalletSort = 1
+	WalletSort_WALLET_SORT_UPDATED_AT  WalletSort = 2
+	WalletSort_WALLET_SORT_BALANCE     WalletSort = 3
+)
+
+// Enum value maps for WalletSort.
+var (
+	WalletSort_name = map[int32]string{
+		0: "WALLET_SORT_UNSPECIFIED",
+		1: "WALLET_SORT_CREATED_AT",
+		2: "WALLET_SORT_UPDATED_AT",
+		3: "WALLET_SORT_BALANCE",
+	}
+	WalletSort_value = map[string]int32{
+		"WALLET_SORT_UNSPECIFIED": 0,
+		"WALLET_SORT_CREATED_AT":  1,
+		"WALLET_SORT_UPDATED_AT":  2,
+		"WALLET_SORT_BALANCE":     3,
+	}
+)
+
+func (x WalletSort) Enum() *WalletSort {
+	p := new(WalletSort)
+	*p = x
+	return p
+}
+
+func (x WalletSort) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WalletSort) Descriptor() protoreflect.EnumDescriptor {
+	return file_wallet_proto_enumTypes[3].Descriptor()
+}
+
+func (WalletSort) Type() protoreflect.EnumType {
+	return &file_wallet_proto_enumTypes[3]
+}
+
+func (x WalletSort) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WalletSort.Descriptor instead.
+func (WalletSort) EnumDescriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{3}
+}
+
+type Wallet struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Balance in minor currency units
+	Balance        int64  `protobuf:"varint,2,opt,name=balance,proto3" json:"balance,omitempty"`
+	BalanceDecimal string `protobuf:"bytes,3,opt,name=balance_decimal,json=balanceDecimal,proto3" json:"balance_decimal,omitempty"`
+	Currency       string `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Funds reserved by holds
+	Held          int64                  `protobuf:"varint,5,opt,name=held,proto3" json:"held,omitempty"`
+	Status        WalletStatus           `protobuf:"varint,6,opt,name=status,proto3,enum=wallet.v1.WalletStatus" json:"status,omitempty"`
+	CreditLimit   int64                  `protobuf:"varint,7,opt,name=credit_limit,json=creditLimit,proto3" json:"credit_limit,omitempty"`
+	CreditUsed    int64                  `protobuf:"varint,8,opt,name=credit_used,json=creditUsed,proto3" json:"credit_used,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Wallet) Reset() {
+	*x = Wallet{}
+	mi := &file_wallet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Wallet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Wallet) ProtoMessage() {}
+
+func (x *Wallet) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Wallet.ProtoReflect.Descriptor instead.
+func (*Wallet) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Wallet) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Wallet) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *Wallet) GetBalanceDecimal() string {
+	if x != nil {
+		return x.BalanceDecimal
+	}
+	return ""
+}
+
+func (x *Wallet) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Wallet) GetHeld() int64 {
+	if x != nil {
+		return x.Held
+	}
+	return 0
+}
+
+func (x *Wallet) GetStatus() WalletStatus {
+	if x != nil {
+		return x.Status
+	}
+	return WalletStatus_WALLET_STATUS_UNSPECIFIED
+}
+
+func (x *Wallet) GetCreditLimit() int64 {
+	if x != nil {
+		return x.CreditLimit
+	}
+	return 0
+}
+
+func (x *Wallet) GetCreditUsed() int64 {
+	if x != nil {
+		return x.CreditUsed
+	}
+	return 0
+}
+
+func (x *Wallet) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Wallet) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateWalletRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ISO 4217 currency code; the server default if empty
+	Currency      string `protobuf:"bytes,1,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateWalletRequest) Reset() {
+	*x = CreateWalletRequest{}
+	mi := &file_wallet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateWalletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateWalletRequest) ProtoMessage() {}
+
+func (x *CreateWalletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateWalletRequest.ProtoReflect.Descriptor instead.
+func (*CreateWalletRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateWalletRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type CreateWalletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WalletId      string                 `protobuf:"bytes,1,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateWalletResponse) Reset() {
+	*x = CreateWalletResponse{}
+	mi := &file_wallet_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateWalletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateWalletResponse) ProtoMessage() {}
+
+func (x *CreateWalletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateWalletResponse.ProtoReflect.Descriptor instead.
+func (*CreateWalletResponse) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateWalletResponse) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+type GetBalanceRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	WalletId string                 `protobuf:"bytes,1,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	Kind     BalanceKind            `protobuf:"varint,2,opt,name=kind,proto3,enum=wallet.v1.BalanceKind" json:"kind,omitempty"`
+	// Point in time to get the historical ledger balance at
+	AsOf          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBalanceRequest) Reset() {
+	*x = GetBalanceRequest{}
+	mi := &file_wallet_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalanceRequest) ProtoMessage() {}
+
+func (x *GetBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalanceRequest.ProtoReflect.Descriptor instead.
+func (*GetBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetBalanceRequest) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+func (x *GetBalanceRequest) GetKind() BalanceKind {
+	if x != nil {
+		return x.Kind
+	}
+	return BalanceKind_BALANCE_KIND_UNSPECIFIED
+}
+
+func (x *GetBalanceRequest) GetAsOf() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AsOf
+	}
+	return nil
+}
+
+type GetBalanceResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	WalletId       string                 `protobuf:"bytes,1,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	Kind           BalanceKind            `protobuf:"varint,2,opt,name=kind,proto3,enum=wallet.v1.BalanceKind" json:"kind,omitempty"`
+	Balance        int64                  `protobuf:"varint,3,opt,name=balance,proto3" json:"balance,omitempty"`
+	BalanceDecimal string                 `protobuf:"bytes,4,opt,name=balance_decimal,json=balanceDecimal,proto3" json:"balance_decimal,omitempty"`
+	Currency       string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	AsOf           *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetBalanceResponse) Reset() {
+	*x = GetBalanceResponse{}
+	mi := &file_wallet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalanceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalanceResponse) ProtoMessage() {}
+
+func (x *GetBalanceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalanceResponse.ProtoReflect.Descriptor instead.
+func (*GetBalanceResponse) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetBalanceResponse) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+func (x *GetBalanceResponse) GetKind() BalanceKind {
+	if x != nil {
+		return x.Kind
+	}
+	return BalanceKind_BALANCE_KIND_UNSPECIFIED
+}
+
+func (x *GetBalanceResponse) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *GetBalanceResponse) GetBalanceDecimal() string {
+	if x != nil {
+		return x.BalanceDecimal
+	}
+	return ""
+}
+
+func (x *GetBalanceResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GetBalanceResponse) GetAsOf() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AsOf
+	}
+	return nil
+}
+
+func (x *GetBalanceResponse) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type ApplyOperationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WalletId      string                 `protobuf:"bytes,1,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	OperationType OperationType          `protobuf:"varint,2,opt,name=operation_type,json=operationType,proto3,enum=wallet.v1.OperationType" json:"operation_type,omitempty"`
+	// Amount in minor currency units
+	Amount int64 `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	// The wallet to transfer to; required for transfers
+	TargetWalletId string `protobuf:"bytes,4,opt,name=target_wallet_id,json=targetWalletId,proto3" json:"target_wallet_id,omitempty"`
+	// Must match the wallet currency if set
+	Currency      string `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyOperationRequest) Reset() {
+	*x = ApplyOperationRequest{}
+	mi := &file_wallet_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyOperationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyOperationRequest) ProtoMessage() {}
+
+func (x *ApplyOperationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyOperationRequest.ProtoReflect.Descriptor instead.
+func (*ApplyOperationRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ApplyOperationRequest) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+func (x *ApplyOperationRequest) GetOperationType() OperationType {
+	if x != nil {
+		return x.OperationType
+	}
+	return OperationType_OPERATION_TYPE_UNSPECIFIED
+}
+
+func (x *ApplyOperationRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *ApplyOperationRequest) GetTargetWalletId() string {
+	if x != nil {
+		return x.TargetWalletId
+	}
+	return ""
+}
+
+func (x *ApplyOperationRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type ApplyOperationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OperationId   string                 `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+	WalletId      string                 `protobuf:"bytes,2,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	OperationType OperationType          `protobuf:"varint,3,opt,name=operation_type,json=operationType,proto3,enum=wallet.v1.OperationType" json:"operation_type,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	// Set for transfers
+	TransferId string `protobuf:"bytes,5,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	// Balance of the wallet after the operation
+	Balance        int64  `protobuf:"varint,6,opt,name=balance,proto3" json:"balance,omitempty"`
+	BalanceDecimal string `protobuf:"bytes,7,opt,name=balance_decimal,json=balanceDecimal,proto3" json:"balance_decimal,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ApplyOperationResponse) Reset() {
+	*x = ApplyOperationResponse{}
+	mi := &file_wallet_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyOperationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyOperationResponse) ProtoMessage() {}
+
+func (x *ApplyOperationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyOperationResponse.ProtoReflect.Descriptor instead.
+func (*ApplyOperationResponse) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ApplyOperationResponse) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
+func (x *ApplyOperationResponse) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+func (x *ApplyOperationResponse) GetOperationType() OperationType {
+	if x != nil {
+		return x.OperationType
+	}
+	return OperationType_OPERATION_TYPE_UNSPECIFIED
+}
+
+func (x *ApplyOperationResponse) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *ApplyOperationResponse) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *ApplyOperationResponse) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *ApplyOperationResponse) GetBalanceDecimal() string {
+	if x != nil {
+		return x.BalanceDecimal
+	}
+	return ""
+}
+
+type ListWalletsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of wallets per page, 50 by default and at most 1000
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// The next_page_token of the previous page
+	PageToken   string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	Sort        WalletSort             `protobuf:"varint,3,opt,name=sort,proto3,enum=wallet.v1.WalletSort" json:"sort,omitempty"`
+	Descending  bool                   `protobuf:"varint,4,opt,name=descending,proto3" json:"descending,omitempty"`
+	Status      WalletStatus           `protobuf:"varint,5,opt,name=status,proto3,enum=wallet.v1.WalletStatus" json:"status,omitempty"`
+	MinBalance  *int64                 `protobuf:"varint,6,opt,name=min_balance,json=minBalance,proto3,oneof" json:"min_balance,omitempty"`
+	MaxBalance  *int64                 `protobuf:"varint,7,opt,name=max_balance,json=maxBalance,proto3,oneof" json:"max_balance,omitempty"`
+	CreatedFrom *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_from,json=createdFrom,proto3" json:"created_from,omitempty"`
+	CreatedTo   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_to,json=createdTo,proto3" json:"created_to,omitempty"`
+	// Count all matching wallets; this scans every matching row
+	IncludeTotal  bool `protobuf:"varint,10,opt,name=include_total,json=includeTotal,proto3" json:"include_total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWalletsRequest) Reset() {
+	*x = ListWalletsRequest{}
+	mi := &file_wallet_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWalletsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWalletsRequest) ProtoMessage() {}
+
+func (x *ListWalletsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWalletsRequest.ProtoReflect.Descriptor instead.
+func (*ListWalletsRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListWalletsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListWalletsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListWalletsRequest) GetSort() WalletSort {
+	if x != nil {
+		return x.Sort
+	}
+	return WalletSort_WALLET_SORT_UNSPECIFIED
+}
+
+func (x *ListWalletsRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+func (x *ListWalletsRequest) GetStatus() WalletStatus {
+	if x != nil {
+		return x.Status
+	}
+	return WalletStatus_WALLET_STATUS_UNSPECIFIED
+}
+
+func (x *ListWalletsRequest) GetMinBalance() int64 {
+	if x != nil && x.MinBalance != nil {
+		return *x.MinBalance
+	}
+	return 0
+}
+
+func (x *ListWalletsRequest) GetMaxBalance() int64 {
+	if x != nil && x.MaxBalance != nil {
+		return *x.MaxBalance
+	}
+	return 0
+}
+
+func (x *ListWalletsRequest) GetCreatedFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedFrom
+	}
+	return nil
+}
+
+func (x *ListWalletsRequest) GetCreatedTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedTo
+	}
+	return nil
+}
+
+func (x *ListWalletsRequest) GetIncludeTotal() bool {
+	if x != nil {
+		return x.IncludeTotal
+	}
+	return false
+}
+
+type ListWalletsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Wallets []*Wallet              `protobuf:"bytes,1,rep,name=wallets,proto3" json:"wallets,omitempty"`
+	// Empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	Total         *int64 `protobuf:"varint,3,opt,name=total,proto3,oneof" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWalletsResponse) Reset() {
+	*x = ListWalletsResponse{}
+	mi := &file_wallet_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWalletsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWalletsResponse) ProtoMessage() {}
+
+func (x *ListWalletsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWalletsResponse.ProtoReflect.Descriptor instead.
+func (*ListWalletsResponse) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListWalletsResponse) GetWallets() []*Wallet {
+	if x != nil {
+		return x.Wallets
+	}
+	return nil
+}
+
+func (x *ListWalletsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *ListWalletsResponse) GetTotal() int64 {
+	if x != nil && x.Total != nil {
+		return *x.Total
+	}
+	return 0
+}
+
+type DeleteWalletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WalletId      string                 `protobuf:"bytes,1,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteWalletRequest) Reset() {
+	*x = DeleteWalletRequest{}
+	mi := &file_wallet_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteWalletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteWalletRequest) ProtoMessage() {}
+
+func (x *DeleteWalletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteWalletRequest.ProtoReflect.Descriptor instead.
+func (*DeleteWalletRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteWalletRequest) GetWalletId() string {
+	if x != nil {
+		return x.WalletId
+	}
+	return ""
+}
+
+type DeleteWalletResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The closed wallet
+	Wallet        *Wallet `protobuf:"bytes,1,opt,name=wallet,proto3" json:"wallet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteWalletResponse) Reset() {
+	*x = DeleteWalletResponse{}
+	mi := &file_wallet_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteWalletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteWalletResponse) ProtoMessage() {}
+
+func (x *DeleteWalletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteWalletResponse.ProtoReflect.Descriptor instead.
+func (*DeleteWalletResponse) Descriptor() ([]byte, []int) {
+	return file_wallet_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteWalletResponse) GetWallet() *Wallet {
+	if x != nil {
+		return x.Wallet
+	}
+	return nil
+}
+
+var File_wallet_proto protoreflect.FileDescriptor
+
+const file_wallet_proto_rawDesc = "" +
+	"\n" +
+	"\fwallet.proto\x12\twallet.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf6\x02\n" +
+	"\x06Wallet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\abalance\x18\x02 \x01(\x03R\abalance\x12'\n" +
+	"\x0fbalance_decimal\x18\x03 \x01(\tR\x0ebalanceDecimal\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\x12\x12\n" +
+	"\x04held\x18\x05 \x01(\x03R\x04held\x12/\n" +
+	"\x06status\x18\x06 \x01(\x0e2\x17.wallet.v1.WalletStatusR\x06status\x12!\n" +
+	"\fcredit_limit\x18\a \x01(\x03R\vcreditLimit\x12\x1f\n" +
+	"\vcredit_used\x18\b \x01(\x03R\n" +
+	"creditUsed\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"1\n" +
+	"\x13CreateWalletRequest\x12\x1a\n" +
+	"\bcurrency\x18\x01 \x01(\tR\bcurrency\"3\n" +
+	"\x14CreateWalletResponse\x12\x1b\n" +
+	"\twallet_id\x18\x01 \x01(\tR\bwalletId\"\x8d\x01\n" +
+	"\x11GetBalanceRequest\x12\x1b\n" +
+	"\twallet_id\x18\x01 \x01(\tR\bwalletId\x12*\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x16.wallet.v1.BalanceKindR\x04kind\x12/\n" +
+	"\x05as_of\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04asOf\"\xa8\x02\n" +
+	"\x12GetBalanceResponse\x12\x1b\n" +
+	"\twallet_id\x18\x01 \x01(\tR\bwalletId\x12*\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x16.wallet.v1.BalanceKindR\x04kind\x12\x18\n" +
+	"\abalance\x18\x03 \x01(\x03R\abalance\x12'\n" +
+	"\x0fbalance_decimal\x18\x04 \x01(\tR\x0ebalanceDecimal\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12/\n" +
+	"\x05as_of\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x04asOf\x129\n" +
+	"\n" +
+	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\xd3\x01\n" +
+	"\x15ApplyOperationRequest\x12\x1b\n" +
+	"\twallet_id\x18\x01 \x01(\tR\bwalletId\x12?\n" +
+	"\x0eoperation_type\x18\x02 \x01(\x0e2\x18.wallet.v1.OperationTypeR\roperationType\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12(\n" +
+	"\x10target_wallet_id\x18\x04 \x01(\tR\x0etargetWalletId\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\"\x95\x02\n" +
+	"\x16ApplyOperationResponse\x12!\n" +
+	"\foperation_id\x18\x01 \x01(\tR\voperationId\x12\x1b\n" +
+	"\twallet_id\x18\x02 \x01(\tR\bwalletId\x12?\n" +
+	"\x0eoperation_type\x18\x03 \x01(\x0e2\x18.wallet.v1.OperationTypeR\roperationType\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1f\n" +
+	"\vtransfer_id\x18\x05 \x01(\tR\n" +
+	"transferId\x12\x18\n" +
+	"\abalance\x18\x06 \x01(\x03R\abalance\x12'\n" +
+	"\x0fbalance_decimal\x18\a \x01(\tR\x0ebalanceDecimal\"\xd7\x03\n" +
+	"\x12ListWalletsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12)\n" +
+	"\x04sort\x18\x03 \x01(\x0e2\x15.wallet.v1.WalletSortR\x04sort\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x04 \x01(\bR\n" +
+	"descending\x12/\n" +
+	"\x06status\x18\x05 \x01(\x0e2\x17.wallet.v1.WalletStatusR\x06status\x12$\n" +
+	"\vmin_balance\x18\x06 \x01(\x03H\x00R\n" +
+	"minBalance\x88\x01\x01\x12$\n" +
+	"\vmax_balance\x18\a \x01(\x03H\x01R\n" +
+	"maxBalance\x88\x01\x01\x12=\n" +
+	"\fcreated_from\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\vcreatedFrom\x129\n" +
+	"\n" +
+	"created_to\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedTo\x12#\n" +
+	"\rinclude_total\x18\n" +
+	" \x01(\bR\fincludeTotalB\x0e\n" +
+	"\f_min_balanceB\x0e\n" +
+	"\f_max_balance\"\x8f\x01\n" +
+	"\x13ListWalletsResponse\x12+\n" +
+	"\awallets\x18\x01 \x03(\v2\x11.wallet.v1.WalletR\awallets\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\x12\x19\n" +
+	"\x05total\x18\x03 \x01(\x03H\x00R\x05total\x88\x01\x01B\b\n" +
+	"\x06_total\"2\n" +
+	"\x13DeleteWalletRequest\x12\x1b\n" +
+	"\twallet_id\x18\x01 \x01(\tR\bwalletId\"A\n" +
+	"\x14DeleteWalletResponse\x12)\n" +
+	"\x06wallet\x18\x01 \x01(\v2\x11.wallet.v1.WalletR\x06wallet*\x85\x01\n" +
+	"\rOperationType\x12\x1e\n" +
+	"\x1aOPERATION_TYPE_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16OPERATION_TYPE_DEPOSIT\x10\x01\x12\x1b\n" +
+	"\x17OPERATION_TYPE_WITHDRAW\x10\x02\x12\x1b\n" +
+	"\x17OPERATION_TYPE_TRANSFER\x10\x03*`\n" +
+	"\vBalanceKind\x12\x1c\n" +
+	"\x18BALANCE_KIND_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13BALANCE_KIND_LEDGER\x10\x01\x12\x1a\n" +
+	"\x16BALANCE_KIND_AVAILABLE\x10\x02*{\n" +
+	"\fWalletStatus\x12\x1d\n" +
+	"\x19WALLET_STATUS_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14WALLET_STATUS_ACTIVE\x10\x01\x12\x18\n" +
+	"\x14WALLET_STATUS_FROZEN\x10\x02\x12\x18\n" +
+	"\x14WALLET_STATUS_CLOSED\x10\x03*z\n" +
+	"\n" +
+	"WalletSort\x12\x1b\n" +
+	"\x17WALLET_SORT_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16WALLET_SORT_CREATED_AT\x10\x01\x12\x1a\n" +
+	"\x16WALLET_SORT_UPDATED_AT\x10\x02\x12\x17\n" +
+	"\x13WALLET_SORT_BALANCE\x10\x032\xa1\x03\n" +
+	"\rWalletService\x12O\n" +
+	"\fCreateWallet\x12\x1e.wallet.v1.CreateWalletRequest\x1a\x1f.wallet.v1.CreateWalletResponse\x12I\n" +
+	"\n" +
+	"GetBalance\x12\x1c.wallet.v1.GetBalanceRequest\x1a\x1d.wallet.v1.GetBalanceResponse\x12U\n" +
+	"\x0eApplyOperation\x12 .wallet.v1.ApplyOperationRequest\x1a!.wallet.v1.ApplyOperationResponse\x12L\n" +
+	"\vListWallets\x12\x1d.wallet.v1.ListWalletsRequest\x1a\x1e.wallet.v1.ListWalletsResponse\x12O\n" +
+	"\fDeleteWallet\x12\x1e.wallet.v1.DeleteWalletRequest\x1a\x1f.wallet.v1.DeleteWalletResponseB7Z5github.com/chtozamm/javacode-wallet/internal/walletpbb\x06proto3"
+
+var (
+	file_wallet_proto_rawDescOnce sync.Once
+	file_wallet_proto_rawDescData []byte
+)
+
+func file_wallet_proto_rawDescGZIP() []byte {
+	file_wallet_proto_rawDescOnce.Do(func() {
+		file_wallet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_wallet_proto_rawDesc), len(file_wallet_proto_rawDesc)))
+	})
+	return file_wallet_proto_rawDescData
+}
+
+var file_wallet_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_wallet_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_wallet_proto_goTypes = []any{
+	(OperationType)(0),             // 0: wallet.v1.OperationType
+	(BalanceKind)(0),               // 1: wallet.v1.BalanceKind
+	(WalletStatus)(0),              // 2: wallet.v1.WalletStatus
+	(WalletSort)(0),                // 3: wallet.v1.WalletSort
+	(*Wallet)(nil),                 // 4: wallet.v1.Wallet
+	(*CreateWalletRequest)(nil),    // 5: wallet.v1.CreateWalletRequest
+	(*CreateWalletResponse)(nil),   // 6: wallet.v1.CreateWalletResponse
+	(*GetBalanceRequest)(nil),      // 7: wallet.v1.GetBalanceRequest
+	(*GetBalanceResponse)(nil),     // 8: wallet.v1.GetBalanceResponse
+	(*ApplyOperationRequest)(nil),  // 9: wallet.v1.ApplyOperationRequest
+	(*ApplyOperationResponse)(nil), // 10: wallet.v1.ApplyOperationResponse
+	(*ListWalletsRequest)(nil),     // 11: wallet.v1.ListWalletsRequest
+	(*ListWalletsResponse)(nil),    // 12: wallet.v1.ListWalletsResponse
+	(*DeleteWalletRequest)(nil),    // 13: wallet.v1.DeleteWalletRequest
+	(*DeleteWalletResponse)(nil),   // 14: wallet.v1.DeleteWalletResponse
+	(*timestamppb.Timestamp)(nil),  // 15: google.protobuf.Timestamp
+}
+var file_wallet_proto_depIdxs = []int32{
+	2,  // 0: wallet.v1.Wallet.status:type_name -> wallet.v1.WalletStatus
+	15, // 1: wallet.v1.Wallet.created_at:type_name -> google.protobuf.Timestamp
+	15, // 2: wallet.v1.Wallet.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 3: wallet.v1.GetBalanceRequest.kind:type_name -> wallet.v1.BalanceKind
+	15, // 4: wallet.v1.GetBalanceRequest.as_of:type_name -> google.protobuf.Timestamp
+	1,  // 5: wallet.v1.GetBalanceResponse.kind:type_name -> wallet.v1.BalanceKind
+	15, // 6: wallet.v1.GetBalanceResponse.as_of:type_name -> google.protobuf.Timestamp
+	15, // 7: wallet.v1.GetBalanceResponse.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 8: wallet.v1.ApplyOperationRequest.operation_type:type_name -> wallet.v1.OperationType
+	0,  // 9: wallet.v1.ApplyOperationResponse.operation_type:type_name -> wallet.v1.OperationType
+	3,  // 10: wallet.v1.ListWalletsRequest.sort:type_name -> wallet.v1.WalletSort
+	2,  // 11: wallet.v1.ListWalletsRequest.status:type_name -> wallet.v1.WalletStatus
+	15, // 12: wallet.v1.ListWalletsRequest.created_from:type_name -> google.protobuf.Timestamp
+	15, // 13: wallet.v1.ListWalletsRequest.created_to:type_name -> google.protobuf.Timestamp
+	4,  // 14: wallet.v1.ListWalletsResponse.wallets:type_name -> wallet.v1.Wallet
+	4,  // 15: wallet.v1.DeleteWalletResponse.wallet:type_name -> wallet.v1.Wallet
+	5,  // 16: wallet.v1.WalletService.CreateWallet:input_type -> wallet.v1.CreateWalletRequest
+	7,  // 17: wallet.v1.WalletService.GetBalance:input_type -> wallet.v1.GetBalanceRequest
+	9,  // 18: wallet.v1.WalletService.ApplyOperation:input_type -> wallet.v1.ApplyOperationRequest
+	11, // 19: wallet.v1.WalletService.ListWallets:input_type -> wallet.v1.ListWalletsRequest
+	13, // 20: wallet.v1.WalletService.DeleteWallet:input_type -> wallet.v1.DeleteWalletRequest
+	6,  // 21: wallet.v1.WalletService.CreateWallet:output_type -> wallet.v1.CreateWalletResponse
+	8,  // 22: wallet.v1.WalletService.GetBalance:output_type -> wallet.v1.GetBalanceResponse
+	10, // 23: wallet.v1.WalletService.ApplyOperation:output_type -> wallet.v1.ApplyOperationResponse
+	12, // 24: wallet.v1.WalletService.ListWallets:output_type -> wallet.v1.ListWalletsResponse
+	14, // 25: wallet.v1.WalletService.DeleteWallet:output_type -> wallet.v1.DeleteWalletResponse
+	21, // [21:26] is the sub-list for method output_type
+	16, // [16:21] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_wallet_proto_init() }
+func file_wallet_proto_init() {
+	if File_wallet_proto != nil {
+		return
+	}
+	file_wallet_proto_msgTypes[7].OneofWrappers = []any{}
+	file_wallet_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_wallet_proto_rawDesc), len(file_wallet_proto_rawDesc)),
+			NumEnums:      4,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_wallet_proto_goTypes,
+		DependencyIndexes: file_wallet_proto_depIdxs,
+		EnumInfos:         file_wallet_proto_enumTypes,
+		MessageInfos:      file_wallet_proto_msgTypes,
+	}.Build()
+	File_wallet_proto = out.File
+	file_wallet_proto_goTypes = nil
+	file_wallet_proto_depIdxs = nil
+}
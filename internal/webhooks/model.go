@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Delivery statuses: a pending delivery is retried until it is delivered or runs out of attempts and is dead
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	Dead      = "dead"
+)
+
+// Headers sent with every webhook
+const (
+	HeaderDeliveryID = "Webhook-Id"
+	HeaderEvent      = "Webhook-Event"
+	HeaderTimestamp  = "Webhook-Timestamp"
+	HeaderSignature  = "Webhook-Signature"
+)
+
+const (
+	// MaxAttempts is the number of attempts after which a failing delivery becomes dead
+	MaxAttempts = 10
+	// baseBackoff is the delay after the first failed attempt; every next delay is twice as long
+	baseBackoff = 10 * time.Second
+	// maxBackoff caps the delay between attempts
+	maxBackoff = 6 * time.Hour
+)
+
+// SubscriptionRequest is the body of a request to register a webhook subscriber.
+// A subscription without a wallet ID receives the events of all wallets.
+type SubscriptionRequest struct {
+	URL      string `json:"url"`
+	WalletID string `json:"wallet_id,omitempty"`
+}
+
+// Sign returns the signature of a webhook body sent at the given Unix time:
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret.
+// Receivers recompute it to check that the webhook comes from the wallet service and was not altered.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the body sent at the given Unix time
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
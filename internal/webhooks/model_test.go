@@ -0,0 +1,41 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"balance.changed"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other secret", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature))
+	assert.False(t, Verify("secret", 1700000000, []byte(`{"type":"wallet.status_changed"}`), signature))
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{name: "No failed attempts", attempts: 0, expected: 0},
+		{name: "First failed attempt", attempts: 1, expected: 10 * time.Second},
+		{name: "Second failed attempt", attempts: 2, expected: 20 * time.Second},
+		{name: "Fifth failed attempt", attempts: 5, expected: 160 * time.Second},
+		{name: "Capped", attempts: 20, expected: 6 * time.Hour},
+		{name: "Huge number of attempts", attempts: 1000, expected: 6 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Backoff(tc.attempts))
+		})
+	}
+}
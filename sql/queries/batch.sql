@@ -0,0 +1,35 @@
+-- name: GetWithdrawalUsages :many
+-- Reversed parts of the withdrawals are refunded and do not count towards the limits
+SELECT
+	w.id::uuid AS wallet_id,
+	(l.wallet_id IS NOT NULL)::bool AS custom_limits,
+	l.daily_withdrawal_limit,
+	l.monthly_withdrawal_limit,
+	COALESCE(t.daily, 0)::bigint AS daily_withdrawn,
+	COALESCE(t.monthly, 0)::bigint AS monthly_withdrawn
+FROM unnest(@ids::uuid[]) AS w(id)
+LEFT JOIN wallet_limits l ON l.wallet_id = w.id
+LEFT JOIN LATERAL (
+	SELECT
+		SUM(o.amount - COALESCE(r.refunded, 0)) FILTER (WHERE o.created_at >= date_trunc('day', NOW())) AS daily,
+		SUM(o.amount - COALESCE(r.refunded, 0)) AS monthly
+	FROM operations o
+	LEFT JOIN LATERAL (
+		SELECT SUM(amount) AS refunded FROM operations WHERE reversal_of = o.id
+	) r ON TRUE
+	WHERE o.wallet_id = w.id
+		AND o.operation_type = 'withdraw'
+		AND o.reversal_of IS NULL
+		AND o.created_at >= date_trunc('month', NOW())
+) t ON TRUE;
+
+-- name: RecordOperation :batchexec
+WITH operation AS (
+	INSERT INTO operations (id, wallet_id, operation_type, amount)
+	VALUES (@id, @wallet_id, @operation_type, @amount)
+	RETURNING id
+)
+INSERT INTO ledger_entries (journal_id, account_id, operation_id, direction, amount)
+VALUES
+	((SELECT id FROM operation), @debit_account_id, (SELECT id FROM operation), 'debit', @amount),
+	((SELECT id FROM operation), @credit_account_id, (SELECT id FROM operation), 'credit', @amount);
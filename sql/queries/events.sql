@@ -0,0 +1,5 @@
+-- name: GetWalletEventsAfter :many
+SELECT seq, event_type, payload FROM outbox_events
+WHERE wallet_id = @wallet_id AND seq > @after_seq
+ORDER BY seq
+LIMIT @max_events;
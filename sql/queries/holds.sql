@@ -0,0 +1,38 @@
+-- name: CreateHold :one
+INSERT INTO holds (id, wallet_id, amount, expires_at)
+VALUES (
+	gen_random_uuid(),
+	@wallet_id,
+	@amount,
+	NOW() + @ttl::interval
+)
+RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 AND wallet_id = $2 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT *, expires_at <= NOW() AS expired FROM holds
+WHERE id = $1 AND wallet_id = $2 LIMIT 1
+FOR UPDATE;
+
+-- name: UpdateHold :one
+UPDATE holds SET status = $1, captured_amount = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING *;
+
+-- name: ExpireHolds :many
+UPDATE holds SET status = 'expired', updated_at = NOW()
+WHERE id IN (
+	SELECT id FROM holds
+	WHERE status = 'active' AND expires_at <= NOW()
+	ORDER BY expires_at
+	LIMIT @batch_size
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING wallet_id, amount;
+
+-- name: UpdateWalletHeld :exec
+UPDATE wallets SET held = held + @delta, updated_at = NOW()
+WHERE id = @id;
@@ -0,0 +1,38 @@
+-- name: CreateIdempotencyKey :one
+-- Claims the key until the lease ends. Nothing is returned if the key is taken: it has not expired yet
+-- and either holds a response or is locked by a request that is still running.
+INSERT INTO idempotency_keys (key, fingerprint, expires_at, locked_until)
+VALUES (
+	@key,
+	@fingerprint,
+	NOW() + @ttl::interval,
+	NOW() + @lease::interval
+)
+ON CONFLICT (key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+	status_code = NULL,
+	content_type = NULL,
+	response_body = NULL,
+	created_at = NOW(),
+	expires_at = EXCLUDED.expires_at,
+	locked_until = EXCLUDED.locked_until
+WHERE idempotency_keys.expires_at <= NOW()
+	OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW())
+RETURNING locked_until;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE key = $1 LIMIT 1;
+
+-- name: SaveIdempotencyKeyResponse :execrows
+-- Stores the response and unlocks the key, unless another request has taken it over
+UPDATE idempotency_keys
+SET status_code = @status_code, content_type = @content_type, response_body = @response_body, locked_until = NULL
+WHERE key = @key AND locked_until = @locked_until;
+
+-- name: DeleteIdempotencyKey :exec
+-- Releases the key, unless another request has taken it over
+DELETE FROM idempotency_keys WHERE key = @key AND locked_until = @locked_until;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at <= NOW();
@@ -0,0 +1,19 @@
+-- name: GetSystemAccount :one
+SELECT id FROM accounts
+WHERE account_type = 'system' AND name = $1 AND currency = $2 LIMIT 1;
+
+-- name: CreateSystemAccount :exec
+INSERT INTO accounts (id, account_type, name, currency)
+VALUES (
+	gen_random_uuid(),
+	'system',
+	$1,
+	$2
+)
+ON CONFLICT (name, currency) WHERE account_type = 'system' DO NOTHING;
+
+-- name: PostJournal :exec
+INSERT INTO ledger_entries (journal_id, account_id, operation_id, direction, amount)
+VALUES
+	(@journal_id, @debit_account_id, sqlc.narg(debit_operation_id), 'debit', @amount),
+	(@journal_id, @credit_account_id, sqlc.narg(credit_operation_id), 'credit', @amount);
@@ -0,0 +1,33 @@
+-- name: GetWalletLimits :one
+SELECT * FROM wallet_limits
+WHERE wallet_id = $1 LIMIT 1;
+
+-- name: SetWalletLimits :one
+INSERT INTO wallet_limits (wallet_id, daily_withdrawal_limit, monthly_withdrawal_limit)
+VALUES (
+	@wallet_id,
+	sqlc.narg(daily_withdrawal_limit),
+	sqlc.narg(monthly_withdrawal_limit)
+)
+ON CONFLICT (wallet_id) DO UPDATE
+SET daily_withdrawal_limit = EXCLUDED.daily_withdrawal_limit,
+	monthly_withdrawal_limit = EXCLUDED.monthly_withdrawal_limit,
+	updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteWalletLimits :exec
+DELETE FROM wallet_limits WHERE wallet_id = $1;
+
+-- name: GetWithdrawalTotals :one
+-- Reversed parts of the withdrawals are refunded and do not count towards the limits
+SELECT
+	COALESCE(SUM(o.amount - COALESCE(r.refunded, 0)) FILTER (WHERE o.created_at >= date_trunc('day', NOW())), 0)::bigint AS daily,
+	COALESCE(SUM(o.amount - COALESCE(r.refunded, 0)), 0)::bigint AS monthly
+FROM operations o
+LEFT JOIN LATERAL (
+	SELECT SUM(amount) AS refunded FROM operations WHERE reversal_of = o.id
+) r ON TRUE
+WHERE o.wallet_id = $1
+	AND o.operation_type = 'withdraw'
+	AND o.reversal_of IS NULL
+	AND o.created_at >= date_trunc('month', NOW());
@@ -0,0 +1,79 @@
+-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs (id, fix)
+VALUES (
+	gen_random_uuid(),
+	$1
+)
+RETURNING *;
+
+-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET status = @status, wallets_checked = @wallets_checked, mismatches_found = @mismatches_found,
+	mismatches_corrected = @mismatches_corrected, error = sqlc.narg(error), finished_at = NOW()
+WHERE id = @id
+RETURNING *;
+
+-- name: GetReconciliationRun :one
+SELECT * FROM reconciliation_runs
+WHERE id = $1 LIMIT 1;
+
+-- name: GetReconciliationRuns :many
+SELECT * FROM reconciliation_runs
+ORDER BY started_at DESC
+LIMIT $1;
+
+-- name: CountWallets :one
+SELECT COUNT(*) FROM wallets;
+
+-- name: GetBalanceMismatches :many
+SELECT
+	w.id AS wallet_id,
+	w.balance AS stored_balance,
+	COALESCE(o.balance, 0)::bigint AS operations_balance,
+	COALESCE(l.balance, 0)::bigint AS ledger_balance
+FROM wallets w
+LEFT JOIN (
+	SELECT wallet_id, SUM(CASE WHEN operation_type = 'deposit' THEN amount ELSE -amount END) AS balance
+	FROM operations GROUP BY wallet_id
+) o ON o.wallet_id = w.id
+LEFT JOIN (
+	SELECT account_id, SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END) AS balance
+	FROM ledger_entries GROUP BY account_id
+) l ON l.account_id = w.id
+WHERE w.balance <> COALESCE(o.balance, 0) OR w.balance <> COALESCE(l.balance, 0)
+ORDER BY w.id;
+
+-- name: GetWalletReconciliation :one
+SELECT
+	w.balance AS stored_balance,
+	(SELECT COALESCE(SUM(CASE WHEN operation_type = 'deposit' THEN amount ELSE -amount END), 0)
+		FROM operations WHERE wallet_id = w.id)::bigint AS operations_balance,
+	(SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+		FROM ledger_entries WHERE account_id = w.id)::bigint AS ledger_balance
+FROM wallets w
+WHERE w.id = $1;
+
+-- name: SyncWalletBalance :exec
+UPDATE wallets
+SET balance = (
+	SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+	FROM ledger_entries WHERE account_id = wallets.id
+), updated_at = NOW()
+WHERE wallets.id = $1;
+
+-- name: AddReconciliationMismatch :exec
+INSERT INTO reconciliation_mismatches (run_id, wallet_id, stored_balance, operations_balance, ledger_balance, corrected, correction_journal_id)
+VALUES (
+	@run_id,
+	@wallet_id,
+	@stored_balance,
+	@operations_balance,
+	@ledger_balance,
+	@corrected,
+	sqlc.narg(correction_journal_id)
+);
+
+-- name: GetReconciliationMismatches :many
+SELECT * FROM reconciliation_mismatches
+WHERE run_id = $1
+ORDER BY wallet_id;
@@ -0,0 +1,40 @@
+-- name: GetBalanceAsOf :one
+WITH snapshot AS (
+	SELECT taken_at, balance FROM balance_snapshots
+	WHERE wallet_id = @wallet_id AND taken_at <= @as_of
+	ORDER BY taken_at DESC
+	LIMIT 1
+)
+SELECT (
+	COALESCE((SELECT balance FROM snapshot), 0)
+	+ COALESCE((
+		SELECT SUM(CASE WHEN o.operation_type = 'deposit' THEN o.amount ELSE -o.amount END)
+		FROM operations o
+		WHERE o.wallet_id = @wallet_id
+			AND o.created_at > COALESCE((SELECT taken_at FROM snapshot), '-infinity'::timestamp)
+			AND o.created_at <= @as_of
+	), 0)
+)::bigint AS balance;
+
+-- name: GetBalanceSnapshotTime :one
+-- Returns the time of a snapshot taken @lag in the past, by the clock and in the time zone
+-- of the database that timestamps the operations
+SELECT date_trunc('second', NOW() - @lag::interval)::timestamp AS taken_at;
+
+-- name: CreateBalanceSnapshots :execrows
+INSERT INTO balance_snapshots (wallet_id, taken_at, balance)
+SELECT
+	o.wallet_id,
+	@taken_at::timestamp,
+	(COALESCE(s.balance, 0) + SUM(CASE WHEN o.operation_type = 'deposit' THEN o.amount ELSE -o.amount END))::bigint
+FROM operations o
+LEFT JOIN LATERAL (
+	SELECT taken_at, balance FROM balance_snapshots
+	WHERE wallet_id = o.wallet_id
+	ORDER BY taken_at DESC
+	LIMIT 1
+) s ON TRUE
+WHERE o.created_at <= @taken_at::timestamp
+	AND o.created_at > COALESCE(s.taken_at, '-infinity'::timestamp)
+GROUP BY o.wallet_id, s.balance
+ON CONFLICT (wallet_id, taken_at) DO NOTHING;
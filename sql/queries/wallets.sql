@@ -1,29 +1,86 @@
--- name: GetWallets :many
-SELECT * FROM wallets ORDER BY created_at;
+-- name: GetWallet :one
+SELECT * FROM wallets
+WHERE id = $1 LIMIT 1;
 
 -- name: GetBalance :one
 SELECT balance FROM wallets 
 WHERE id = $1 LIMIT 1;
 
+-- name: GetAvailableBalance :one
+SELECT LEAST(balance::numeric - held + credit_limit, 9223372036854775807)::bigint AS available FROM wallets
+WHERE id = $1 LIMIT 1;
+
+-- name: GetBalanceForUpdate :one
+SELECT balance, currency, held, status, credit_limit FROM wallets
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: GetBalancesForUpdate :many
+SELECT id, balance, currency, held, status, credit_limit FROM wallets
+WHERE id = ANY(@ids::uuid[])
+ORDER BY id
+FOR UPDATE;
+
 -- name: CreateWallet :one
-INSERT INTO wallets (id)
-VALUES (
-	gen_random_uuid()
+WITH wallet AS (
+	INSERT INTO wallets (id, currency)
+	VALUES (
+		gen_random_uuid(),
+		$1
+	)
+	RETURNING id, currency
 )
+INSERT INTO accounts (id, account_type, currency)
+SELECT id, 'wallet', currency FROM wallet
 RETURNING id;
 
--- name: AddOperation :exec
-INSERT INTO operations (id, wallet_id, operation_type, amount)
+-- name: AddOperation :one
+INSERT INTO operations (id, wallet_id, operation_type, amount, transfer_id, hold_id, reversal_of)
 VALUES (
 	gen_random_uuid(),
 	$1,
 	$2,
-	$3
-);
+	$3,
+	$4,
+	$5,
+	$6
+)
+RETURNING id;
+
+-- name: UpdateWalletCreditLimit :one
+UPDATE wallets SET credit_limit = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UpdateWalletStatus :one
+UPDATE wallets SET status = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: GetOperation :one
+SELECT * FROM operations
+WHERE id = $1 LIMIT 1;
+
+-- name: GetOperationForUpdate :one
+SELECT * FROM operations
+WHERE id = $1 AND wallet_id = $2 LIMIT 1
+FOR UPDATE;
 
--- name: UpdateWallet :exec
-UPDATE wallets SET balance = $1, updated_at = NOW()
-WHERE id = $2;
+-- name: GetReversedAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS reversed FROM operations
+WHERE reversal_of = $1;
 
--- name: DeleteWallet :exec
-DELETE FROM wallets WHERE id = $1;
+-- name: GetOperations :many
+SELECT * FROM operations
+WHERE wallet_id = @wallet_id
+	AND (sqlc.narg(operation_type)::text IS NULL OR operation_type = sqlc.narg(operation_type))
+	AND (sqlc.narg(min_amount)::bigint IS NULL OR amount >= sqlc.narg(min_amount))
+	AND (sqlc.narg(max_amount)::bigint IS NULL OR amount <= sqlc.narg(max_amount))
+	AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from))
+	AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to))
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY created_at DESC, id DESC
+LIMIT @row_limit;
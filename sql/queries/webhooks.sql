@@ -0,0 +1,95 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, url, secret, wallet_id)
+VALUES (
+	gen_random_uuid(),
+	@url,
+	@secret,
+	sqlc.narg(wallet_id)
+)
+RETURNING *;
+
+-- name: GetWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE active
+ORDER BY created_at;
+
+-- name: DeactivateWebhookSubscription :execrows
+UPDATE webhook_subscriptions
+SET active = FALSE
+WHERE id = $1 AND active;
+
+-- name: FanOutOutboxEvents :execrows
+-- Creates a delivery of each undispatched event for every active subscription it matches
+-- and marks the events as dispatched
+WITH events AS (
+	SELECT id, wallet_id FROM outbox_events
+	WHERE dispatched_at IS NULL
+	ORDER BY created_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+), deliveries AS (
+	INSERT INTO webhook_deliveries (id, event_id, subscription_id)
+	SELECT gen_random_uuid(), e.id, s.id
+	FROM events e
+	JOIN webhook_subscriptions s ON s.active AND (s.wallet_id IS NULL OR s.wallet_id = e.wallet_id)
+	ON CONFLICT (event_id, subscription_id) DO NOTHING
+)
+UPDATE outbox_events
+SET dispatched_at = NOW()
+WHERE id IN (SELECT id FROM events);
+
+-- name: DeleteExpiredWebhookEvents :execrows
+-- Deletes dispatched events older than the retention period together with their deliveries,
+-- unless a delivery to an active subscription is still pending
+DELETE FROM outbox_events e
+WHERE e.dispatched_at IS NOT NULL
+	AND e.created_at < NOW() - @retention::interval
+	AND NOT EXISTS (
+		SELECT 1 FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.event_id = e.id AND d.status = 'pending' AND s.active
+	);
+
+-- name: ClaimWebhookDeliveries :many
+-- Claims due deliveries for one attempt: their next attempt is moved past the lease,
+-- so another dispatcher does not pick them up while they are being sent
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = NOW() + @lease::interval
+FROM outbox_events e, webhook_subscriptions s
+WHERE d.id IN (
+	SELECT wd.id FROM webhook_deliveries wd
+	JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+	WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW() AND ws.active
+	ORDER BY wd.next_attempt_at
+	LIMIT @max_deliveries
+	FOR UPDATE OF wd SKIP LOCKED
+) AND e.id = d.event_id AND s.id = d.subscription_id
+RETURNING d.id, d.attempts, e.event_type, e.payload, s.url, s.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = @last_status_code, last_error = NULL, delivered_at = NOW()
+WHERE id = @id;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = @status, next_attempt_at = @next_attempt_at,
+	last_status_code = sqlc.narg(last_status_code), last_error = @last_error
+WHERE id = @id;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 LIMIT 1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)
+ORDER BY created_at DESC
+LIMIT @max_deliveries;
+
+-- name: RedeliverWebhookDelivery :one
+-- Schedules a delivered or dead delivery to be sent again right away with a fresh set of attempts
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+WHERE id = $1 AND status <> 'pending'
+RETURNING *;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE idempotency_keys(
+	key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status_code INTEGER,
+	content_type TEXT,
+	response_body BYTEA,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE operations ADD COLUMN transfer_id UUID;
+
+CREATE INDEX idx_operations_transfer_id ON operations(transfer_id) WHERE transfer_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_operations_transfer_id;
+ALTER TABLE operations DROP COLUMN transfer_id;
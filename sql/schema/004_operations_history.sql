@@ -0,0 +1,9 @@
+-- +goose Up
+UPDATE operations SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE operations ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_operations_wallet_id_created_at_id ON operations(wallet_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX idx_operations_wallet_id_created_at_id;
+ALTER TABLE operations ALTER COLUMN created_at DROP NOT NULL;
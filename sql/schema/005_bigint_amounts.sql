@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE wallets ALTER COLUMN balance TYPE BIGINT;
+ALTER TABLE operations ALTER COLUMN amount TYPE BIGINT;
+
+-- +goose Down
+ALTER TABLE operations ALTER COLUMN amount TYPE INTEGER;
+ALTER TABLE wallets ALTER COLUMN balance TYPE INTEGER;
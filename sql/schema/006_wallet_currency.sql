@@ -0,0 +1,7 @@
+-- +goose Up
+-- Existing wallets were created when the service only worked with rubles
+ALTER TABLE wallets ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE wallets ALTER COLUMN currency DROP DEFAULT;
+
+-- +goose Down
+ALTER TABLE wallets DROP COLUMN currency;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Funds reserved by active holds: available balance is balance - held
+ALTER TABLE wallets ADD COLUMN held BIGINT NOT NULL DEFAULT 0 CHECK (held >= 0);
+
+CREATE TABLE holds(
+	id UUID PRIMARY KEY,
+	wallet_id UUID NOT NULL,
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	captured_amount BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+	status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'captured', 'voided', 'expired')),
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_hold_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'active';
+
+-- Captures are recorded as withdrawals that reference their hold
+ALTER TABLE operations ADD COLUMN hold_id UUID;
+ALTER TABLE operations ADD CONSTRAINT fk_operation_hold_id FOREIGN KEY (hold_id) REFERENCES holds(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE operations DROP COLUMN hold_id;
+DROP TABLE holds;
+ALTER TABLE wallets DROP COLUMN held;
@@ -0,0 +1,117 @@
+-- +goose Up
+-- Ledger accounts: every wallet has an account with the same ID,
+-- system accounts (such as "external" funding) exist once per currency
+CREATE TABLE accounts(
+	id UUID PRIMARY KEY,
+	account_type TEXT NOT NULL CHECK (account_type IN ('wallet', 'system')),
+	name TEXT,
+	currency TEXT NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CHECK ((account_type = 'system') = (name IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX idx_accounts_system_name_currency ON accounts(name, currency) WHERE account_type = 'system';
+
+-- Append-only double-entry ledger: the entries of each journal must balance
+CREATE TABLE ledger_entries(
+	id BIGSERIAL PRIMARY KEY,
+	journal_id UUID NOT NULL,
+	account_id UUID NOT NULL,
+	operation_id UUID,
+	direction TEXT NOT NULL CHECK (direction IN ('debit', 'credit')),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_ledger_entry_account_id FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE INDEX idx_ledger_entries_journal_id ON ledger_entries(journal_id);
+CREATE INDEX idx_ledger_entries_account_id ON ledger_entries(account_id);
+
+-- Balance of every account: credits increase it, debits decrease it
+CREATE VIEW account_balances AS
+SELECT
+	a.id AS account_id,
+	a.account_type,
+	a.name,
+	a.currency,
+	COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END), 0)::bigint AS balance
+FROM accounts a
+LEFT JOIN ledger_entries e ON e.account_id = a.id
+GROUP BY a.id;
+
+-- Open accounts for existing wallets and post their current balances as opening entries
+INSERT INTO accounts (id, account_type, currency)
+SELECT id, 'wallet', currency FROM wallets;
+
+INSERT INTO accounts (id, account_type, name, currency)
+SELECT gen_random_uuid(), 'system', 'external', currency FROM wallets GROUP BY currency;
+
+WITH opening AS (
+	SELECT w.id AS wallet_id, w.balance, a.id AS external_id, gen_random_uuid() AS journal_id
+	FROM wallets w
+	JOIN accounts a ON a.account_type = 'system' AND a.name = 'external' AND a.currency = w.currency
+	WHERE w.balance <> 0
+)
+INSERT INTO ledger_entries (journal_id, account_id, direction, amount)
+SELECT journal_id, external_id, CASE WHEN balance > 0 THEN 'debit' ELSE 'credit' END, ABS(balance) FROM opening
+UNION ALL
+SELECT journal_id, wallet_id, CASE WHEN balance > 0 THEN 'credit' ELSE 'debit' END, ABS(balance) FROM opening;
+
+-- Wallet balances are derived from the entries posted to wallet accounts
+-- +goose StatementBegin
+CREATE FUNCTION apply_ledger_entry() RETURNS trigger AS $$
+BEGIN
+	UPDATE wallets
+	SET balance = balance + CASE WHEN NEW.direction = 'credit' THEN NEW.amount ELSE -NEW.amount END,
+		updated_at = NOW()
+	WHERE id = NEW.account_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_apply
+AFTER INSERT ON ledger_entries
+FOR EACH ROW EXECUTE FUNCTION apply_ledger_entry();
+
+-- Every journal must have equal debits and credits by the end of the transaction
+-- +goose StatementBegin
+CREATE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+	IF (
+		SELECT SUM(CASE WHEN direction = 'debit' THEN amount ELSE -amount END)
+		FROM ledger_entries
+		WHERE journal_id = NEW.journal_id
+	) <> 0 THEN
+		RAISE EXCEPTION 'ledger journal % is not balanced', NEW.journal_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+AFTER INSERT ON ledger_entries
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
+
+-- Posted entries can never be changed; mistakes are corrected with new entries
+-- +goose StatementBegin
+CREATE FUNCTION reject_ledger_entry_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'ledger entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_append_only
+BEFORE UPDATE OR DELETE ON ledger_entries
+FOR EACH ROW EXECUTE FUNCTION reject_ledger_entry_change();
+
+-- +goose Down
+DROP VIEW account_balances;
+DROP TABLE ledger_entries;
+DROP FUNCTION reject_ledger_entry_change;
+DROP FUNCTION check_journal_balanced;
+DROP FUNCTION apply_ledger_entry;
+DROP TABLE accounts;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Runs of the balance reconciliation that compares stored wallet balances
+-- with their operation history and ledger entries
+CREATE TABLE reconciliation_runs(
+	id UUID PRIMARY KEY,
+	fix BOOLEAN NOT NULL DEFAULT FALSE,
+	status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+	wallets_checked INTEGER NOT NULL DEFAULT 0,
+	mismatches_found INTEGER NOT NULL DEFAULT 0,
+	mismatches_corrected INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_reconciliation_runs_started_at ON reconciliation_runs(started_at DESC);
+
+-- Wallets whose balances did not match during a run
+CREATE TABLE reconciliation_mismatches(
+	run_id UUID NOT NULL,
+	wallet_id UUID NOT NULL,
+	stored_balance BIGINT NOT NULL,
+	operations_balance BIGINT NOT NULL,
+	ledger_balance BIGINT NOT NULL,
+	corrected BOOLEAN NOT NULL DEFAULT FALSE,
+	correction_journal_id UUID,
+	PRIMARY KEY (run_id, wallet_id),
+	CONSTRAINT fk_reconciliation_mismatch_run_id FOREIGN KEY (run_id) REFERENCES reconciliation_runs(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE reconciliation_mismatches;
+DROP TABLE reconciliation_runs;
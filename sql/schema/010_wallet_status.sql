@@ -0,0 +1,18 @@
+-- +goose Up
+-- Wallets are closed instead of deleted, so their history is kept
+ALTER TABLE wallets ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'closed'));
+
+ALTER TABLE operations DROP CONSTRAINT fk_wallet_id;
+ALTER TABLE operations ADD CONSTRAINT fk_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE RESTRICT;
+
+ALTER TABLE holds DROP CONSTRAINT fk_hold_wallet_id;
+ALTER TABLE holds ADD CONSTRAINT fk_hold_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE RESTRICT;
+
+-- +goose Down
+ALTER TABLE holds DROP CONSTRAINT fk_hold_wallet_id;
+ALTER TABLE holds ADD CONSTRAINT fk_hold_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE;
+
+ALTER TABLE operations DROP CONSTRAINT fk_wallet_id;
+ALTER TABLE operations ADD CONSTRAINT fk_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE;
+
+ALTER TABLE wallets DROP COLUMN status;
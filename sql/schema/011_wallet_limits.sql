@@ -0,0 +1,13 @@
+-- +goose Up
+-- Withdrawal limits of a wallet: NULL means no limit.
+-- Wallets without a row use the default limits from the configuration.
+CREATE TABLE wallet_limits(
+	wallet_id UUID PRIMARY KEY,
+	daily_withdrawal_limit BIGINT CHECK (daily_withdrawal_limit > 0),
+	monthly_withdrawal_limit BIGINT CHECK (monthly_withdrawal_limit > 0),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_wallet_limits_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE wallet_limits;
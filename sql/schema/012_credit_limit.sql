@@ -0,0 +1,8 @@
+-- +goose Up
+-- Credit wallets may go below zero down to their credit limit
+ALTER TABLE wallets ADD COLUMN credit_limit BIGINT NOT NULL DEFAULT 0 CHECK (credit_limit >= 0);
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_within_credit_limit CHECK (balance >= -credit_limit);
+
+-- +goose Down
+ALTER TABLE wallets DROP CONSTRAINT wallets_balance_within_credit_limit;
+ALTER TABLE wallets DROP COLUMN credit_limit;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A reversal is an operation of the opposite type that refunds part or all of the original operation
+ALTER TABLE operations ADD COLUMN reversal_of UUID;
+ALTER TABLE operations ADD CONSTRAINT fk_operation_reversal_of FOREIGN KEY (reversal_of) REFERENCES operations(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_operations_reversal_of ON operations(reversal_of) WHERE reversal_of IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_operations_reversal_of;
+ALTER TABLE operations DROP CONSTRAINT fk_operation_reversal_of;
+ALTER TABLE operations DROP COLUMN reversal_of;
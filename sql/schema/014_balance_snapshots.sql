@@ -0,0 +1,13 @@
+-- +goose Up
+-- Periodic snapshots of wallet balances calculated from the operation history,
+-- so historical balances only need to sum the operations after the nearest snapshot
+CREATE TABLE balance_snapshots(
+	wallet_id UUID NOT NULL,
+	taken_at TIMESTAMP NOT NULL,
+	balance BIGINT NOT NULL,
+	PRIMARY KEY (wallet_id, taken_at),
+	CONSTRAINT fk_balance_snapshot_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE balance_snapshots;
@@ -0,0 +1,127 @@
+-- +goose Up
+-- Subscribers that receive webhooks about wallet events; a subscription without a wallet receives events of all wallets
+CREATE TABLE webhook_subscriptions(
+	id UUID PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	wallet_id UUID,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_webhook_subscription_wallet_id FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+-- Transactional outbox: events are written in the transaction that changes the balance
+-- and fanned out to the subscriptions by the dispatcher
+CREATE TABLE outbox_events(
+	id UUID PRIMARY KEY,
+	event_type TEXT NOT NULL,
+	wallet_id UUID NOT NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	dispatched_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_events_undispatched ON outbox_events(created_at) WHERE dispatched_at IS NULL;
+
+-- Delivery of an event to a subscription: pending deliveries are retried with exponential backoff
+-- until they succeed or run out of attempts and become dead
+CREATE TABLE webhook_deliveries(
+	id UUID PRIMARY KEY,
+	event_id UUID NOT NULL,
+	subscription_id UUID NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_status_code INTEGER,
+	last_error TEXT,
+	delivered_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (event_id, subscription_id),
+	CONSTRAINT fk_webhook_delivery_event_id FOREIGN KEY (event_id) REFERENCES outbox_events(id) ON DELETE CASCADE,
+	CONSTRAINT fk_webhook_delivery_subscription_id FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Every entry posted to a wallet account enqueues a balance event with the balance right after the entry.
+-- The trigger name sorts after ledger_entries_apply, so the wallet balance is already updated.
+-- +goose StatementBegin
+CREATE FUNCTION enqueue_balance_event() RETURNS trigger AS $$
+DECLARE
+	event_id UUID := gen_random_uuid();
+BEGIN
+	INSERT INTO outbox_events (id, event_type, wallet_id, payload)
+	SELECT
+		event_id,
+		'balance.changed',
+		w.id,
+		jsonb_build_object(
+			'id', event_id,
+			'type', 'balance.changed',
+			'wallet_id', w.id,
+			'balance', w.balance,
+			'currency', w.currency,
+			'change', CASE WHEN NEW.direction = 'credit' THEN NEW.amount ELSE -NEW.amount END,
+			'operation', (
+				SELECT jsonb_build_object(
+					'id', o.id,
+					'operation_type', o.operation_type,
+					'amount', o.amount,
+					'transfer_id', o.transfer_id,
+					'hold_id', o.hold_id,
+					'reversal_of', o.reversal_of,
+					'created_at', o.created_at
+				)
+				FROM operations o WHERE o.id = NEW.operation_id
+			),
+			'created_at', NOW()
+		)
+	FROM wallets w
+	WHERE w.id = NEW.account_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_outbox
+AFTER INSERT ON ledger_entries
+FOR EACH ROW EXECUTE FUNCTION enqueue_balance_event();
+
+-- Every status change of a wallet enqueues a status event
+-- +goose StatementBegin
+CREATE FUNCTION enqueue_wallet_status_event() RETURNS trigger AS $$
+DECLARE
+	event_id UUID := gen_random_uuid();
+BEGIN
+	INSERT INTO outbox_events (id, event_type, wallet_id, payload)
+	VALUES (
+		event_id,
+		'wallet.status_changed',
+		NEW.id,
+		jsonb_build_object(
+			'id', event_id,
+			'type', 'wallet.status_changed',
+			'wallet_id', NEW.id,
+			'status', NEW.status,
+			'previous_status', OLD.status,
+			'created_at', NOW()
+		)
+	);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER wallets_status_outbox
+AFTER UPDATE OF status ON wallets
+FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE FUNCTION enqueue_wallet_status_event();
+
+-- +goose Down
+DROP TRIGGER wallets_status_outbox ON wallets;
+DROP FUNCTION enqueue_wallet_status_event;
+DROP TRIGGER ledger_entries_outbox ON ledger_entries;
+DROP FUNCTION enqueue_balance_event;
+DROP TABLE webhook_deliveries;
+DROP TABLE outbox_events;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Events get a sequence number, so streaming clients can resume after the last event they received
+ALTER TABLE outbox_events ADD COLUMN seq BIGINT NOT NULL GENERATED ALWAYS AS IDENTITY;
+
+CREATE UNIQUE INDEX idx_outbox_events_wallet_id_seq ON outbox_events(wallet_id, seq);
+
+-- Every event is also published on the wallet_events channel; listeners receive it once the transaction commits
+-- +goose StatementBegin
+CREATE FUNCTION notify_wallet_event() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('wallet_events', jsonb_build_object(
+		'seq', NEW.seq,
+		'wallet_id', NEW.wallet_id,
+		'type', NEW.event_type,
+		'payload', NEW.payload
+	)::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER outbox_events_notify
+AFTER INSERT ON outbox_events
+FOR EACH ROW EXECUTE FUNCTION notify_wallet_event();
+
+-- +goose Down
+DROP TRIGGER outbox_events_notify ON outbox_events;
+DROP FUNCTION notify_wallet_event;
+DROP INDEX idx_outbox_events_wallet_id_seq;
+ALTER TABLE outbox_events DROP COLUMN seq;
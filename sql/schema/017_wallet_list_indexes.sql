@@ -0,0 +1,18 @@
+-- +goose Up
+-- Sort columns of the wallet list must not be null for keyset pagination
+UPDATE wallets SET created_at = NOW() WHERE created_at IS NULL;
+UPDATE wallets SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE wallets ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE wallets ALTER COLUMN updated_at SET NOT NULL;
+
+-- Keyset pagination of the wallet list in every supported sort order
+CREATE INDEX idx_wallets_balance_id ON wallets(balance, id);
+CREATE INDEX idx_wallets_created_at_id ON wallets(created_at, id);
+CREATE INDEX idx_wallets_updated_at_id ON wallets(updated_at, id);
+
+-- +goose Down
+DROP INDEX idx_wallets_updated_at_id;
+DROP INDEX idx_wallets_created_at_id;
+DROP INDEX idx_wallets_balance_id;
+ALTER TABLE wallets ALTER COLUMN updated_at DROP NOT NULL;
+ALTER TABLE wallets ALTER COLUMN created_at DROP NOT NULL;
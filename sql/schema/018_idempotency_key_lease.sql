@@ -0,0 +1,8 @@
+-- +goose Up
+-- A request holds its key until locked_until; a retry may take over the key of a request
+-- that did not finish by then. Keys of finished requests have no lock.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP;
+UPDATE idempotency_keys SET locked_until = NOW() WHERE status_code IS NULL;
+
+-- +goose Down
+ALTER TABLE idempotency_keys DROP COLUMN locked_until;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Dispatched events are deleted with their deliveries once they are older than the retention period
+CREATE INDEX idx_outbox_events_dispatched ON outbox_events(created_at) WHERE dispatched_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_outbox_events_dispatched;
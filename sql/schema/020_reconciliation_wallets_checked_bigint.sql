@@ -0,0 +1,6 @@
+-- +goose Up
+-- The number of checked wallets is a count of all wallets, which is a BIGINT
+ALTER TABLE reconciliation_runs ALTER COLUMN wallets_checked TYPE BIGINT;
+
+-- +goose Down
+ALTER TABLE reconciliation_runs ALTER COLUMN wallets_checked TYPE INTEGER;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Every balance change updates balance and updated_at. Indexes on them turn these updates from HOT updates
+-- into index writes on the hottest write path, so the wallet list sorts by them without an index.
+DROP INDEX idx_wallets_updated_at_id;
+DROP INDEX idx_wallets_balance_id;
+
+-- +goose Down
+CREATE INDEX idx_wallets_balance_id ON wallets(balance, id);
+CREATE INDEX idx_wallets_updated_at_id ON wallets(updated_at, id);
@@ -37,8 +37,12 @@ case "$1" in
 		# Run benchmark
 		ab -n 10000 -c 1000 $API_URL/wallets/$wallet_id
 
-		# Delete wallet
-		curl -s $API_URL/wallets/$wallet_id -X DELETE	
+		# Close wallet
+		curl -sf $API_URL/wallets/$wallet_id -X DELETE -u javacode:secret
+		if [ $? -ne 0 ]; then
+			echo "Error closing wallet"
+			exit 1
+		fi
 		;;
 	test)
 		# Create new wallet
@@ -114,21 +118,31 @@ case "$1" in
 		echo "# List all wallets:"
 		echo $all_wallets
 
-		# Delete wallet
-		curl -s $API_URL/wallets/$wallet_id -X DELETE
+		# Withdraw the rest of the balance, so the wallet can be closed
+		curl -s $API_URL/wallets/$wallet_id -X POST \
+			-H "Content-Type: application/json" \
+			-d '{"operation_type": "withdraw","amount": 350}'
+		if [ $? -ne 0 ]; then
+			echo "Error withdrawing funds"
+			exit 1
+		fi
+		echo "# Withdraw 350..."
+
+		# Close wallet
+		curl -sf $API_URL/wallets/$wallet_id -X DELETE -u javacode:secret
 		if [ $? -ne 0 ]; then
-			echo "Error deleting wallet"
+			echo "Error closing wallet"
 			exit 1
 		fi
-		echo "# Deleting the wallet..."
+		echo "# Closing the wallet..."
 
-		# Get all wallets after deletion
+		# Get all wallets after closing
 		all_wallets=$(curl -s $API_URL/wallets -u javacode:secret)
 		if [ $? -ne 0 ]; then
 			echo "Error getting all wallets"
 			exit 1
 		fi
-		echo "# List all wallets after deletion:"
+		echo "# List all wallets after closing:"
 		echo $all_wallets
 	;;
 	*)